@@ -80,8 +80,16 @@
           referenced=$(nix eval .#secretsGenerationData --json | jq \
             --raw-output \
             '.secrets
-              | keys
-              | map("./secrets/data/\(.).age\n./secrets/entropy/\(.).age")
+              | to_entries
+              | map(
+                  .key as $name
+                  | ((.value.generation.json.outputs // {}) + (.value.generation.template.outputs // {}) | keys) as $outputs
+                  | "./secrets/entropy/\($name).age",
+                    if $outputs == []
+                    then "./secrets/data/\($name).age"
+                    else $outputs[] | "./secrets/data/\($name)/\(.).age"
+                    end
+                )
               | .[]
             ' | sort)
 
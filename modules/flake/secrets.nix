@@ -43,6 +43,12 @@
           builtins.toJSON
           (pkgs.writeText "secrets-config.json")
         ];
+
+        # Catches problems in the secrets configuration without needing any identity.
+        checks.secrets-lint = pkgs.runCommand "secrets-lint" { } ''
+          ${self'.packages.secrets-generator}/bin/secrets-generator lint -config ${self'.secretsGenerationConfig}
+          touch $out
+        '';
       };
     });
   };
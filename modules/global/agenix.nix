@@ -12,10 +12,34 @@ let
   };
 
   jsonLib = {
+    base64Decode = data: jsonMakeFunctionCall "base64Decode" {
+      inherit data;
+    };
+
+    base64Encode = data: jsonMakeFunctionCall "base64Encode" {
+      inherit data;
+    };
+
+    base64URLDecode = data: jsonMakeFunctionCall "base64URLDecode" {
+      inherit data;
+    };
+
+    base64URLEncode = data: jsonMakeFunctionCall "base64URLEncode" {
+      inherit data;
+    };
+
     fmt = format: args: jsonMakeFunctionCall "fmt" {
       inherit format args;
     };
 
+    fromJSON = data: jsonMakeFunctionCall "fromJSON" {
+      inherit data;
+    };
+
+    hashAPR1 = data: jsonMakeFunctionCall "hashAPR1" {
+      inherit data;
+    };
+
     hashArgon2id = data: memory: iterations: parallelism: jsonMakeFunctionCall "hashArgon2id" {
       inherit data memory iterations parallelism;
     };
@@ -24,9 +48,109 @@ let
       inherit data rounds;
     };
 
+    hashPBKDF2SHA256 = data: iterations: jsonMakeFunctionCall "hashPBKDF2SHA256" {
+      inherit data iterations;
+    };
+
+    hashSHA512Crypt = data: rounds: jsonMakeFunctionCall "hashSHA512Crypt" {
+      inherit data rounds;
+    };
+
+    hashSSHA = data: jsonMakeFunctionCall "hashSSHA" {
+      inherit data;
+    };
+
+    hashScrypt = data: n: r: p: jsonMakeFunctionCall "hashScrypt" {
+      inherit data n r p;
+    };
+
+    hashYescrypt = data: jsonMakeFunctionCall "hashYescrypt" {
+      inherit data;
+    };
+
+    hexDecode = data: jsonMakeFunctionCall "hexDecode" {
+      inherit data;
+    };
+
+    hexEncode = data: jsonMakeFunctionCall "hexEncode" {
+      inherit data;
+    };
+
+    hmacSHA256 = key: data: jsonMakeFunctionCall "hmacSHA256" {
+      inherit key data;
+    };
+
+    join = separator: list: jsonMakeFunctionCall "join" {
+      inherit separator list;
+    };
+
+    lower = data: jsonMakeFunctionCall "lower" {
+      inherit data;
+    };
+
     readSecret = name: jsonMakeFunctionCall "readSecret" {
       inherit name;
     };
+
+    readSecretField = name: path: jsonMakeFunctionCall "readSecretField" {
+      inherit name path;
+    };
+
+    readSecretJSON = name: jsonMakeFunctionCall "readSecretJSON" {
+      inherit name;
+    };
+
+    sha256sum = data: jsonMakeFunctionCall "sha256sum" {
+      inherit data;
+    };
+
+    shellQuote = data: jsonMakeFunctionCall "shellQuote" {
+      inherit data;
+    };
+
+    split = separator: data: jsonMakeFunctionCall "split" {
+      inherit separator data;
+    };
+
+    stringReplace = s: old: new: n: jsonMakeFunctionCall "stringReplace" {
+      inherit s old new n;
+    };
+
+    toDotenv = value: jsonMakeFunctionCall "toDotenv" {
+      inherit value;
+    };
+
+    toINI = value: jsonMakeFunctionCall "toINI" {
+      inherit value;
+    };
+
+    toJSON = value: jsonMakeFunctionCall "toJSON" {
+      inherit value;
+    };
+
+    toTOML = value: jsonMakeFunctionCall "toTOML" {
+      inherit value;
+    };
+
+    toYAML = value: jsonMakeFunctionCall "toYAML" {
+      inherit value;
+    };
+
+    trim = data: jsonMakeFunctionCall "trim" {
+      inherit data;
+    };
+
+    upper = data: jsonMakeFunctionCall "upper" {
+      inherit data;
+    };
+
+    urlPathEscape = data: jsonMakeFunctionCall "urlPathEscape" {
+      inherit data;
+    };
+
+    urlQueryEscape = data: jsonMakeFunctionCall "urlQueryEscape" {
+      inherit data;
+    };
   };
 
   jsonCoerceContent = content:
@@ -34,14 +158,108 @@ let
     then content { inherit jsonLib; }
     else content;
 
+  # The content is written as JSON if no format is given.
+  jsonFormatOption = lib.mkOption {
+    default = null;
+    type = lib.types.nullOr (lib.types.enum [ "dotenv" "ini" "json" "toml" "yaml" ]);
+  };
+
+  # Names of the secrets that can be mounted.
+  # Secrets with named outputs are mounted per output.
+  mountableSecrets = lib.pipe globalConfig.agenix.secrets [
+    (lib.mapAttrsToList (secretName: secret:
+      let
+        outputs = lib.attrNames (
+          (if secret.generation.json == null then { } else secret.generation.json.outputs)
+          // (if secret.generation.template == null then { } else secret.generation.template.outputs)
+        );
+      in
+      if outputs == [ ]
+      then [ secretName ]
+      else builtins.map (output: "${secretName}/${output}") outputs))
+    lib.flatten
+    (names: lib.genAttrs names (_: true))
+  ];
+
   generationOptions = secretName: {
+    # Imports a secret that isn't generated from exactly one source.
+    # The secret is imported again whenever the value at its source changes.
+    import = lib.mkOption {
+      default = null;
+      type = lib.types.nullOr (lib.types.submodule {
+        options = {
+          # This must be a string rather than a path so that the file isn't copied into the Nix store.
+          file = lib.mkOption {
+            default = null;
+            type = lib.types.nullOr lib.types.str;
+          };
+
+          env = lib.mkOption {
+            default = null;
+            type = lib.types.nullOr lib.types.str;
+          };
+
+          # The secret is the first line of the pass entry.
+          pass = lib.mkOption {
+            default = null;
+            type = lib.types.nullOr lib.types.str;
+          };
+
+          passCommand = lib.mkOption {
+            default = null;
+            type = lib.types.nullOr lib.types.str;
+          };
+
+          # One trailing newline is removed from the output of the command.
+          command = lib.mkOption {
+            default = null;
+            type = lib.types.nullOr (lib.types.listOf lib.types.str);
+          };
+        };
+      });
+    };
+
+    # Selects a generator registered by a program that embeds the generator through package secretsgen.
+    custom = lib.mkOption {
+      default = null;
+      type = lib.types.nullOr (lib.types.submodule {
+        options = {
+          type = lib.mkOption {
+            type = lib.types.str;
+          };
+
+          params = lib.mkOption {
+            default = null;
+            type = lib.types.anything;
+          };
+        };
+      });
+    };
+
     json = lib.mkOption {
       default = null;
       type = lib.types.nullOr (lib.types.submodule {
         options = {
           content = lib.mkOption {
+            default = null;
             type = lib.types.coercedTo lib.types.anything jsonCoerceContent lib.types.anything;
           };
+
+          format = jsonFormatOption;
+
+          # Named outputs are stored and mounted as separate secrets called "<secret>/<output>".
+          outputs = lib.mkOption {
+            default = { };
+            type = lib.types.attrsOf (lib.types.submodule {
+              options = {
+                content = lib.mkOption {
+                  type = lib.types.coercedTo lib.types.anything jsonCoerceContent lib.types.anything;
+                };
+
+                format = jsonFormatOption;
+              };
+            });
+          };
         };
       });
     };
@@ -127,6 +345,7 @@ let
       type = lib.types.nullOr (lib.types.submodule {
         options = {
           content = lib.mkOption {
+            default = "";
             type = lib.types.str;
           };
 
@@ -134,6 +353,19 @@ let
             default = { };
             type = lib.types.attrs;
           };
+
+          # Named outputs are stored and mounted as separate secrets called "<secret>/<output>".
+          # All outputs share the same data.
+          outputs = lib.mkOption {
+            default = { };
+            type = lib.types.attrsOf lib.types.str;
+          };
+
+          # Missing keys in data are an error and output containing "<no value>" is rejected unless this is set to false.
+          strict = lib.mkOption {
+            default = null;
+            type = lib.types.nullOr lib.types.bool;
+          };
         };
       });
     };
@@ -151,6 +383,12 @@ in
       type = lib.types.attrsOf (lib.types.submodule ({ name, ... }: {
         options = {
           generation = generationOptions name;
+
+          # Secrets (or outputs of secrets) that this secret reads in addition to those the generator finds in its generation parameters.
+          dependsOn = lib.mkOption {
+            default = [ ];
+            type = lib.types.listOf lib.types.str;
+          };
         };
       }));
     };
@@ -184,6 +422,11 @@ in
           path = lib.mkOption {
             type = lib.types.str;
           };
+
+          # Lets the generator check that the path is inside the directory agenix mounts secrets into.
+          secretsDir = lib.mkOption {
+            type = lib.types.str;
+          };
         };
 
         config = lib.optionalAttrs localEval {
@@ -191,6 +434,7 @@ in
           owner = lib.mkDefault toplevelConfig.users.users.root.name;
           group = lib.mkDefault toplevelConfig.users.users.root.group;
           path = lib.mkDefault "${toplevelConfig.age.secretsDir}/${name}";
+          secretsDir = lib.mkDefault toplevelConfig.age.secretsDir;
         };
       }));
     };
@@ -209,6 +453,15 @@ in
         })
         cfg.secrets)
 
+      (lib.mapAttrsToList
+        (secretName: secret: builtins.map
+          (dependency: {
+            assertion = mountableSecrets ? ${dependency};
+            message = "Secret ${dependency} that secret ${secretName} depends on does not exist";
+          })
+          secret.dependsOn)
+        cfg.secrets)
+
       (lib.mapAttrsToList
         (mountName: { host, secret, ... }: [
           {
@@ -216,7 +469,7 @@ in
             message = "Hostname ${host} in secret mount named ${mountName} does not exist";
           }
           {
-            assertion = globalConfig.agenix.secrets ? ${secret};
+            assertion = mountableSecrets ? ${secret};
             message = "Secret ${secret} in secret mount named ${mountName} does not exist";
           }
         ])
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"tbx.at/secrets-generator/internal"
+	"tbx.at/secrets-generator/internal/bundle"
+)
+
+// bundleMain implements the bundle subcommand, which packs the secrets mounted on a host into an archive encrypted to that host.
+func bundleMain(args []string) int {
+	flags := flag.NewFlagSet("bundle", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: secrets-generator bundle [flags] <hostname>")
+		flags.PrintDefaults()
+	}
+
+	var configPath string
+	var identityPath string
+	var outputPath string
+	var lockMemory bool
+	var logFlags logFlags
+	var storageFlags storageFlags
+
+	flags.StringVar(&configPath, "config", "-", "file containing the configuration")
+	flags.StringVar(&identityPath, "identity", "", "file containing an age identity that can decrypt all secrets")
+	flags.StringVar(&outputPath, "o", "-", "file to write the bundle to")
+	flags.BoolVar(&lockMemory, "lock-memory", false, "lock decrypted secrets into memory so that they are never swapped to disk")
+	logFlags.register(flags)
+	storageFlags.register(flags)
+
+	flags.Parse(args)
+
+	logger, err := logFlags.logger(os.Stderr)
+	if err != nil || flags.NArg() != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	hostname := flags.Arg(0)
+
+	config, err := readConfig(configPath)
+	if err != nil {
+		logger.Error("reading configuration failed", "error", err)
+		return exitConfig
+	}
+
+	secretStorage, secretEncoding, err := storageFlags.open()
+	if err != nil {
+		logger.Error("opening storage failed", "error", err)
+		return openExitCode(err)
+	}
+
+	identities, _, err := internal.ParseGeneratorKeys(identityPath)
+	if err != nil {
+		logger.Error("reading identity failed", "error", err)
+		return exitFailure
+	}
+
+	output := os.Stdout
+	if outputPath != "-" {
+		// The bundle is encrypted, but there's no reason for anyone else to read it.
+		output, err = os.OpenFile(outputPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			logger.Error("creating bundle failed", "error", err)
+			return exitFailure
+		}
+	}
+
+	secretStore := internal.NewSecretStore(secretStorage, secretEncoding, identities, internal.SecretStoreOptions{LockMemory: lockMemory})
+	defer secretStore.Wipe()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	entries, err := bundle.Create(ctx, output, config, secretStore, hostname)
+	if err == nil {
+		err = output.Close()
+	}
+
+	if err != nil {
+		if outputPath != "-" {
+			_ = output.Close()
+			_ = os.Remove(outputPath)
+		}
+
+		if ctx.Err() != nil {
+			logger.Error("bundling interrupted", "error", err)
+			return exitInterrupted
+		}
+
+		logger.Error("creating bundle failed", "error", err)
+		return exitFailure
+	}
+
+	for _, entry := range entries {
+		logger.Info("bundled secret", "mount", entry.Mount, "secret", entry.Secret, "path", entry.Path, "owner", entry.Owner, "group", entry.Group, "mode", entry.Mode)
+	}
+
+	logger.Info("created bundle", "host", hostname, "secrets", len(entries))
+	return exitSuccess
+}
+
+// unbundleMain implements the unbundle subcommand, which runs on a host and installs the secrets from a bundle.
+func unbundleMain(args []string) int {
+	flags := flag.NewFlagSet("unbundle", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: secrets-generator unbundle [flags] <bundle>")
+		flags.PrintDefaults()
+	}
+
+	var identityPath string
+	var root string
+	var logFlags logFlags
+
+	flags.StringVar(&identityPath, "identity", "", "file containing the age identity or SSH private key of the host")
+	flags.StringVar(&root, "root", "/", "directory that the paths of the secrets are relative to")
+	logFlags.register(flags)
+
+	flags.Parse(args)
+
+	logger, err := logFlags.logger(os.Stderr)
+	if err != nil || flags.NArg() != 1 || identityPath == "" {
+		flags.Usage()
+		return exitUsage
+	}
+
+	identities, err := bundle.ParseIdentities(identityPath)
+	if err != nil {
+		logger.Error("reading identity failed", "error", err)
+		return exitFailure
+	}
+
+	input := os.Stdin
+	if flags.Arg(0) != "-" {
+		f, err := os.Open(flags.Arg(0))
+		if err != nil {
+			logger.Error("reading bundle failed", "error", err)
+			return exitFailure
+		}
+		defer f.Close()
+		input = f
+	}
+
+	installed, err := bundle.Install(input, identities, root)
+	for _, path := range installed {
+		logger.Info("installed secret", "path", path)
+	}
+
+	if err != nil {
+		logger.Error("installing bundle failed", "error", err)
+		return exitFailure
+	}
+
+	return exitSuccess
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"tbx.at/secrets-generator/internal/lint"
+)
+
+// lintMain implements the lint subcommand, which checks the configuration without an identity.
+// It prints every problem found and returns a non-zero exit code if there are any.
+func lintMain(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+
+	var configPath string
+	flags.StringVar(&configPath, "config", "-", "file containing the configuration")
+
+	flags.Parse(args)
+
+	config, err := readConfig(configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfig
+	}
+
+	problems := lint.Lint(config)
+	for _, problem := range problems {
+		fmt.Fprintln(os.Stderr, problem)
+	}
+
+	if len(problems) > 0 {
+		return exitFailure
+	}
+
+	return exitSuccess
+}
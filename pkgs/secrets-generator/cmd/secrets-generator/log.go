@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// logFlags holds the flags that configure logging.
+type logFlags struct {
+	level  slog.Level
+	format string
+}
+
+func (f *logFlags) register(flags *flag.FlagSet) {
+	flags.TextVar(&f.level, "log-level", slog.LevelInfo, "minimum level of log messages (debug, info, warn or error)")
+	flags.StringVar(&f.format, "log-format", "text", "format of log messages (text or json)")
+}
+
+// logger returns a logger writing to w as configured by the flags.
+func (f *logFlags) logger(w io.Writer) (*slog.Logger, error) {
+	options := &slog.HandlerOptions{Level: f.level}
+
+	switch f.format {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format: %s", f.format)
+	}
+}
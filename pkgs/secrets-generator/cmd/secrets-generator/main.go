@@ -2,8 +2,8 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"flag"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
@@ -12,33 +12,128 @@ import (
 	"tbx.at/secrets-generator/internal/generate"
 )
 
+// Exit codes of the program.
+// Invalid flags exit with exitUsage through the flag package.
+const (
+	exitSuccess     = 0
+	exitFailure     = 1
+	exitUsage       = 2
+	exitConfig      = 3
+	exitInterrupted = 130
+)
+
 func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// run runs the subcommand given in args and returns the exit code.
+// Without a subcommand, secrets are generated.
+func run(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "bundle":
+			return bundleMain(args[1:])
+		case "lint":
+			return lintMain(args[1:])
+		case "mounts":
+			return mountsMain(args[1:])
+		case "schema":
+			return schemaMain(args[1:])
+		case "rehost":
+			return rehostMain(args[1:])
+		case "rotate-identity":
+			return rotateIdentityMain(args[1:])
+		case "set":
+			return setMain(args[1:])
+		case "unbundle":
+			return unbundleMain(args[1:])
+		case "watch":
+			return watchMain(args[1:])
+		}
+	}
+
+	return generateMain(args)
+}
+
+func generateMain(args []string) int {
+	flags := flag.NewFlagSet("secrets-generator", flag.ExitOnError)
+
 	var configPath string
-	var identityPath string
+	var explain bool
+	var logFlags logFlags
+	var storageFlags storageFlags
+	var options generate.Options
 
-	flag.StringVar(&configPath, "config", "-", "file containing the configuration")
-	flag.StringVar(&identityPath, "identity", "", "file containing an age identity that can decrypt all secrets")
+	flags.StringVar(&configPath, "config", "-", "file containing the configuration")
+	flags.BoolVar(&explain, "explain", false, "log why each secret is generated")
+	flags.StringVar(&options.IdentityPath, "identity", "", "file containing an age identity that can decrypt all secrets")
+	flags.Func("rotate", "generate the named secret again with fresh entropy (can be repeated)", func(secretName string) error {
+		options.Rotate = append(options.Rotate, secretName)
+		return nil
+	})
+	flags.BoolVar(&options.StrictDependencies, "strict-dependencies", false, "fail if a secret reads a secret that it doesn't depend on")
+	flags.BoolVar(&options.LockMemory, "lock-memory", false, "lock decrypted secrets into memory so that they are never swapped to disk")
+	logFlags.register(flags)
+	storageFlags.register(flags)
 
-	flag.Parse()
+	flags.Parse(args)
 
-	configFile := os.Stdin
-	if configPath != "-" {
-		f, err := os.Open(configPath)
-		if err != nil {
-			panic(err)
+	logger, err := logFlags.logger(os.Stderr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		flags.Usage()
+		return exitUsage
+	}
+
+	options.Logger = logger
+
+	options.Storage, options.Encoding, err = storageFlags.open()
+	if err != nil {
+		logger.Error("opening storage failed", "error", err)
+		return openExitCode(err)
+	}
+
+	if explain {
+		options.Explain = func(explanation generate.Explanation) {
+			logger.Info("explanation", "secret", explanation.Secret, "reason", explanation.Reason, "details", explanation.Details)
 		}
-		defer f.Close()
-		configFile = f
 	}
 
-	var config internal.Config
-	if err := json.NewDecoder(configFile).Decode(&config); err != nil {
-		panic(err)
+	config, err := readConfig(configPath)
+	if err != nil {
+		logger.Error("reading configuration failed", "error", err)
+		return exitConfig
 	}
 
-	ctx, _ := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := generate.Run(ctx, config, options); err != nil {
+		if ctx.Err() != nil {
+			logger.Error("generation interrupted", "error", err)
+			return exitInterrupted
+		}
 
-	if err := generate.Run(ctx, identityPath, config); err != nil {
-		panic(err)
+		logger.Error("generation failed", "error", err)
+		return exitFailure
 	}
+
+	logger.Info("generation finished", "secrets", len(config.Secrets))
+	return exitSuccess
+}
+
+// readConfig reads the configuration from the file at path or from stdin if path is "-".
+// Unknown fields in the configuration are an error.
+func readConfig(path string) (internal.Config, error) {
+	configFile := os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return internal.Config{}, err
+		}
+		defer f.Close()
+		configFile = f
+	}
+
+	return internal.DecodeConfig(configFile)
 }
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"tbx.at/secrets-generator/internal"
+)
+
+// mountsMain implements the mounts subcommand, which prints a table of the secret mounts and their metadata.
+func mountsMain(args []string) int {
+	flags := flag.NewFlagSet("mounts", flag.ExitOnError)
+
+	var configPath string
+	var hostname string
+
+	flags.StringVar(&configPath, "config", "-", "file containing the configuration")
+	flags.StringVar(&hostname, "host", "", "only print the mounts of this host")
+
+	flags.Parse(args)
+
+	config, err := readConfig(configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfig
+	}
+
+	mountNames := make([]string, 0, len(config.SecretMounts))
+	for mountName, mount := range config.SecretMounts {
+		if hostname == "" || mount.Host == hostname {
+			mountNames = append(mountNames, mountName)
+		}
+	}
+	slices.Sort(mountNames)
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(table, "MOUNT\tHOST\tSECRET\tPATH\tOWNER\tGROUP\tMODE")
+	for _, mountName := range mountNames {
+		mount := config.SecretMounts[mountName]
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", mountName, mount.Host, mount.Secret, mount.Path, mount.Owner, mount.Group, mount.Mode)
+	}
+
+	if err := table.Flush(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	// Problems with the metadata are reported, but the table is still useful.
+	problems := internal.CheckMounts(config.SecretMounts)
+	for _, problem := range problems {
+		fmt.Fprintln(os.Stderr, problem)
+	}
+
+	if len(problems) > 0 {
+		return exitFailure
+	}
+
+	return exitSuccess
+}
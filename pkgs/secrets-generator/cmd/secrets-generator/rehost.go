@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"tbx.at/secrets-generator/internal/generate"
+)
+
+// rehostMain implements the rehost subcommand, which encrypts the secrets mounted on a host to its current keys.
+// It prints a summary of the secrets it rehosted to stdout.
+func rehostMain(args []string) int {
+	flags := flag.NewFlagSet("rehost", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: secrets-generator rehost [flags] <hostname>")
+		flags.PrintDefaults()
+	}
+
+	var configPath string
+	var logFlags logFlags
+	var storageFlags storageFlags
+	var options generate.Options
+
+	flags.StringVar(&configPath, "config", "-", "file containing the configuration")
+	flags.StringVar(&options.IdentityPath, "identity", "", "file containing an age identity that can decrypt all secrets")
+	logFlags.register(flags)
+	storageFlags.register(flags)
+
+	flags.Parse(args)
+
+	logger, err := logFlags.logger(os.Stderr)
+	if err != nil || flags.NArg() != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	options.Logger = logger
+
+	options.Storage, options.Encoding, err = storageFlags.open()
+	if err != nil {
+		logger.Error("opening storage failed", "error", err)
+		return openExitCode(err)
+	}
+	hostname := flags.Arg(0)
+
+	config, err := readConfig(configPath)
+	if err != nil {
+		logger.Error("reading configuration failed", "error", err)
+		return exitConfig
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	summary, err := generate.Rehost(ctx, config, options, hostname)
+	if err != nil {
+		if ctx.Err() != nil {
+			logger.Error("rehosting interrupted", "error", err)
+			return exitInterrupted
+		}
+
+		logger.Error("rehosting failed", "error", err)
+		return exitFailure
+	}
+
+	fmt.Printf("Rehosted %d secret(s) for host %s\n", len(summary.Rehosted), hostname)
+	for _, secretName := range summary.Rehosted {
+		fmt.Printf("  %s\n", secretName)
+	}
+
+	if len(summary.Missing) > 0 {
+		fmt.Printf("Skipped %d secret(s) that don't exist yet\n", len(summary.Missing))
+		for _, secretName := range summary.Missing {
+			fmt.Printf("  %s\n", secretName)
+		}
+	}
+
+	return exitSuccess
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"tbx.at/secrets-generator/internal/generate"
+)
+
+// rotateIdentityMain implements the rotate-identity subcommand, which moves all secrets to a new generator identity.
+// It can be run again to resume after an interruption.
+func rotateIdentityMain(args []string) int {
+	flags := flag.NewFlagSet("rotate-identity", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: secrets-generator rotate-identity [flags]")
+		flags.PrintDefaults()
+	}
+
+	var configPath string
+	var newIdentityPath string
+	var logFlags logFlags
+	var storageFlags storageFlags
+	var options generate.Options
+
+	flags.StringVar(&configPath, "config", "-", "file containing the configuration")
+	flags.StringVar(&options.IdentityPath, "old", "", "file containing the age identity that can currently decrypt all secrets")
+	flags.StringVar(&newIdentityPath, "new", "", "file containing the age identity to encrypt all secrets to instead")
+	logFlags.register(flags)
+	storageFlags.register(flags)
+
+	flags.Parse(args)
+
+	logger, err := logFlags.logger(os.Stderr)
+	if err != nil || flags.NArg() != 0 || options.IdentityPath == "" || newIdentityPath == "" {
+		flags.Usage()
+		return exitUsage
+	}
+
+	options.Logger = logger
+
+	options.Storage, options.Encoding, err = storageFlags.open()
+	if err != nil {
+		logger.Error("opening storage failed", "error", err)
+		return openExitCode(err)
+	}
+
+	config, err := readConfig(configPath)
+	if err != nil {
+		logger.Error("reading configuration failed", "error", err)
+		return exitConfig
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := generate.RotateIdentity(ctx, config, options, newIdentityPath); err != nil {
+		if ctx.Err() != nil {
+			logger.Error("rotation interrupted", "error", err)
+			return exitInterrupted
+		}
+
+		logger.Error("rotation failed", "error", err)
+		return exitFailure
+	}
+
+	return exitSuccess
+}
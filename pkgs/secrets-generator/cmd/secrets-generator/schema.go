@@ -0,0 +1,25 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"tbx.at/secrets-generator/internal/schema"
+)
+
+// schemaMain implements the schema subcommand, which prints the JSON Schema for the configuration.
+func schemaMain(args []string) int {
+	flags := flag.NewFlagSet("schema", flag.ExitOnError)
+	flags.Parse(args)
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(schema.Config()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	return exitSuccess
+}
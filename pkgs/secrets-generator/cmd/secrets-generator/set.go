@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/term"
+	"tbx.at/secrets-generator/internal/generate"
+)
+
+// setMain implements the set subcommand, which stores a manually provided secret.
+// The value is prompted for without echo if stdin is a terminal and read from stdin otherwise.
+// One trailing newline is removed from stdin, like from the output of import commands, unless -keep-newline is given.
+func setMain(args []string) int {
+	flags := flag.NewFlagSet("set", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: secrets-generator set [flags] <secret>")
+		flags.PrintDefaults()
+	}
+
+	var configPath string
+	var keepNewline bool
+	var logFlags logFlags
+	var storageFlags storageFlags
+	var options generate.Options
+
+	flags.StringVar(&configPath, "config", "", "file containing the configuration")
+	flags.StringVar(&options.IdentityPath, "identity", "", "file containing an age identity that can decrypt all secrets")
+	flags.BoolVar(&keepNewline, "keep-newline", false, "keep a trailing newline of a value read from stdin")
+	logFlags.register(flags)
+	storageFlags.register(flags)
+
+	flags.Parse(args)
+
+	logger, err := logFlags.logger(os.Stderr)
+	if err != nil || flags.NArg() != 1 || configPath == "" || configPath == "-" {
+		// The configuration can't be read from stdin because stdin may hold the value.
+		flags.Usage()
+		return exitUsage
+	}
+
+	options.Logger = logger
+
+	options.Storage, options.Encoding, err = storageFlags.open()
+	if err != nil {
+		logger.Error("opening storage failed", "error", err)
+		return openExitCode(err)
+	}
+	secretName := flags.Arg(0)
+
+	config, err := readConfig(configPath)
+	if err != nil {
+		logger.Error("reading configuration failed", "error", err)
+		return exitConfig
+	}
+
+	value, err := readValue(secretName, keepNewline)
+	if err != nil {
+		logger.Error("reading secret failed", "error", err)
+		return exitFailure
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := generate.Set(ctx, config, options, secretName, value); err != nil {
+		logger.Error("setting secret failed", "error", err)
+		return exitFailure
+	}
+
+	return exitSuccess
+}
+
+func readValue(secretName string, keepNewline bool) ([]byte, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return readPipedValue(os.Stdin, keepNewline)
+	}
+
+	fmt.Fprintf(os.Stderr, "Value for %s: ", secretName)
+	defer fmt.Fprintln(os.Stderr)
+
+	return term.ReadPassword(fd)
+}
+
+// readPipedValue reads a value from r and removes one trailing newline unless keepNewline is set.
+func readPipedValue(r io.Reader, keepNewline bool) ([]byte, error) {
+	value, err := io.ReadAll(r)
+	if err != nil || keepNewline {
+		return value, err
+	}
+
+	return bytes.TrimSuffix(value, []byte("\n")), nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadPipedValue(t *testing.T) {
+	for _, tt := range []struct {
+		input       string
+		keepNewline bool
+		expected    string
+	}{
+		{input: "secret\n", expected: "secret"},
+		{input: "secret\n\n", expected: "secret\n"},
+		{input: "secret", expected: "secret"},
+		{input: "", expected: ""},
+		{input: "secret\n", keepNewline: true, expected: "secret\n"},
+	} {
+		value, err := readPipedValue(strings.NewReader(tt.input), tt.keepNewline)
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, string(value), "input %q", tt.input)
+	}
+}
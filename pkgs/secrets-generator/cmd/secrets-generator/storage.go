@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"tbx.at/secrets-generator/internal"
+	"tbx.at/secrets-generator/internal/sops"
+	"tbx.at/secrets-generator/internal/storage"
+)
+
+var errUnknownFormat = errors.New("unknown secret file format")
+
+// storageFlags holds the flags that select where secret and entropy files are stored and what format secret files have.
+type storageFlags struct {
+	location string
+	format   string
+}
+
+func (f *storageFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&f.location, "storage", internal.SecretsDirectory, "directory or URL like s3://bucket/prefix?endpoint=https://host to store secrets in")
+	flags.StringVar(&f.format, "format", "age", "format of secret files (age, sops-json or sops-yaml)")
+}
+
+// open opens the storage selected by the flags and returns the encoding of the secret files in it.
+func (f *storageFlags) open() (storage.Storage, internal.SecretEncoding, error) {
+	var encoding internal.SecretEncoding
+
+	switch f.format {
+	case "age":
+		encoding = internal.AgeEncoding{}
+	case "sops-json":
+		encoding = sops.Encoding{Format: sops.FormatJSON}
+	case "sops-yaml":
+		encoding = sops.Encoding{Format: sops.FormatYAML}
+	default:
+		return nil, nil, fmt.Errorf("%w: %s", errUnknownFormat, f.format)
+	}
+
+	secretStorage, err := storage.Open(f.location)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return secretStorage, encoding, nil
+}
+
+// openExitCode returns the exit code for an error returned by open.
+// Invalid flags are usage errors, while other errors, like failing to create the storage directory, are failures.
+func openExitCode(err error) int {
+	if errors.Is(err, errUnknownFormat) || errors.Is(err, storage.ErrInvalidLocation) {
+		return exitUsage
+	}
+
+	return exitFailure
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenExitCode(t *testing.T) {
+	for _, flags := range []storageFlags{
+		{location: "ftp://host/secrets", format: "age"},
+		{location: "s3:///prefix", format: "age"},
+		{location: t.TempDir(), format: "toml"},
+	} {
+		_, _, err := flags.open()
+		if assert.Error(t, err, flags) {
+			assert.Equal(t, exitUsage, openExitCode(err), flags)
+		}
+	}
+
+	// Errors that don't come from the flags are failures.
+	assert.Equal(t, exitFailure, openExitCode(fmt.Errorf("reading storage failed: %w", fs.ErrPermission)))
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+
+	"tbx.at/secrets-generator/internal"
+	"tbx.at/secrets-generator/internal/generate"
+	"tbx.at/secrets-generator/internal/watch"
+)
+
+// watchMain implements the watch subcommand, which generates secrets again whenever the configuration changes.
+// It prints the secrets that were generated by every run to stdout.
+func watchMain(args []string) int {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: secrets-generator watch [flags]")
+		flags.PrintDefaults()
+	}
+
+	var configPath string
+	var logFlags logFlags
+	var storageFlags storageFlags
+	var options generate.Options
+
+	flags.StringVar(&configPath, "config", "", "file containing the configuration, which can be a symlink like the result of a Nix build")
+	flags.StringVar(&options.IdentityPath, "identity", "", "file containing an age identity that can decrypt all secrets")
+	flags.BoolVar(&options.StrictDependencies, "strict-dependencies", false, "fail if a secret reads a secret that it doesn't depend on")
+	flags.BoolVar(&options.LockMemory, "lock-memory", false, "lock decrypted secrets into memory so that they are never swapped to disk")
+	logFlags.register(flags)
+	storageFlags.register(flags)
+
+	flags.Parse(args)
+
+	logger, err := logFlags.logger(os.Stderr)
+	if err != nil || flags.NArg() != 0 || configPath == "" || configPath == "-" {
+		flags.Usage()
+		return exitUsage
+	}
+
+	options.Logger = logger
+
+	options.Storage, options.Encoding, err = storageFlags.open()
+	if err != nil {
+		logger.Error("opening storage failed", "error", err)
+		return openExitCode(err)
+	}
+
+	// Decrypted secrets are kept between runs, so that unchanged dependencies aren't decrypted again.
+	options.SecretStore, err = generate.NewSecretStore(options)
+	if err != nil {
+		logger.Error("reading identity failed", "error", err)
+		return exitFailure
+	}
+	defer options.SecretStore.Wipe()
+
+	var generated []generate.Outcome
+	options.Outcome = func(outcome generate.Outcome) {
+		if outcome.Skipped == "" {
+			generated = append(generated, outcome)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// lastConfig is the configuration of the last successful run.
+	var lastConfig []byte
+
+	runGeneration := func() {
+		data, err := os.ReadFile(configPath)
+		if err != nil {
+			logger.Error("reading configuration failed", "error", err)
+			return
+		}
+
+		if bytes.Equal(data, lastConfig) {
+			logger.Debug("configuration unchanged")
+			return
+		}
+
+		config, err := internal.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			logger.Error("reading configuration failed", "error", err)
+			return
+		}
+
+		// Other programs may have changed secrets since the last run.
+		if err := options.SecretStore.Refresh(ctx); err != nil {
+			logger.Error("generation failed", "error", err)
+			return
+		}
+
+		generated = nil
+		if err := generate.Run(ctx, config, options); err != nil {
+			if ctx.Err() == nil {
+				logger.Error("generation failed", "error", err)
+			}
+			return
+		}
+
+		lastConfig = data
+
+		slices.SortFunc(generated, func(a, b generate.Outcome) int {
+			return cmp.Compare(a.Secret, b.Secret)
+		})
+		for _, outcome := range generated {
+			fmt.Printf("%s: %s\n", outcome.Secret, outcome.Explanation.Reason)
+		}
+
+		logger.Info("generation finished", "secrets", len(config.Secrets), "generated", len(generated))
+	}
+
+	runGeneration()
+
+	logger.Info("watching configuration", "path", configPath)
+
+	if err := watch.Watch(ctx, configPath, runGeneration); err != nil && !errors.Is(err, context.Canceled) {
+		logger.Error("watching configuration failed", "error", err)
+		return exitFailure
+	}
+
+	return exitSuccess
+}
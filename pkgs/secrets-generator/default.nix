@@ -2,7 +2,7 @@
 
 self.lib.buildGoModule {
   name = "secrets-generator";
-  vendorHash = "sha256-dnA3fxXOLF2jMo8yFZQ/03DtmPwuYTyvU31V6PSt/hg=";
+  vendorHash = "sha256-YqQHeTHXGCKuSy2deScoIgXiNwoU0acdW9Ivq5DtPBc=";
 
   subPackages = [ "cmd/secrets-generator" ];
 }
@@ -0,0 +1,270 @@
+// Package bundle packs the secrets mounted on a host into a single encrypted archive for offline deployment.
+// A bundle is a tar archive encrypted only to the recipients of the host.
+// Every secret mount is one file in the archive, named after the mount path and carrying its owner, group and mode.
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"tbx.at/secrets-generator/internal"
+)
+
+// Metadata used for mounts that don't specify it, matching the defaults of the Nix module.
+const (
+	DefaultOwner = "root"
+	DefaultGroup = "root"
+	DefaultMode  = "u=r,go="
+)
+
+var (
+	ErrInvalidEntry = errors.New("invalid entry in bundle")
+	ErrNoPath       = errors.New("secret mount has no path")
+	ErrUnknownHost  = errors.New("unknown host")
+)
+
+// Entry is a mounted secret in a bundle.
+type Entry struct {
+	Mount string
+	internal.SecretMount
+}
+
+// Entries returns the secret mounts of a host, sorted by the name of the mount.
+// Mounts without a path or with metadata rejected by internal.CheckMounts are an error.
+func Entries(config internal.Config, hostname string) ([]Entry, error) {
+	if _, ok := config.PublicKeys[hostname]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownHost, hostname)
+	}
+
+	mounts := make(map[string]internal.SecretMount)
+	for mountName, mount := range config.SecretMounts {
+		if mount.Host == hostname {
+			mounts[mountName] = mount
+		}
+	}
+
+	if problems := internal.CheckMounts(mounts); len(problems) > 0 {
+		return nil, errors.Join(problems...)
+	}
+
+	var entries []Entry
+	for mountName, mount := range mounts {
+		if mount.Path == "" {
+			return nil, fmt.Errorf("%w: %s", ErrNoPath, mountName)
+		}
+
+		if mount.Owner == "" {
+			mount.Owner = DefaultOwner
+		}
+		if mount.Group == "" {
+			mount.Group = DefaultGroup
+		}
+		if mount.Mode == "" {
+			mount.Mode = DefaultMode
+		}
+
+		entries = append(entries, Entry{Mount: mountName, SecretMount: mount})
+	}
+
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return strings.Compare(a.Mount, b.Mount)
+	})
+
+	return entries, nil
+}
+
+// Create writes a bundle with the secrets mounted on hostname to w.
+// The secrets are loaded from secretStore, which needs to be able to decrypt them with the identities of the generator.
+// The bundle is encrypted only to the recipients of the host.
+// It returns the entries that were written.
+func Create(ctx context.Context, w io.Writer, config internal.Config, secretStore *internal.SecretStore, hostname string) ([]Entry, error) {
+	entries, err := Entries(config, hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	recipients, err := internal.ParseRecipients(config.PublicKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := age.Encrypt(w, recipients[hostname]...)
+	if err != nil {
+		return nil, err
+	}
+
+	archive := tar.NewWriter(encrypted)
+	modTime := time.Now().UTC().Truncate(time.Second)
+
+	for _, entry := range entries {
+		mode, err := internal.ParseMode(entry.Mode)
+		if err != nil {
+			return nil, fmt.Errorf("secret mount %s: %w", entry.Mount, err)
+		}
+
+		content, err := secretStore.LoadSecret(ctx, entry.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("secret mount %s: %w", entry.Mount, err)
+		}
+
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     strings.TrimPrefix(path.Clean(entry.Path), "/"),
+			Size:     int64(len(content)),
+			Mode:     int64(mode.Perm()),
+			Uname:    entry.Owner,
+			Gname:    entry.Group,
+			ModTime:  modTime,
+			Format:   tar.FormatPAX,
+		}
+
+		if err := archive.WriteHeader(header); err != nil {
+			return nil, err
+		}
+
+		if _, err := archive.Write(content); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := encrypted.Close(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Install decrypts a bundle with the identities of the host and installs its files below root.
+// Files are replaced atomically and get the owner, group and mode recorded in the bundle.
+// It returns the paths of the installed files.
+func Install(r io.Reader, identities []age.Identity, root string) ([]string, error) {
+	decrypted, err := age.Decrypt(r, identities...)
+	if err != nil {
+		return nil, err
+	}
+
+	archive := tar.NewReader(decrypted)
+
+	var installed []string
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			return installed, nil
+		} else if err != nil {
+			return installed, err
+		}
+
+		// Bundles only ever contain regular files below the root.
+		name := path.Clean("/" + header.Name)
+		if header.Typeflag != tar.TypeReg || name == "/" || name != "/"+header.Name {
+			return installed, fmt.Errorf("%w: %s", ErrInvalidEntry, header.Name)
+		}
+
+		target := filepath.Join(root, filepath.FromSlash(name))
+		if err := installFile(target, header, archive); err != nil {
+			return installed, fmt.Errorf("while installing %s: %w", target, err)
+		}
+
+		installed = append(installed, target)
+	}
+}
+
+func installFile(target string, header *tar.Header, content io.Reader) (err error) {
+	uid, err := lookupID(user.Lookup, header.Uname, func(u *user.User) string { return u.Uid })
+	if err != nil {
+		return err
+	}
+
+	gid, err := lookupID(user.LookupGroup, header.Gname, func(g *user.Group) string { return g.Gid })
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tempFile.Close()
+			_ = os.Remove(tempFile.Name())
+		}
+	}()
+
+	// Restrict the file before the secret is written to it.
+	if err := tempFile.Chown(uid, gid); err != nil {
+		return err
+	}
+
+	if err := tempFile.Chmod(os.FileMode(header.Mode).Perm()); err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(tempFile, content); err != nil {
+		return err
+	}
+
+	if err := tempFile.Sync(); err != nil {
+		return err
+	}
+
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tempFile.Name(), target)
+}
+
+// lookupID resolves the name of a user or group to its numeric ID.
+func lookupID[T any](lookup func(string) (T, error), name string, id func(T) string) (int, error) {
+	result, err := lookup(name)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(id(result))
+}
+
+// ParseIdentities reads the identities of a host from a file.
+// The file contains either age identities or an SSH private key, like the host keys used by agenix.
+func ParseIdentities(identityPath string) ([]age.Identity, error) {
+	content, err := os.ReadFile(identityPath)
+	if err != nil {
+		return nil, err
+	}
+
+	identities, err := age.ParseIdentities(bytes.NewReader(content))
+	if err == nil {
+		return identities, nil
+	}
+
+	identity, sshErr := agessh.ParseIdentity(content)
+	if sshErr != nil {
+		return nil, err
+	}
+
+	return []age.Identity{identity}, nil
+}
@@ -0,0 +1,166 @@
+package bundle_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tbx.at/secrets-generator/internal"
+	"tbx.at/secrets-generator/internal/bundle"
+	"tbx.at/secrets-generator/internal/storage"
+)
+
+var localStorage = &storage.Local{Root: internal.SecretsDirectory}
+
+func TestBundleRoundTrip(t *testing.T) {
+	generatorIdentity := chdirWithSecrets(t, map[string]string{
+		"password":     "hunter2",
+		"config/env":   "PASSWORD=hunter2",
+		"other/secret": "not for this host",
+	})
+
+	hostIdentity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	otherIdentity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	owner, group := currentUserAndGroup(t)
+
+	config := internal.Config{
+		PublicKeys: map[string][]string{
+			"host":  {hostIdentity.Recipient().String()},
+			"other": {otherIdentity.Recipient().String()},
+		},
+		SecretMounts: map[string]internal.SecretMount{
+			"password": {Host: "host", Secret: "password", Owner: owner, Group: group, Mode: "u=r,go=", Path: "/run/agenix/password"},
+			"env":      {Host: "host", Secret: "config/env", Owner: owner, Group: group, Mode: "u=rw,g=r", Path: "/run/agenix/app/env"},
+			"other":    {Host: "other", Secret: "other/secret", Owner: owner, Group: group, Mode: "u=r,go=", Path: "/run/agenix/secret"},
+		},
+	}
+
+	var archive bytes.Buffer
+	entries, err := bundle.Create(context.Background(), &archive, config, internal.NewSecretStore(localStorage, internal.AgeEncoding{}, []age.Identity{generatorIdentity}, internal.SecretStoreOptions{}), "host")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "env", entries[0].Mount)
+	assert.Equal(t, "password", entries[1].Mount)
+
+	_, err = age.Decrypt(bytes.NewReader(archive.Bytes()), generatorIdentity)
+	assert.Error(t, err, "bundle is only encrypted to the host")
+	_, err = age.Decrypt(bytes.NewReader(archive.Bytes()), otherIdentity)
+	assert.Error(t, err, "bundle is only encrypted to the host")
+
+	root := t.TempDir()
+	installed, err := bundle.Install(bytes.NewReader(archive.Bytes()), []age.Identity{hostIdentity}, root)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(root, "run/agenix/app/env"),
+		filepath.Join(root, "run/agenix/password"),
+	}, installed)
+
+	for path, expected := range map[string]struct {
+		content string
+		mode    os.FileMode
+	}{
+		"run/agenix/password": {"hunter2", 0400},
+		"run/agenix/app/env":  {"PASSWORD=hunter2", 0640},
+	} {
+		content, err := os.ReadFile(filepath.Join(root, path))
+		require.NoError(t, err)
+		assert.Equal(t, expected.content, string(content))
+
+		info, err := os.Stat(filepath.Join(root, path))
+		require.NoError(t, err)
+		assert.Equal(t, expected.mode, info.Mode().Perm(), path)
+	}
+
+	assert.NoFileExists(t, filepath.Join(root, "run/agenix/secret"))
+}
+
+func TestBundleErrors(t *testing.T) {
+	generatorIdentity := chdirWithSecrets(t, map[string]string{"password": "hunter2"})
+
+	hostIdentity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	config := internal.Config{
+		PublicKeys: map[string][]string{"host": {hostIdentity.Recipient().String()}},
+		SecretMounts: map[string]internal.SecretMount{
+			"password": {Host: "host", Secret: "password"},
+		},
+	}
+
+	_, err = bundle.Create(context.Background(), new(bytes.Buffer), config, internal.NewSecretStore(localStorage, internal.AgeEncoding{}, []age.Identity{generatorIdentity}, internal.SecretStoreOptions{}), "unknown")
+	assert.ErrorIs(t, err, bundle.ErrUnknownHost)
+
+	_, err = bundle.Create(context.Background(), new(bytes.Buffer), config, internal.NewSecretStore(localStorage, internal.AgeEncoding{}, []age.Identity{generatorIdentity}, internal.SecretStoreOptions{}), "host")
+	assert.ErrorIs(t, err, bundle.ErrNoPath)
+
+	config.SecretMounts["password"] = internal.SecretMount{Host: "host", Secret: "password", Path: "/run/agenix/password", Mode: "u=banana"}
+	_, err = bundle.Create(context.Background(), new(bytes.Buffer), config, internal.NewSecretStore(localStorage, internal.AgeEncoding{}, []age.Identity{generatorIdentity}, internal.SecretStoreOptions{}), "host")
+	assert.ErrorIs(t, err, internal.ErrInvalidMode)
+
+	t.Run("path traversal", func(t *testing.T) {
+		var archive bytes.Buffer
+		encrypted, err := age.Encrypt(&archive, hostIdentity.Recipient())
+		require.NoError(t, err)
+
+		writer := tar.NewWriter(encrypted)
+		require.NoError(t, writer.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "../escaped", Mode: 0400}))
+		require.NoError(t, writer.Close())
+		require.NoError(t, encrypted.Close())
+
+		root := t.TempDir()
+		_, err = bundle.Install(&archive, []age.Identity{hostIdentity}, filepath.Join(root, "root"))
+		assert.ErrorIs(t, err, bundle.ErrInvalidEntry)
+		assert.NoFileExists(t, filepath.Join(root, "escaped"))
+	})
+}
+
+// chdirWithSecrets changes into a directory holding the given secrets and returns the identity of the generator.
+func chdirWithSecrets(t *testing.T, secrets map[string]string) *age.X25519Identity {
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = os.Chdir(cwd)
+	})
+
+	require.NoError(t, os.Chdir(t.TempDir()))
+
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	for secretName, content := range secrets {
+		secretFilePath := internal.SecretFilePath(secretName)
+		require.NoError(t, os.MkdirAll(filepath.Dir(secretFilePath), 0770))
+
+		var encrypted bytes.Buffer
+		writer, err := age.Encrypt(&encrypted, identity.Recipient())
+		require.NoError(t, err)
+		_, err = writer.Write([]byte(content))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+
+		require.NoError(t, os.WriteFile(secretFilePath, encrypted.Bytes(), 0660))
+	}
+
+	return identity
+}
+
+func currentUserAndGroup(t *testing.T) (string, string) {
+	current, err := user.Current()
+	require.NoError(t, err)
+
+	group, err := user.LookupGroupId(current.Gid)
+	require.NoError(t, err)
+
+	return current.Username, group.Name
+}
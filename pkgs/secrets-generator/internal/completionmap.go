@@ -36,8 +36,13 @@ func NewCompletionMap(secrets map[string]Secret) *CompletionMap {
 		cancel:     make(map[string]context.CancelFunc, len(secrets)),
 	}
 
-	for name := range secrets {
+	for name, secret := range secrets {
 		cm.completion[name], cm.cancel[name] = context.WithCancel(context.Background())
+
+		// Outputs of a secret are complete when the secret itself is complete.
+		for _, output := range secret.Outputs() {
+			cm.completion[OutputSecretName(name, output)] = cm.completion[name]
+		}
 	}
 
 	return cm
@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+var ErrUnknownField = errors.New("unknown field in configuration")
+
+// DecodeConfig decodes a configuration from r.
+// Unlike plain JSON decoding, fields that don't exist in Config are rejected along with their path, so that typos don't silently fall back to defaults.
+func DecodeConfig(r io.Reader) (config Config, err error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return config, err
+	}
+
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return config, err
+	}
+
+	if err := checkFields(reflect.TypeFor[Config](), raw, ""); err != nil {
+		return config, err
+	}
+
+	err = json.Unmarshal(data, &config)
+	return config, err
+}
+
+// checkFields checks that every object in value only has fields that exist in the Go type t.
+// path is the location of value in the configuration, like .secrets["name"].generation.
+// Values that don't match t at all are left for json.Unmarshal to report.
+func checkFields(t reflect.Type, value any, path string) error {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return checkFields(t.Elem(), value, path)
+
+	case reflect.Struct:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+
+		fields := JSONFields(t)
+		for _, key := range sortedKeys(object) {
+			field, ok := fields[key]
+			if !ok {
+				return fmt.Errorf("%w: %s.%s", ErrUnknownField, path, key)
+			}
+
+			if err := checkFields(field.Type, object[key], path+"."+key); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+
+		for _, key := range sortedKeys(object) {
+			if err := checkFields(t.Elem(), object[key], fmt.Sprintf("%s[%q]", path, key)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice:
+		list, ok := value.([]any)
+		if !ok {
+			return nil
+		}
+
+		for i, item := range list {
+			if err := checkFields(t.Elem(), item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// JSONFields returns the fields of the struct type t by their names in JSON.
+// Fields that are not encoded (tagged with "-") are left out.
+func JSONFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		fields[name] = field
+	}
+
+	return fields
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,41 @@
+package internal_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tbx.at/secrets-generator/internal"
+)
+
+func TestDecodeConfig(t *testing.T) {
+	config, err := internal.DecodeConfig(strings.NewReader(`{
+		"publicKeys": {"host": ["age1..."]},
+		"secrets": {
+			"password": {"generation": {"json": null, "random": {"charsets": {"numbers": true}, "length": 8}, "script": null, "template": null}},
+			"config": {"generation": {"json": {"content": {"a": {"anything": ["goes"]}}, "format": null}}},
+			"script": {"generation": {"script": {"program": "/bin/generate", "runtimeInputs": ["/nix/store/coreutils"], "script": "echo"}}}
+		},
+		"secretMounts": {
+			"password": {"host": "host", "secret": "password", "owner": "root", "group": "root", "mode": "u=r,go=", "path": "/run/agenix/password"}
+		}
+	}`))
+	require.NoError(t, err)
+
+	assert.Equal(t, 8, config.Secrets["password"].Generation.Random.Length)
+	assert.Equal(t, map[string]any{"a": map[string]any{"anything": []any{"goes"}}}, config.Secrets["config"].Generation.JSON.Content)
+	assert.Equal(t, "/bin/generate", config.Secrets["script"].Generation.Script.Program)
+}
+
+func TestDecodeConfigUnknownField(t *testing.T) {
+	for config, expected := range map[string]string{
+		`{"secrets": {"db/password": {"generation": {"random": {"lenght": 8}}}}}`: `.secrets["db/password"].generation.random.lenght`,
+		`{"secretMounts": {"a": {"hots": "host"}}}`:                               `.secretMounts["a"].hots`,
+		`{"secret": {}}`: `.secret`,
+	} {
+		_, err := internal.DecodeConfig(strings.NewReader(config))
+		assert.ErrorIs(t, err, internal.ErrUnknownField)
+		assert.EqualError(t, err, "unknown field in configuration: "+expected)
+	}
+}
@@ -0,0 +1,56 @@
+package internal
+
+import (
+	"bytes"
+	"io"
+
+	"filippo.io/age"
+)
+
+// SecretEncoding turns the plaintext of a secret into the file that is stored for it and back.
+type SecretEncoding interface {
+	// Extension is appended to the name of a secret to get the storage key of its file.
+	Extension() string
+
+	// Encrypt returns the file for a secret that can be decrypted by all of recipients.
+	Encrypt(plaintext []byte, recipients []age.Recipient) ([]byte, error)
+
+	// Decrypt returns the exact plaintext that was passed to Encrypt.
+	Decrypt(encrypted []byte, identities []age.Identity) ([]byte, error)
+}
+
+// AgeEncoding stores secrets as age files, which is what agenix expects.
+type AgeEncoding struct{}
+
+func (AgeEncoding) Extension() string {
+	return ".age"
+}
+
+func (AgeEncoding) Encrypt(plaintext []byte, recipients []age.Recipient) ([]byte, error) {
+	encrypted := new(bytes.Buffer)
+
+	writer, err := age.Encrypt(encrypted, recipients...)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := writer.Write(plaintext); err != nil {
+		return nil, err
+	}
+
+	// Flush the age writer before returning the encrypted secret.
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return encrypted.Bytes(), nil
+}
+
+func (AgeEncoding) Decrypt(encrypted []byte, identities []age.Identity) ([]byte, error) {
+	reader, err := age.Decrypt(bytes.NewReader(encrypted), identities...)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(reader)
+}
@@ -0,0 +1,190 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Versions of the algorithms that consume entropy.
+// The version is recorded in the entropy file so that secrets generated with an older algorithm can still be reproduced.
+const (
+	// AlgorithmLegacy is assumed for entropy files without a header.
+	AlgorithmLegacy = 1
+
+	// AlgorithmSeeded generates passwords from a fixed-size seed (see password.GeneratePassword).
+	AlgorithmSeeded = 2
+
+	AlgorithmCurrent = AlgorithmSeeded
+)
+
+// EntropyFormatVersion is the version of the container format written by WriteEntropyFile.
+const EntropyFormatVersion = 1
+
+// Entropy files come in two flavors:
+//
+//   - Files written before the header was introduced only contain the raw entropy.
+//   - Current files contain entropyMagic, one byte with the format version, the length of the JSON encoded EntropyHeader as a big endian uint32,
+//     the header itself (which records the algorithm version) and then the raw entropy.
+var entropyMagic = []byte("SGENTRPY")
+
+const entropyMaxHeaderLength = 1 << 16
+
+var (
+	ErrEntropyHeaderTooLong   = errors.New("entropy header is too long")
+	ErrUnknownAlgorithm       = errors.New("unknown entropy algorithm version")
+	ErrUnknownEntropyFormat   = errors.New("unknown entropy file format version")
+	ErrEntropyLengthIncorrect = errors.New("entropy file is shorter than recorded in its header")
+)
+
+// Entropy is a source of randomness for generators along with the algorithm version that should be used to consume it.
+type Entropy struct {
+	io.Reader
+	Algorithm int
+
+	// Header holds the metadata recorded with the entropy.
+	// It is nil for files written before metadata was recorded.
+	Header *EntropyHeader
+}
+
+// EntropyHeader holds metadata about how an entropy file came to be.
+type EntropyHeader struct {
+	FormatVersion int `json:"-"`
+
+	Algorithm  int       `json:"algorithm"`
+	Generator  string    `json:"generator"`
+	ParamsHash string    `json:"paramsHash"`
+	Timestamp  time.Time `json:"timestamp"`
+	Length     int64     `json:"length"`
+}
+
+// EntropyAlgorithm returns the algorithm version for rng.
+// Readers other than *Entropy (like crypto/rand.Reader in tests) always use the current version.
+func EntropyAlgorithm(rng io.Reader) int {
+	if entropy, ok := rng.(*Entropy); ok {
+		return entropy.Algorithm
+	}
+
+	return AlgorithmCurrent
+}
+
+// WriteEntropyFile writes an entropy file in the current format.
+// The length in the header is set from the length of entropy.
+func WriteEntropyFile(w io.Writer, header EntropyHeader, entropy []byte) error {
+	header.Length = int64(len(entropy))
+
+	encodedHeader, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	prefix := make([]byte, 0, len(entropyMagic)+5)
+	prefix = append(prefix, entropyMagic...)
+	prefix = append(prefix, EntropyFormatVersion)
+	prefix = binary.BigEndian.AppendUint32(prefix, uint32(len(encodedHeader)))
+
+	for _, data := range [][]byte{prefix, encodedHeader, entropy} {
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReadEntropyHeader reads the header from the start of an entropy file.
+// Files without a header are passed through unchanged with AlgorithmLegacy.
+func ReadEntropyHeader(r io.Reader) (*Entropy, error) {
+	prefix := make([]byte, len(entropyMagic)+1)
+
+	n, err := io.ReadFull(r, prefix)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+
+	if n < len(prefix) || !bytes.Equal(prefix[:len(entropyMagic)], entropyMagic) {
+		return &Entropy{
+			Reader:    io.MultiReader(bytes.NewReader(prefix[:n]), r),
+			Algorithm: AlgorithmLegacy,
+		}, nil
+	}
+
+	formatVersion := prefix[len(entropyMagic)]
+	if formatVersion != EntropyFormatVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownEntropyFormat, formatVersion)
+	}
+
+	var headerLength uint32
+	if err := binary.Read(r, binary.BigEndian, &headerLength); err != nil {
+		return nil, err
+	}
+
+	if headerLength > entropyMaxHeaderLength {
+		return nil, fmt.Errorf("%w: %d bytes", ErrEntropyHeaderTooLong, headerLength)
+	}
+
+	encodedHeader := make([]byte, headerLength)
+	if _, err := io.ReadFull(r, encodedHeader); err != nil {
+		return nil, err
+	}
+
+	header := &EntropyHeader{
+		FormatVersion: int(formatVersion),
+	}
+
+	if err := json.Unmarshal(encodedHeader, header); err != nil {
+		return nil, err
+	}
+
+	if err := checkAlgorithm(header.Algorithm); err != nil {
+		return nil, err
+	}
+
+	return &Entropy{
+		Reader:    &entropyLengthReader{r: io.LimitReader(r, header.Length), remaining: header.Length},
+		Algorithm: header.Algorithm,
+		Header:    header,
+	}, nil
+}
+
+// HashGenerationParams returns a hash identifying the generation parameters of a secret.
+func HashGenerationParams(params GenerationParams) (string, error) {
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(encoded)
+	return "sha256:" + hex.EncodeToString(hash[:]), nil
+}
+
+func checkAlgorithm(algorithm int) error {
+	if algorithm < AlgorithmLegacy || algorithm > AlgorithmCurrent {
+		return fmt.Errorf("%w: %d", ErrUnknownAlgorithm, algorithm)
+	}
+
+	return nil
+}
+
+// entropyLengthReader reports an error if the underlying file ends before the length recorded in the header.
+type entropyLengthReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (e *entropyLengthReader) Read(p []byte) (n int, err error) {
+	n, err = e.r.Read(p)
+	e.remaining -= int64(n)
+
+	if errors.Is(err, io.EOF) && e.remaining > 0 {
+		return n, ErrEntropyLengthIncorrect
+	}
+
+	return n, err
+}
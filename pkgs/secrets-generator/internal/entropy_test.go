@@ -0,0 +1,90 @@
+package internal_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tbx.at/secrets-generator/internal"
+)
+
+func TestEntropyFileRoundTrip(t *testing.T) {
+	entropy := []byte("some entropy")
+	timestamp := time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	file := new(bytes.Buffer)
+	require.NoError(t, internal.WriteEntropyFile(file, internal.EntropyHeader{
+		Algorithm:  internal.AlgorithmCurrent,
+		Generator:  internal.GenerationTypeRandom,
+		ParamsHash: "sha256:abc",
+		Timestamp:  timestamp,
+	}, entropy))
+
+	read, err := internal.ReadEntropyHeader(file)
+	require.NoError(t, err)
+
+	assert.Equal(t, internal.AlgorithmCurrent, read.Algorithm)
+	assert.Equal(t, &internal.EntropyHeader{
+		FormatVersion: internal.EntropyFormatVersion,
+		Algorithm:     internal.AlgorithmCurrent,
+		Generator:     internal.GenerationTypeRandom,
+		ParamsHash:    "sha256:abc",
+		Timestamp:     timestamp,
+		Length:        int64(len(entropy)),
+	}, read.Header)
+
+	readEntropy, err := io.ReadAll(read)
+	require.NoError(t, err)
+	assert.Equal(t, entropy, readEntropy)
+}
+
+func TestEntropyFileTruncated(t *testing.T) {
+	file := new(bytes.Buffer)
+	require.NoError(t, internal.WriteEntropyFile(file, internal.EntropyHeader{
+		Algorithm: internal.AlgorithmCurrent,
+	}, []byte("some entropy")))
+
+	truncated := file.Bytes()[:file.Len()-1]
+
+	read, err := internal.ReadEntropyHeader(bytes.NewReader(truncated))
+	require.NoError(t, err)
+
+	_, err = io.ReadAll(read)
+	assert.ErrorIs(t, err, internal.ErrEntropyLengthIncorrect)
+}
+
+func TestEntropyFileHeaderless(t *testing.T) {
+	for _, entropy := range [][]byte{
+		{},
+		[]byte("short"),
+		[]byte("some longer entropy without a header"),
+	} {
+		read, err := internal.ReadEntropyHeader(bytes.NewReader(entropy))
+		require.NoError(t, err)
+
+		assert.Equal(t, internal.AlgorithmLegacy, read.Algorithm)
+		assert.Nil(t, read.Header)
+
+		readEntropy, err := io.ReadAll(read)
+		require.NoError(t, err)
+		assert.Equal(t, entropy, readEntropy)
+	}
+}
+
+func TestEntropyFileUnknownVersions(t *testing.T) {
+	_, err := internal.ReadEntropyHeader(bytes.NewReader(append([]byte("SGENTRPY"), 0xff)))
+	assert.ErrorIs(t, err, internal.ErrUnknownEntropyFormat)
+}
+
+func TestEntropyFileUnknownAlgorithm(t *testing.T) {
+	file := new(bytes.Buffer)
+	require.NoError(t, internal.WriteEntropyFile(file, internal.EntropyHeader{
+		Algorithm: internal.AlgorithmCurrent + 1,
+	}, []byte("some entropy")))
+
+	_, err := internal.ReadEntropyHeader(file)
+	assert.ErrorIs(t, err, internal.ErrUnknownAlgorithm)
+}
@@ -0,0 +1,138 @@
+package generate
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"tbx.at/secrets-generator/internal"
+	"tbx.at/secrets-generator/internal/generator/json"
+	"tbx.at/secrets-generator/internal/generator/template"
+)
+
+var (
+	ErrDependencyCycle   = errors.New("secrets depend on each other")
+	ErrUnknownDependency = errors.New("dependency is not a secret")
+)
+
+// Dependencies returns the secrets that each secret in config depends on, sorted by name.
+// A secret depends on the secrets in its dependsOn list and on the secrets its generation parameters read by name, as far as those names are known without generating it.
+// Depending on an output of a secret means depending on the secret itself.
+//
+// Names in dependsOn must be secrets or outputs.
+// Unknown names in generation parameters are left out, since reading them fails anyway.
+func Dependencies(config internal.Config) (map[string][]string, error) {
+	owners := make(map[string]string)
+	for secretName, secret := range config.Secrets {
+		for _, name := range internal.StoredSecretNames(secretName, secret) {
+			owners[name] = secretName
+		}
+	}
+
+	dependencies := make(map[string][]string, len(config.Secrets))
+	for secretName, secret := range config.Secrets {
+		var secretDependencies []string
+
+		for _, name := range secret.DependsOn {
+			owner, ok := owners[name]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s depends on %s", ErrUnknownDependency, secretName, name)
+			}
+
+			secretDependencies = append(secretDependencies, owner)
+		}
+
+		for _, name := range readSecrets(secretName, secret) {
+			if owner, ok := owners[name]; ok {
+				secretDependencies = append(secretDependencies, owner)
+			}
+		}
+
+		slices.Sort(secretDependencies)
+		dependencies[secretName] = slices.Compact(secretDependencies)
+	}
+
+	if cycle := findCycle(dependencies); cycle != nil {
+		return nil, fmt.Errorf("%w: %s", ErrDependencyCycle, strings.Join(cycle, " -> "))
+	}
+
+	return dependencies, nil
+}
+
+// readSecrets returns the names of the secrets that the generation parameters of a secret read by name.
+func readSecrets(secretName string, secret internal.Secret) []string {
+	switch {
+	case secret.Generation.JSON != nil:
+		return json.ReadSecrets(*secret.Generation.JSON)
+	case secret.Generation.Template != nil:
+		return template.ReadSecrets(secretName, *secret.Generation.Template)
+	default:
+		return nil
+	}
+}
+
+// findCycle returns the secrets along a cycle in dependencies, starting and ending with the same secret, or nil if there is no cycle.
+func findCycle(dependencies map[string][]string) []string {
+	const (
+		visiting = iota + 1
+		visited
+	)
+
+	state := make(map[string]int, len(dependencies))
+	var path []string
+
+	var visit func(secretName string) []string
+	visit = func(secretName string) []string {
+		switch state[secretName] {
+		case visiting:
+			return append(slices.Clone(path[slices.Index(path, secretName):]), secretName)
+		case visited:
+			return nil
+		}
+
+		state[secretName] = visiting
+		path = append(path, secretName)
+
+		for _, dependency := range dependencies[secretName] {
+			if cycle := visit(dependency); cycle != nil {
+				return cycle
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[secretName] = visited
+
+		return nil
+	}
+
+	secretNames := make([]string, 0, len(dependencies))
+	for secretName := range dependencies {
+		secretNames = append(secretNames, secretName)
+	}
+	slices.Sort(secretNames)
+
+	for _, secretName := range secretNames {
+		if cycle := visit(secretName); cycle != nil {
+			return cycle
+		}
+	}
+
+	return nil
+}
+
+// allowedReads returns the names of the stored secrets that each secret may read according to dependencies.
+func allowedReads(config internal.Config, dependencies map[string][]string) map[string]map[string]bool {
+	allowed := make(map[string]map[string]bool, len(dependencies))
+	for secretName, secretDependencies := range dependencies {
+		allowed[secretName] = make(map[string]bool)
+
+		for _, dependency := range secretDependencies {
+			for _, name := range internal.StoredSecretNames(dependency, config.Secrets[dependency]) {
+				allowed[secretName][name] = true
+			}
+		}
+	}
+
+	return allowed
+}
@@ -1,12 +1,17 @@
 package generate_test
 
 import (
+	"context"
+	"errors"
 	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"tbx.at/secrets-generator/internal"
+	"tbx.at/secrets-generator/internal/generate"
+	"tbx.at/secrets-generator/internal/generator/functions"
+	"tbx.at/secrets-generator/internal/testutil"
 )
 
 const regexDependenciesHash = "hash: " + RegexBcryptHash
@@ -80,3 +85,255 @@ func TestDependenciesGenerate(t *testing.T) {
 
 	assert.Regexp(t, regexDependenciesHash, hashSecretChanged)
 }
+
+func TestDependenciesStructured(t *testing.T) {
+	testbed := InitializeTest(t)
+
+	credentialsSecretName := testbed.GenerateSecretName()
+	templateSecretName := testbed.GenerateSecretName()
+	jsonSecretName := testbed.GenerateSecretName()
+
+	config := internal.Config{
+		PublicKeys: testbed.PublicKeys,
+
+		Secrets: map[string]internal.Secret{
+			credentialsSecretName: {
+				Generation: internal.GenerationParams{
+					JSON: &internal.GenerationParamsJSON{
+						Content: map[string]any{
+							"users": []any{
+								map[string]any{
+									"name":     "prometheus",
+									"password": "hunter2",
+								},
+							},
+						},
+					},
+				},
+			},
+			templateSecretName: {
+				Generation: internal.GenerationParams{
+					Template: &internal.GenerationParamsTemplate{
+						Data: map[string]any{
+							"CredentialsSecret": credentialsSecretName,
+						},
+						Content: `{{ $user := index (readSecretJSON .CredentialsSecret).users 0 }}{{ $user.name }}:{{ readSecretField .CredentialsSecret "users[0].password" }}`,
+					},
+				},
+			},
+			jsonSecretName: {
+				Generation: internal.GenerationParams{
+					JSON: &internal.GenerationParamsJSON{
+						Content: testutil.JSONFunctionCall("readSecret", map[string]any{
+							"name": credentialsSecretName,
+							"path": "users[0]",
+						}),
+					},
+				},
+			},
+		},
+
+		SecretMounts: RandomMounts(map[string]int{
+			credentialsSecretName: 1,
+			templateSecretName:    1,
+			jsonSecretName:        1,
+		}),
+	}
+
+	testbed.RunGenerator(t, config)
+
+	templateSecret := testbed.ReadSecret(t, testbed.IdentitiesForSecret(config.SecretMounts, templateSecretName), templateSecretName)
+	assert.Equal(t, "prometheus:hunter2", templateSecret)
+
+	jsonSecret := testbed.ReadSecret(t, testbed.IdentitiesForSecret(config.SecretMounts, jsonSecretName), jsonSecretName)
+	assert.JSONEq(t, `{"name":"prometheus","password":"hunter2"}`, jsonSecret)
+}
+
+func TestDependenciesLockMemory(t *testing.T) {
+	testbed := InitializeTest(t)
+
+	passwordSecretName := testbed.GenerateSecretName()
+	templateSecretName := testbed.GenerateSecretName()
+
+	config := internal.Config{
+		PublicKeys: testbed.PublicKeys,
+
+		Secrets: map[string]internal.Secret{
+			passwordSecretName: {
+				Generation: internal.GenerationParams{
+					Random: &internal.GenerationParamsRandom{
+						Length:   16,
+						Charsets: RandomCharsets(),
+					},
+				},
+			},
+			templateSecretName: {
+				Generation: internal.GenerationParams{
+					Template: &internal.GenerationParamsTemplate{
+						Data: map[string]any{
+							"PasswordSecret": passwordSecretName,
+						},
+						Content: `password={{ printf "%s" (readSecret .PasswordSecret) }}`,
+					},
+				},
+			},
+		},
+
+		SecretMounts: RandomMounts(map[string]int{
+			passwordSecretName: 1,
+			templateSecretName: 1,
+		}),
+	}
+
+	options := generate.Options{IdentityPath: IdentityFileName, LockMemory: true}
+
+	err := generate.Run(context.Background(), config, options)
+	if errors.Is(err, internal.ErrLockMemoryUnsupported) {
+		t.Skip(err)
+	}
+	require.NoError(t, err)
+
+	// The second run decrypts both secrets into locked memory to compare them.
+	testbed.RunGeneratorWithOptions(t, config, options)
+
+	password := testbed.ReadSecret(t, testbed.IdentitiesForSecret(config.SecretMounts, passwordSecretName), passwordSecretName)
+	templateSecret := testbed.ReadSecret(t, testbed.IdentitiesForSecret(config.SecretMounts, templateSecretName), templateSecretName)
+	assert.Equal(t, "password="+password, templateSecret)
+}
+
+func TestDependencies(t *testing.T) {
+	config := internal.Config{
+		Secrets: map[string]internal.Secret{
+			"password": {},
+			"database": {
+				Generation: internal.GenerationParams{
+					JSON: &internal.GenerationParamsJSON{
+						Outputs: map[string]internal.GenerationParamsJSONOutput{
+							"url":  {Content: "postgres://"},
+							"user": {Content: "app"},
+						},
+					},
+				},
+			},
+			"literal": {
+				Generation: internal.GenerationParams{
+					Template: &internal.GenerationParamsTemplate{
+						Content: `{{ readSecret "password" }}`,
+					},
+				},
+			},
+			"data": {
+				Generation: internal.GenerationParams{
+					Template: &internal.GenerationParamsTemplate{
+						Data: map[string]any{
+							"Secrets": map[string]any{"Database": "database/url"},
+						},
+						Content: `{{ with .Secrets }}{{ readSecret "missing" }}{{ end }}{{ readSecretField .Secrets.Database "host" }}`,
+					},
+				},
+			},
+			"json": {
+				Generation: internal.GenerationParams{
+					JSON: &internal.GenerationParamsJSON{
+						Content: map[string]any{
+							"password": testutil.JSONFunctionCall("readSecret", map[string]any{"name": "password"}),
+							"user":     testutil.JSONFunctionCall("readSecret", map[string]any{"name": testutil.JSONFunctionCall("readSecret", map[string]any{"name": "literal"})}),
+						},
+					},
+				},
+			},
+			"declared": {
+				Generation: internal.GenerationParams{
+					Template: &internal.GenerationParamsTemplate{
+						Data:    map[string]any{"Names": []any{"password", "database/user"}},
+						Content: `{{ range .Names }}{{ readSecret . }}{{ end }}`,
+					},
+				},
+				DependsOn: []string{"database/user", "password"},
+			},
+		},
+	}
+
+	dependencies, err := generate.Dependencies(config)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string][]string{
+		"password": nil,
+		"database": nil,
+		"literal":  {"password"},
+		"data":     {"database"},
+		"json":     {"literal", "password"},
+		"declared": {"database", "password"},
+	}, dependencies)
+}
+
+func TestDependenciesInvalid(t *testing.T) {
+	config := internal.Config{
+		Secrets: map[string]internal.Secret{
+			"a": {DependsOn: []string{"b"}},
+			"b": {DependsOn: []string{"c"}},
+			"c": {DependsOn: []string{"a"}},
+		},
+	}
+
+	_, err := generate.Dependencies(config)
+	assert.ErrorIs(t, err, generate.ErrDependencyCycle)
+	assert.EqualError(t, err, "secrets depend on each other: a -> b -> c -> a")
+
+	config.Secrets["c"] = internal.Secret{DependsOn: []string{"d"}}
+
+	_, err = generate.Dependencies(config)
+	assert.ErrorIs(t, err, generate.ErrUnknownDependency)
+}
+
+func TestDependenciesStrict(t *testing.T) {
+	testbed := InitializeTest(t)
+
+	passwordSecretName := testbed.GenerateSecretName()
+	templateSecretName := testbed.GenerateSecretName()
+
+	template := internal.Secret{
+		Generation: internal.GenerationParams{
+			Template: &internal.GenerationParamsTemplate{
+				Data:    map[string]any{"Names": []any{passwordSecretName}},
+				Content: `{{ range .Names }}{{ printf "%s" (readSecret .) }}{{ end }}`,
+			},
+		},
+	}
+
+	config := internal.Config{
+		PublicKeys: testbed.PublicKeys,
+
+		Secrets: map[string]internal.Secret{
+			passwordSecretName: {
+				Generation: internal.GenerationParams{
+					Random: &internal.GenerationParamsRandom{
+						Length:   16,
+						Charsets: RandomCharsets(),
+					},
+				},
+			},
+			templateSecretName: template,
+		},
+
+		SecretMounts: RandomMounts(map[string]int{
+			passwordSecretName: 1,
+			templateSecretName: 1,
+		}),
+	}
+
+	options := generate.Options{IdentityPath: IdentityFileName, StrictDependencies: true}
+
+	// The name of the password comes from a range, so it can't be found without executing the template.
+	err := generate.Run(context.Background(), config, options)
+	assert.ErrorIs(t, err, functions.ErrUndeclaredDependency)
+
+	template.DependsOn = []string{passwordSecretName}
+	config.Secrets[templateSecretName] = template
+
+	testbed.RunGeneratorWithOptions(t, config, options)
+
+	password := testbed.ReadSecret(t, testbed.IdentitiesForSecret(config.SecretMounts, passwordSecretName), passwordSecretName)
+	templateSecret := testbed.ReadSecret(t, testbed.IdentitiesForSecret(config.SecretMounts, templateSecretName), templateSecretName)
+	assert.Equal(t, password, templateSecret)
+}
@@ -0,0 +1,160 @@
+package generate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Reason is the cause for generating a secret.
+type Reason string
+
+const (
+	ReasonEntropyMissing      Reason = "entropy file missing"
+	ReasonEntropyExhausted    Reason = "entropy exhausted"
+	ReasonGenerationFailed    Reason = "generation with recorded entropy failed"
+	ReasonSecretMissing       Reason = "secret file missing"
+	ReasonSecretUndecryptable Reason = "secret undecryptable"
+	ReasonContentDiffers      Reason = "content differs"
+	ReasonRotated             Reason = "forced rotation"
+)
+
+// Explanation says why a secret is generated.
+type Explanation struct {
+	Secret string
+	Reason Reason
+
+	// Details holds additional information like the parts of JSON content that changed.
+	// Details never contain the values of secrets.
+	Details []string
+}
+
+func (e Explanation) String() string {
+	if len(e.Details) == 0 {
+		return fmt.Sprintf("generating secret %s: %s", e.Secret, e.Reason)
+	}
+
+	return fmt.Sprintf("generating secret %s: %s: %s", e.Secret, e.Reason, strings.Join(e.Details, ", "))
+}
+
+// SkipReason is the cause for leaving a secret as it is.
+type SkipReason string
+
+const (
+	SkipNotGenerated SkipReason = "not generated"
+	SkipUnchanged    SkipReason = "unchanged"
+	SkipExists       SkipReason = "exists"
+)
+
+// Outcome says what happened to a secret during a run.
+type Outcome struct {
+	Secret string
+
+	// Skipped is the reason why the secret was left as it is.
+	// It is empty if the secret was generated.
+	Skipped SkipReason
+
+	// Explanation says why the secret was generated.
+	// It is empty if the secret was skipped.
+	Explanation Explanation
+}
+
+// exhaustionReader records whether the underlying reader ran out of data.
+// A generator reading more entropy than was recorded means that the secret needs more randomness than last time.
+type exhaustionReader struct {
+	r         io.Reader
+	exhausted bool
+}
+
+func (e *exhaustionReader) Read(p []byte) (n int, err error) {
+	n, err = e.r.Read(p)
+	if err == io.EOF {
+		e.exhausted = true
+	}
+
+	return n, err
+}
+
+// structureDiff describes where two JSON documents differ without revealing any of their values.
+// It returns nil if either of them isn't valid JSON.
+func structureDiff(old, new []byte) []string {
+	var oldValue, newValue any
+	if json.Unmarshal(old, &oldValue) != nil || json.Unmarshal(new, &newValue) != nil {
+		return nil
+	}
+
+	var diff []string
+	diffValues(&diff, "", oldValue, newValue)
+	return diff
+}
+
+func diffValues(diff *[]string, path string, old, new any) {
+	switch oldCast := old.(type) {
+	case map[string]any:
+		newCast, ok := new.(map[string]any)
+		if !ok {
+			break
+		}
+
+		keys := make([]string, 0, len(oldCast)+len(newCast))
+		for key := range oldCast {
+			keys = append(keys, key)
+		}
+		for key := range newCast {
+			if _, ok := oldCast[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			diffMember(diff, path+"."+key, oldCast, newCast, key)
+		}
+		return
+
+	case []any:
+		newCast, ok := new.([]any)
+		if !ok {
+			break
+		}
+
+		for i := 0; i < max(len(oldCast), len(newCast)); i++ {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(newCast):
+				*diff = append(*diff, "removed "+itemPath)
+			case i >= len(oldCast):
+				*diff = append(*diff, "added "+itemPath)
+			default:
+				diffValues(diff, itemPath, oldCast[i], newCast[i])
+			}
+		}
+		return
+
+	default:
+		if old == new {
+			return
+		}
+	}
+
+	if path == "" {
+		path = "."
+	}
+	*diff = append(*diff, "changed "+path)
+}
+
+func diffMember(diff *[]string, path string, old, new map[string]any, key string) {
+	oldValue, inOld := old[key]
+	newValue, inNew := new[key]
+
+	switch {
+	case !inNew:
+		*diff = append(*diff, "removed "+path)
+	case !inOld:
+		*diff = append(*diff, "added "+path)
+	default:
+		diffValues(diff, path, oldValue, newValue)
+	}
+}
@@ -0,0 +1,149 @@
+package generate_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tbx.at/secrets-generator/internal"
+	"tbx.at/secrets-generator/internal/generate"
+	"tbx.at/secrets-generator/internal/testutil"
+)
+
+// runExplained runs the generator and returns the explanations by secret name.
+func (tb *Testbed) runExplained(t *testing.T, config internal.Config, rotate ...string) map[string]generate.Explanation {
+	explanations := make(map[string]generate.Explanation)
+
+	tb.RunGeneratorWithOptions(t, config, generate.Options{
+		Rotate: rotate,
+		Explain: func(explanation generate.Explanation) {
+			explanations[explanation.Secret] = explanation
+		},
+	})
+
+	return explanations
+}
+
+func TestExplain(t *testing.T) {
+	testbed := InitializeTest(t)
+
+	randomSecretName := testbed.GenerateSecretName()
+	jsonSecretName := testbed.GenerateSecretName()
+	scriptSecretName := testbed.GenerateSecretName()
+
+	config := internal.Config{
+		PublicKeys: testbed.PublicKeys,
+		Secrets: map[string]internal.Secret{
+			randomSecretName: {
+				Generation: internal.GenerationParams{
+					Random: &internal.GenerationParamsRandom{
+						Length:   32,
+						Charsets: RandomCharsets(),
+					},
+				},
+			},
+			jsonSecretName: {
+				Generation: internal.GenerationParams{
+					JSON: &internal.GenerationParamsJSON{
+						Content: map[string]any{
+							"user":     "admin",
+							"password": "hunter2",
+							"hosts":    []any{"a"},
+						},
+					},
+				},
+			},
+			scriptSecretName: {
+				Generation: internal.GenerationParams{
+					Script: &internal.GenerationParamsScript{
+						Program: "true",
+					},
+				},
+			},
+		},
+		SecretMounts: RandomMounts(map[string]int{
+			randomSecretName: 1,
+			jsonSecretName:   1,
+			scriptSecretName: 1,
+		}),
+	}
+
+	explanations := testbed.runExplained(t, config)
+	assert.Equal(t, map[string]generate.Explanation{
+		randomSecretName: {Secret: randomSecretName, Reason: generate.ReasonEntropyMissing},
+		jsonSecretName:   {Secret: jsonSecretName, Reason: generate.ReasonSecretMissing},
+		scriptSecretName: {Secret: scriptSecretName, Reason: generate.ReasonSecretMissing},
+	}, explanations)
+
+	t.Run("unchanged", func(t *testing.T) {
+		assert.Empty(t, testbed.runExplained(t, config))
+	})
+
+	t.Run("content differs", func(t *testing.T) {
+		config.Secrets[jsonSecretName].Generation.JSON.Content = map[string]any{
+			"user":     "admin",
+			"password": "hunter3",
+			"hosts":    []any{"a", "b"},
+			"port":     float64(22),
+		}
+
+		explanation := testbed.runExplained(t, config)[jsonSecretName]
+		assert.Equal(t, generate.ReasonContentDiffers, explanation.Reason)
+		assert.Equal(t, []string{"added .hosts[1]", "changed .password", "added .port", "generation parameters changed"}, explanation.Details)
+
+		// The values of the secret must not be revealed.
+		assert.NotContains(t, explanation.String(), "hunter")
+	})
+
+	t.Run("entropy exhausted", func(t *testing.T) {
+		config.Secrets[jsonSecretName].Generation.JSON.Content = testutil.JSONFunctionCall("hashBcrypt", map[string]any{
+			"data":   "hunter2",
+			"rounds": float64(5),
+		})
+
+		explanation := testbed.runExplained(t, config)[jsonSecretName]
+		assert.Equal(t, generate.ReasonEntropyExhausted, explanation.Reason)
+		assert.Equal(t, []string{"generation parameters changed"}, explanation.Details)
+	})
+
+	t.Run("secret missing", func(t *testing.T) {
+		require.NoError(t, os.Remove(internal.SecretFilePath(randomSecretName)))
+		assert.Equal(t, generate.ReasonSecretMissing, testbed.runExplained(t, config)[randomSecretName].Reason)
+	})
+
+	t.Run("secret undecryptable", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(internal.SecretFilePath(randomSecretName), []byte("garbage"), 0660))
+		assert.Equal(t, generate.ReasonSecretUndecryptable, testbed.runExplained(t, config)[randomSecretName].Reason)
+	})
+
+	t.Run("rotated", func(t *testing.T) {
+		randomBefore := testbed.ReadSecretFile(t, randomSecretName)
+		scriptBefore := testbed.ReadSecretFile(t, scriptSecretName)
+
+		explanations := testbed.runExplained(t, config, randomSecretName, scriptSecretName)
+		assert.Equal(t, map[string]generate.Explanation{
+			randomSecretName: {Secret: randomSecretName, Reason: generate.ReasonRotated},
+			scriptSecretName: {Secret: scriptSecretName, Reason: generate.ReasonRotated},
+		}, explanations)
+
+		assert.NotEqual(t, randomBefore, testbed.ReadSecretFile(t, randomSecretName))
+		assert.NotEqual(t, scriptBefore, testbed.ReadSecretFile(t, scriptSecretName))
+	})
+}
+
+func TestRotateUnknown(t *testing.T) {
+	testbed := InitializeTest(t)
+
+	err := generate.Run(context.Background(), internal.Config{
+		PublicKeys: testbed.PublicKeys,
+		Secrets: map[string]internal.Secret{
+			"imported": {},
+		},
+	}, generate.Options{
+		IdentityPath: IdentityFileName,
+		Rotate:       []string{"imported"},
+	})
+	assert.ErrorIs(t, err, generate.ErrRotateUnknown)
+}
@@ -7,29 +7,123 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
-	"path/filepath"
+	"sync"
+	"time"
 
 	"filippo.io/age"
 	"golang.org/x/sync/errgroup"
 	"tbx.at/secrets-generator/internal"
 	"tbx.at/secrets-generator/internal/generator"
+	"tbx.at/secrets-generator/internal/generator/importer"
 	"tbx.at/secrets-generator/internal/generator/json"
 	"tbx.at/secrets-generator/internal/generator/random"
 	"tbx.at/secrets-generator/internal/generator/script"
 	"tbx.at/secrets-generator/internal/generator/template"
+	"tbx.at/secrets-generator/internal/storage"
 )
 
-const (
-	ageFileCreateMode   = 0660
-	directoreCreateMode = 0770
+var (
+	ErrRotateUnknown    = errors.New("cannot rotate secret that is not generated")
+	ErrUnknownGenerator = errors.New("no generator registered for custom generation type")
 )
 
+// Options control how Run generates secrets.
+type Options struct {
+	// IdentityPath is the path of a file containing an age identity that can decrypt all secrets.
+	IdentityPath string
+
+	// Identities replaces the identities read from IdentityPath if it is not nil.
+	Identities []*age.X25519Identity
+
+	// Rotate holds the names of secrets that are generated again with fresh entropy, even if they haven't changed.
+	Rotate []string
+
+	// Explain is called with the reason for every secret that is generated.
+	// Calls are serialized, so it doesn't need to be safe for concurrent use.
+	Explain func(Explanation)
+
+	// Outcome is called for every secret once it has been generated or skipped.
+	// Calls are serialized with each other and with calls to Explain.
+	Outcome func(Outcome)
+
+	// Generators holds the generators for secrets with custom generation parameters by the type they are registered under.
+	Generators map[string]generator.Generator
+
+	// SecretStore caches decrypted secrets and is created for every run if it is nil.
+	// A store from NewSecretStore can be reused for several runs with the same options to avoid decrypting the same secrets again.
+	SecretStore *internal.SecretStore
+
+	// StrictDependencies makes reading a secret fail unless the reading secret depends on it (see Dependencies).
+	// This keeps dependencies in the configuration complete.
+	StrictDependencies bool
+
+	// LockMemory locks decrypted secrets into memory, so that they are never written to swap.
+	// It applies to the secret store created by Run or NewSecretStore.
+	LockMemory bool
+
+	// Logger receives a progress message for every secret.
+	// Nothing is logged if it is nil.
+	Logger *slog.Logger
+
+	// Storage holds the secret and entropy files.
+	// Files are stored in internal.SecretsDirectory in the current working directory if it is nil.
+	Storage storage.Storage
+
+	// Encoding determines the format of secret files, which are age files if it is nil.
+	// Entropy files are always age files since only the generator reads them.
+	Encoding internal.SecretEncoding
+}
+
+// logger returns options.Logger or a logger that discards everything if it is nil.
+func (options Options) logger() *slog.Logger {
+	if options.Logger == nil {
+		return slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	return options.Logger
+}
+
+// generatorKeys returns options.Identities or the identities read from options.IdentityPath, together with their recipients.
+func (options Options) generatorKeys() ([]age.Identity, []age.Recipient, error) {
+	if options.Identities == nil {
+		return internal.ParseGeneratorKeys(options.IdentityPath)
+	}
+
+	identities := make([]age.Identity, len(options.Identities))
+	recipients := make([]age.Recipient, len(options.Identities))
+	for i, identity := range options.Identities {
+		identities[i] = identity
+		recipients[i] = identity.Recipient()
+	}
+
+	return identities, recipients, nil
+}
+
+// storage returns options.Storage or the default local storage if it is nil.
+func (options Options) storage() storage.Storage {
+	if options.Storage == nil {
+		return &storage.Local{Root: internal.SecretsDirectory}
+	}
+
+	return options.Storage
+}
+
+// encoding returns options.Encoding or the age encoding if it is nil.
+func (options Options) encoding() internal.SecretEncoding {
+	if options.Encoding == nil {
+		return internal.AgeEncoding{}
+	}
+
+	return options.Encoding
+}
+
 // Run generates or regenerates secrets in the current working directory as needed.
 // This function amounts to the core of the program.
-func Run(ctx context.Context, identityPath string, config internal.Config) error {
+func Run(ctx context.Context, config internal.Config, options Options) error {
 	// Parse the keys used by the generator to decrypt any secret.
-	generatorIdentities, generatorRecipients, err := internal.ParseGeneratorKeys(identityPath)
+	generatorIdentities, generatorRecipients, err := options.generatorKeys()
 	if err != nil {
 		return err
 	}
@@ -40,40 +134,190 @@ func Run(ctx context.Context, identityPath string, config internal.Config) error
 		return err
 	}
 
+	if err := ValidateOutputs(config); err != nil {
+		return err
+	}
+
+	dependencies, err := Dependencies(config)
+	if err != nil {
+		return err
+	}
+
+	var allowed map[string]map[string]bool
+	if options.StrictDependencies {
+		allowed = allowedReads(config, dependencies)
+	}
+
+	rotate := make(map[string]bool)
+	for _, secretName := range options.Rotate {
+		if secret, ok := config.Secrets[secretName]; !ok || secret.Generation.Type() == "" {
+			return fmt.Errorf("%w: %s", ErrRotateUnknown, secretName)
+		}
+
+		rotate[secretName] = true
+	}
+
+	for secretName, secret := range config.Secrets {
+		if custom := secret.Generation.Custom; custom != nil && options.Generators[custom.Type] == nil {
+			return fmt.Errorf("%w: secret %s has type %s", ErrUnknownGenerator, secretName, custom.Type)
+		}
+	}
+
+	logger := options.logger()
+	secretStorage := options.storage()
+	secretEncoding := options.encoding()
+
+	var explainMutex sync.Mutex
+	explain := func(explanation Explanation) {
+		if options.Explain == nil {
+			return
+		}
+
+		explainMutex.Lock()
+		defer explainMutex.Unlock()
+
+		options.Explain(explanation)
+	}
+
+	report := func(outcome Outcome) {
+		if options.Outcome == nil {
+			return
+		}
+
+		explainMutex.Lock()
+		defer explainMutex.Unlock()
+
+		options.Outcome(outcome)
+	}
+
 	// Initialize some data structures.
 
 	completionMap := internal.NewCompletionMap(config.Secrets)
-	secretStore := internal.NewSecretStore(generatorIdentities)
+
+	secretStore := options.SecretStore
+	if secretStore == nil {
+		secretStore = internal.NewSecretStore(secretStorage, secretEncoding, generatorIdentities, internal.SecretStoreOptions{LockMemory: options.LockMemory})
+		defer secretStore.Wipe()
+	}
+
+	// Every secret retains the secrets it may load, so that they can be wiped once no remaining secret needs them.
+	reads := secretReads(config, allowed)
+	for _, names := range reads {
+		for _, name := range names {
+			secretStore.Retain(name, 1)
+		}
+	}
+
+	// release releases the secrets retained for a secret once it is done.
+	release := func(secretName string) {
+		for _, name := range reads[secretName] {
+			secretStore.Release(name)
+		}
+	}
 
 	generateGroup, generateCtx := errgroup.WithContext(ctx)
 
 	// Initialize the generators.
 
+	generatorImport := &importer.GeneratorImport{}
+	defer generatorImport.Wipe()
+
 	generatorJSON := &json.GeneratorJSON{
-		Completion:  completionMap,
-		SecretStore: secretStore,
+		Completion:   completionMap,
+		SecretStore:  secretStore,
+		Dependencies: allowed,
+		Logger:       logger,
 	}
 
 	generatorRandom := &random.GeneratorRandom{}
 	generatorScript := &script.GeneratorScript{}
 
 	generatorTemplate := &template.GeneratorTemplate{
-		Completion:  completionMap,
-		SecretStore: secretStore,
+		Completion:   completionMap,
+		SecretStore:  secretStore,
+		Dependencies: allowed,
+		Logger:       logger,
+	}
+
+	// Secrets are started once the secrets they depend on are complete, so that they don't block waiting for them.
+	// Dependencies that are only known once a secret is generated are still waited for through the completion map.
+	var scheduleMutex sync.Mutex
+
+	// waiting counts the dependencies of every secret that are not complete yet.
+	waiting := make(map[string]int, len(dependencies))
+	dependents := make(map[string][]string)
+	for secretName, secretDependencies := range dependencies {
+		waiting[secretName] = len(secretDependencies)
+		for _, dependency := range secretDependencies {
+			dependents[dependency] = append(dependents[dependency], secretName)
+		}
+	}
+
+	// tasks holds the function that generates or skips each secret.
+	tasks := make(map[string]func() error, len(config.Secrets))
+	started := make(map[string]bool, len(config.Secrets))
+
+	// start runs the task of a secret in a goroutine unless generation was cancelled.
+	start := func(secretName string) {
+		if generateCtx.Err() != nil {
+			return
+		}
+
+		scheduleMutex.Lock()
+		started[secretName] = true
+		scheduleMutex.Unlock()
+
+		task := tasks[secretName]
+		generateGroup.Go(func() error {
+			defer release(secretName)
+
+			if err := task(); err != nil {
+				return fmt.Errorf("while generating secret %s: %w", secretName, err)
+			}
+
+			return nil
+		})
+	}
+
+	// complete marks a secret as complete and starts the secrets that were waiting for it.
+	complete := func(secretName string) {
+		completionMap.MarkComplete(secretName)
+
+		var ready []string
+
+		scheduleMutex.Lock()
+		for _, dependent := range dependents[secretName] {
+			waiting[dependent]--
+			if waiting[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+		scheduleMutex.Unlock()
+
+		for _, dependent := range ready {
+			start(dependent)
+		}
 	}
 
-	// Iterate over all secrets and start a goroutine to generate it if needed.
+	// Iterate over all secrets and set up a task to generate it if needed.
 	for _secretName, _secret := range config.Secrets {
 		secretName := _secretName
 		secret := _secret
+		secret.Name = secretName
+
+		// Get the relevant storage keys.
+		entropyKey := internal.EntropyKey(secretName)
+		secretKey := internal.EncodedSecretKey(secretEncoding, secretName)
 
-		// Get the relevant paths.
-		entropyFilePath := internal.EntropyFilePath(secretName)
-		secretFilePath := internal.SecretFilePath(secretName)
+		// Get the names the secret is stored as.
+		// Secrets with multiple outputs are stored once per output.
+		storedNames := internal.StoredSecretNames(secretName, secret)
 
 		// Figure out what generator to use.
 		var generator generator.Generator
-		if secret.Generation.JSON != nil {
+		if secret.Generation.Import != nil {
+			generator = generatorImport
+		} else if secret.Generation.JSON != nil {
 			generator = generatorJSON
 		} else if secret.Generation.Random != nil {
 			generator = generatorRandom
@@ -81,59 +325,93 @@ func Run(ctx context.Context, identityPath string, config internal.Config) error
 			generator = generatorScript
 		} else if secret.Generation.Template != nil {
 			generator = generatorTemplate
+		} else if secret.Generation.Custom != nil {
+			generator = options.Generators[secret.Generation.Custom.Type]
 		} else {
 			// If we have no generation options, the secret is not automatically generated.
 			// Just mark it as complete then and move on.
-			completionMap.MarkComplete(secretName)
+			tasks[secretName] = func() error {
+				logger.Debug("skipping secret", "secret", secretName, "reason", SkipNotGenerated)
+				complete(secretName)
+				report(Outcome{Secret: secretName, Skipped: SkipNotGenerated})
+				return nil
+			}
 			continue
 		}
 
-		generate := func() error {
+		tasks[secretName] = func() error {
 			// rng holds the entropy source to be used in the final secret generation step.
-			rng := rand.Reader
+			var rng io.Reader = rand.Reader
+
+			// recordedEntropy holds the entropy read during generation if it needs to be written to the entropy file.
+			var recordedEntropy *bytes.Buffer
+
+			// explanation records why the secret is generated.
+			explanation := Explanation{Secret: secretName}
 
 			// If the generator can produce deterministic output, we check if it's necessary to regenerate the secret.
 			// We do this by feeding the generator the same entropy as last time the secret was generated.
 			// If it doesn't error and the output is the same, we know that the secret hasn't changed.
 			// If it runs into an error or the output is not the same, we generate the secret again with fresh entropy and record bytes read from that entropy.
-			if generator.Deterministic() {
-				// hasChanged holds whether the generated output needs regeneration (because of changes or errors).
-				var hasChanged bool
+			if rotate[secretName] {
+				// Rotated secrets are generated again without checking for changes.
+				explanation.Reason = ReasonRotated
+			} else if generator.Deterministic() {
 
 				// entropy holds a reader for the entropy used last time the secret was generated.
-				var entropy io.Reader
+				var entropy *internal.Entropy
 
-				// Try opening and decrypting the entropy file.
-				entropyFile, err := os.Open(entropyFilePath)
+				// Try reading and decrypting the entropy file.
+				encryptedEntropy, err := secretStorage.Read(generateCtx, entropyKey)
+				entropyFound := err == nil
 				if err == nil {
-					entropy, err = age.Decrypt(entropyFile, generatorIdentities...)
+					decrypted, err := age.Decrypt(bytes.NewReader(encryptedEntropy), generatorIdentities...)
+					if err != nil {
+						return err
+					}
+
+					// The header tells us which algorithms consumed the entropy, so we can consume it in the same way again.
+					entropy, err = internal.ReadEntropyHeader(decrypted)
 					if err != nil {
 						return err
 					}
 				} else if errors.Is(err, os.ErrNotExist) {
 					// If no entropy file exists, use an empty reader instead.
-					entropy = new(bytes.Reader)
+					entropy = &internal.Entropy{
+						Reader:    new(bytes.Reader),
+						Algorithm: internal.AlgorithmCurrent,
+					}
 				} else {
 					return err
 				}
 
-				// Generate the secret into a buffer for comparison.
-				generated := new(bytes.Buffer)
-				err = generator.Generate(generateCtx, entropy, secret, generated)
+				// Track whether the generator runs out of entropy to explain why the secret is regenerated.
+				exhaustion := &exhaustionReader{r: entropy.Reader}
 
-				// We don't need the entropy file open for reading anymore, so close it.
-				if entropyFile != nil {
-					_ = entropyFile.Close()
-				}
+				// Generate the secret into buffers for comparison.
+				generated, err := generateOutputs(generateCtx, generator, &internal.Entropy{
+					Reader:    exhaustion,
+					Algorithm: entropy.Algorithm,
+					Header:    entropy.Header,
+				}, secretName, secret)
 
 				if err != nil {
 					// If we ran into an error, we can assume that the secret has changed (because a successfully generated secret doesn't result in an error).
 					// A likely source of errors is that the amount of entropy read during generation has increased, so we hit the end of the entropy file.
-					hasChanged = true
+					switch {
+					case exhaustion.exhausted && !entropyFound:
+						explanation.Reason = ReasonEntropyMissing
+					case exhaustion.exhausted:
+						explanation.Reason = ReasonEntropyExhausted
+					default:
+						explanation.Reason = ReasonGenerationFailed
+						explanation.Details = append(explanation.Details, err.Error())
+					}
 				} else {
 					// If we didn't run into an error, the secret was generated successfully with the old entropy.
 
 					// Load the current secret for comparison.
+					// Secrets with multiple outputs are compared output by output, and if any of them has changed, all of them are regenerated.
 
 					// Normally we would wait for completion before loading a secret.
 					// There's no need to wait for completion here since we're loading the secret currently being generated.
@@ -141,139 +419,320 @@ func Run(ctx context.Context, identityPath string, config internal.Config) error
 					// Loading it into the secret store is also fine:
 					// If it's unchanged, it won't be regenerated and the stored version is current.
 					// If it has changed, it will be regenerated and stored before we mark it as complete, so the stale entry in the secret store is replaced before anyone reads it.
-					existing, err := secretStore.LoadSecret(secretName)
-					if err != nil {
-						// If there is an error the secret file doesn't exist or is somehow borked and we should probably regenerate it.
-						hasChanged = true
-					} else {
+					for _, storedName := range storedNames {
+						existing, err := secretStore.LoadSecret(generateCtx, storedName)
+						if errors.Is(err, os.ErrNotExist) {
+							explanation.Reason = ReasonSecretMissing
+							break
+						} else if err != nil {
+							// If there is any other error the secret file is somehow borked and we should probably regenerate it.
+							explanation.Reason = ReasonSecretUndecryptable
+							explanation.Details = append(explanation.Details, err.Error())
+							break
+						}
+
 						// If all goes well loading the secret, actually compare it to the version generated with the same entropy.
 						// If they are the same, the secret hasn't changed.
 						// If they are different, the secret has changed and needs to be regenerated.
-						hasChanged = !bytes.Equal(generated.Bytes(), existing)
-					}
+						if !bytes.Equal(generated[storedName], existing) {
+							explanation.Reason = ReasonContentDiffers
+
+							if secret.Generation.JSON != nil {
+								for _, change := range structureDiff(existing, generated[storedName]) {
+									if storedName != secretName {
+										change = storedName + ": " + change
+									}
 
+									explanation.Details = append(explanation.Details, change)
+								}
+							}
+
+							break
+						}
+					}
 				}
 
 				// If the secret hasn't changed, mark it as complete and we're done.
-				if !hasChanged {
-					completionMap.MarkComplete(secretName)
+				if explanation.Reason == "" {
+					logger.Info("skipping secret", "secret", secretName, "reason", SkipUnchanged)
+					complete(secretName)
+					report(Outcome{Secret: secretName, Skipped: SkipUnchanged})
 					return nil
 				}
 
-				// Otherwise we need to regenerate.
-				// Set up the rng variable with an entropy source that records to a file.
-
-				// Create the entropy file.
-
-				if err := os.MkdirAll(filepath.Dir(entropyFilePath), directoreCreateMode); err != nil {
-					return err
-				}
-
-				entropyFile, err = os.Create(entropyFilePath)
-				if err != nil {
-					return err
-				}
-				defer entropyFile.Close()
+				// The hash of the parameters recorded with the entropy tells whether the configuration of the secret changed.
+				if entropy.Header != nil && options.Explain != nil {
+					paramsHash, err := internal.HashGenerationParams(secret.Generation)
+					if err != nil {
+						return err
+					}
 
-				// Encrypt it in such a way that only the generator can read it.
-				// Hosts don't ever need to access this file, so it doesn't make sense to encrypt it for them.
-				entropyWriter, err := age.Encrypt(entropyFile, generatorRecipients...)
-				if err != nil {
-					return err
+					if paramsHash != entropy.Header.ParamsHash {
+						explanation.Details = append(explanation.Details, "generation parameters changed")
+					}
 				}
-				defer entropyWriter.Close()
 
-				// rng becomes a reader for cryptographically secure randomness that also writes the bytes it reads to the encrypted entropy file.
-				rng = io.TeeReader(rand.Reader, entropyWriter)
 			} else {
 				// If we end up here then the generator cannot produce deterministic output.
 				// In this case, to regenerate or not is a simple question of whether the secret file exists.
 				// No entropy file is recorded in this case.
 
-				if _, err := os.Stat(secretFilePath); err == nil {
-					completionMap.MarkComplete(secretName)
+				if _, err := secretStorage.Read(generateCtx, secretKey); err == nil {
+					logger.Info("skipping secret", "secret", secretName, "reason", SkipExists)
+					complete(secretName)
+					report(Outcome{Secret: secretName, Skipped: SkipExists})
 					return nil
 				} else if !errors.Is(err, os.ErrNotExist) {
 					return err
 				}
-			}
-
-			// Find the recipients for the secret.
-			// This always includes the generator itself (so that it can decrypt secrets in the future) and all of the hosts that have the secret mounted.
-
-			var secretRecipients []age.Recipient
-			secretRecipients = append(secretRecipients, generatorRecipients...)
-
-			for mountName, mount := range config.SecretMounts {
-				if mount.Secret == secretName {
-					hostRecipients, found := recipients[mount.Host]
-					if !found {
-						return fmt.Errorf("unknown host in secret mount: mount=%s secret=%s host=%s", mountName, secretName, mount.Host)
-					}
 
-					secretRecipients = append(secretRecipients, hostRecipients...)
-				}
+				explanation.Reason = ReasonSecretMissing
 			}
 
-			// Create the secret file.
+			logger.Info("generating secret", "secret", secretName, "reason", explanation.Reason)
+			explain(explanation)
 
-			if err := os.MkdirAll(filepath.Dir(secretFilePath), directoreCreateMode); err != nil {
-				return err
+			if generator.Deterministic() {
+				// Set up the rng variable with an entropy source that records into a buffer.
+				// The buffer is written to the entropy file after generation because the header records the number of bytes read.
+				recordedEntropy = new(bytes.Buffer)
+
+				// rng becomes a reader for cryptographically secure randomness that also records the bytes it reads.
+				// Fresh entropy is always consumed with the current algorithms.
+				rng = &internal.Entropy{
+					Reader:    io.TeeReader(rand.Reader, recordedEntropy),
+					Algorithm: internal.AlgorithmCurrent,
+				}
 			}
 
-			secretFile, err := os.Create(secretFilePath)
+			// Actually generate the secret.
+			// The secret is generated into buffers first, which are then encrypted into the secret files.
+			// The secret store keeps the contents of the buffers when it writes the secret files.
+			// This avoids the need to read and decrypt the secret files if another secret needs to load the current secret.
+			generated, err := generateOutputs(generateCtx, generator, rng, secretName, secret)
 			if err != nil {
 				return err
 			}
 
-			// Encrypt the secret file for the previously determined recipients.
-
-			secretWriter, err := age.Encrypt(secretFile, secretRecipients...)
-			if err != nil {
-				return err
-			}
+			for _, storedName := range storedNames {
+				// Outputs of a secret are mounted separately, so each of them has its own recipients.
+				secretRecipients, err := secretRecipients(config, recipients, generatorRecipients, storedName)
+				if err != nil {
+					return err
+				}
 
-			// The secret will be generated directly into the encrypted file and into an unencrypted buffer.
-			// The contents of the buffer will be stored into the secret store later.
-			// This avoids the need to read and decrypt the secret file if another secret needs to load the current secret.
-			generated := new(bytes.Buffer)
-			writer := io.MultiWriter(secretWriter, generated)
+				if err := secretStore.WriteSecret(generateCtx, storedName, secretRecipients, generated[storedName]); err != nil {
+					return err
+				}
 
-			// Actually generate the secret.
-			if err := generator.Generate(generateCtx, rng, secret, writer); err != nil {
-				return err
+				logger.Info("wrote secret", "secret", storedName, "recipients", len(secretRecipients))
 			}
 
-			// Flush the age writer and close the secret file gracefully.
-			// We don't handle errors during closing for the entropy file because it's inconvenient in the case where we don't have an entropy file.
-			// I think it's fine like that.
+			// Write the entropy file if the generator is deterministic.
+			if recordedEntropy != nil {
+				paramsHash, err := internal.HashGenerationParams(secret.Generation)
+				if err != nil {
+					return err
+				}
 
-			if err := secretWriter.Close(); err != nil {
-				return err
-			}
+				header := internal.EntropyHeader{
+					Algorithm:  internal.AlgorithmCurrent,
+					Generator:  secret.Generation.Type(),
+					ParamsHash: paramsHash,
+					Timestamp:  time.Now().UTC(),
+				}
 
-			if err := secretFile.Close(); err != nil {
-				return err
-			}
+				if err := writeEntropyFile(generateCtx, secretStorage, entropyKey, generatorRecipients, header, recordedEntropy.Bytes()); err != nil {
+					return err
+				}
 
-			// Store the secret so that other secret generation goroutines can get its content.
-			secretStore.StoreSecret(secretName, generated.Bytes())
+				logger.Debug("wrote entropy", "secret", secretName, "bytes", recordedEntropy.Len())
+			}
 
 			// Mark this secret as complete.
 			// Other secret generation goroutines won't try to load this secret until it's marked as complete.
-			completionMap.MarkComplete(secretName)
+			complete(secretName)
+			report(Outcome{Secret: secretName, Explanation: explanation})
 
 			return nil
 		}
+	}
 
-		generateGroup.Go(func() error {
-			if err := generate(); err != nil {
-				return fmt.Errorf("while generating secret %s: %w", secretName, err)
+	// Start the secrets that don't depend on any other secret.
+	// They have to be collected first, since starting them can make other secrets ready.
+	var ready []string
+	for secretName, count := range waiting {
+		if count == 0 {
+			ready = append(ready, secretName)
+		}
+	}
+
+	for _, secretName := range ready {
+		start(secretName)
+	}
+
+	err = generateGroup.Wait()
+
+	// Secrets that weren't started because generation failed still hold their retained secrets.
+	for secretName := range config.Secrets {
+		if !started[secretName] {
+			release(secretName)
+		}
+	}
+
+	return err
+}
+
+// NewSecretStore returns a store for the secrets in options.Storage that can be passed to Run in options.SecretStore.
+// The store keeps secrets between runs until it is wiped.
+func NewSecretStore(options Options) (*internal.SecretStore, error) {
+	identities, _, err := options.generatorKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.NewSecretStore(options.storage(), options.encoding(), identities, internal.SecretStoreOptions{
+		Keep:       true,
+		LockMemory: options.LockMemory,
+	}), nil
+}
+
+// secretReads returns the names of the stored secrets that each secret may load from the secret store while it is generated.
+// Generated secrets load their own outputs for comparison.
+// Secrets whose generators can read other secrets may load those in allowed, or any of them if allowed is nil.
+func secretReads(config internal.Config, allowed map[string]map[string]bool) map[string][]string {
+	var all []string
+	for secretName, secret := range config.Secrets {
+		all = append(all, internal.StoredSecretNames(secretName, secret)...)
+	}
+
+	reads := make(map[string][]string)
+	for secretName, secret := range config.Secrets {
+		if secret.Generation.Type() == "" {
+			continue
+		}
+
+		reads[secretName] = internal.StoredSecretNames(secretName, secret)
+
+		if secret.Generation.JSON == nil && secret.Generation.Template == nil {
+			continue
+		}
+
+		if allowed == nil {
+			reads[secretName] = all
+			continue
+		}
+
+		for name := range allowed[secretName] {
+			reads[secretName] = append(reads[secretName], name)
+		}
+	}
+
+	return reads
+}
+
+// generateOutputs generates a secret into buffers, keyed by the names the outputs are stored as.
+// Secrets with a single output are stored under their own name.
+func generateOutputs(ctx context.Context, gen generator.Generator, rng io.Reader, secretName string, secret internal.Secret) (map[string][]byte, error) {
+	outputs := secret.Outputs()
+
+	if outputs == nil {
+		generated := new(bytes.Buffer)
+		if err := gen.Generate(ctx, rng, secret, generated); err != nil {
+			return nil, err
+		}
+
+		return map[string][]byte{secretName: generated.Bytes()}, nil
+	}
+
+	multiGen, ok := gen.(generator.MultiOutputGenerator)
+	if !ok {
+		return nil, ErrOutputsNotSupported
+	}
+
+	buffers := make(map[string]*bytes.Buffer, len(outputs))
+	writers := make(map[string]io.Writer, len(outputs))
+
+	for _, output := range outputs {
+		buffers[output] = new(bytes.Buffer)
+		writers[output] = buffers[output]
+	}
+
+	if err := multiGen.GenerateOutputs(ctx, rng, secret, writers); err != nil {
+		return nil, err
+	}
+
+	generated := make(map[string][]byte, len(outputs))
+	for output, buffer := range buffers {
+		generated[internal.OutputSecretName(secretName, output)] = buffer.Bytes()
+	}
+
+	return generated, nil
+}
+
+// secretRecipients returns the recipients for a stored secret.
+// This always includes the generator itself (so that it can decrypt secrets in the future) and all of the hosts that have the secret mounted.
+func secretRecipients(config internal.Config, recipients map[string][]age.Recipient, generatorRecipients []age.Recipient, storedName string) ([]age.Recipient, error) {
+	var secretRecipients []age.Recipient
+	secretRecipients = append(secretRecipients, generatorRecipients...)
+
+	for mountName, mount := range config.SecretMounts {
+		if mount.Secret == storedName {
+			hostRecipients, found := recipients[mount.Host]
+			if !found {
+				return nil, fmt.Errorf("unknown host in secret mount: mount=%s secret=%s host=%s", mountName, storedName, mount.Host)
 			}
 
-			return nil
-		})
+			secretRecipients = append(secretRecipients, hostRecipients...)
+		}
+	}
+
+	return secretRecipients, nil
+}
+
+// writeSecretFile encrypts a secret for the given recipients and writes it to the storage.
+func writeSecretFile(ctx context.Context, secretStorage storage.Storage, encoding internal.SecretEncoding, key string, recipients []age.Recipient, data []byte) error {
+	encrypted, err := encoding.Encrypt(data, recipients)
+	if err != nil {
+		return err
+	}
+
+	return secretStorage.Write(ctx, key, encrypted)
+}
+
+// writeEntropyFile writes the entropy recorded during generation to an entropy file.
+// The file is encrypted in such a way that only the generator can read it.
+// Hosts don't ever need to access this file, so it doesn't make sense to encrypt it for them.
+func writeEntropyFile(ctx context.Context, secretStorage storage.Storage, key string, generatorRecipients []age.Recipient, header internal.EntropyHeader, entropy []byte) error {
+	encrypted := new(bytes.Buffer)
+
+	entropyWriter, err := age.Encrypt(encrypted, generatorRecipients...)
+	if err != nil {
+		return err
+	}
+
+	if err := internal.WriteEntropyFile(entropyWriter, header, entropy); err != nil {
+		return err
+	}
+
+	if err := entropyWriter.Close(); err != nil {
+		return err
+	}
+
+	return secretStorage.Write(ctx, key, encrypted.Bytes())
+}
+
+// reencryptFile decrypts the file at key with identities and replaces it with the same plaintext encrypted to recipients.
+func reencryptFile(ctx context.Context, secretStorage storage.Storage, encoding internal.SecretEncoding, key string, identities []age.Identity, recipients []age.Recipient) error {
+	encrypted, err := secretStorage.Read(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := encoding.Decrypt(encrypted, identities)
+	if err != nil {
+		return err
 	}
 
-	return generateGroup.Wait()
+	return writeSecretFile(ctx, secretStorage, encoding, key, recipients, plaintext)
 }
@@ -101,6 +101,20 @@ func mountsForSecret(secretMounts map[string]internal.SecretMount, secretName st
 	return mounts
 }
 
+func (tb *Testbed) ReadEntropyHeader(t *testing.T, secretName string) *internal.EntropyHeader {
+	entropyFile, err := os.Open(internal.EntropyFilePath(secretName))
+	require.NoError(t, err)
+	defer entropyFile.Close()
+
+	entropyReader, err := age.Decrypt(entropyFile, tb.GeneratorIdentities[0])
+	require.NoError(t, err)
+
+	entropy, err := internal.ReadEntropyHeader(entropyReader)
+	require.NoError(t, err)
+
+	return entropy.Header
+}
+
 func (tb *Testbed) ReadEntropy(t *testing.T, secretName string) []byte {
 	var lastRead *[]byte
 	entropyFilePath := internal.EntropyFilePath(secretName)
@@ -112,7 +126,10 @@ func (tb *Testbed) ReadEntropy(t *testing.T, secretName string) []byte {
 		entropyReader, err := age.Decrypt(entropyFile, identity)
 		require.NoError(t, err)
 
-		entropy, err := io.ReadAll(entropyReader)
+		entropyWithoutHeader, err := internal.ReadEntropyHeader(entropyReader)
+		require.NoError(t, err)
+
+		entropy, err := io.ReadAll(entropyWithoutHeader)
 		require.NoError(t, err)
 
 		require.NoError(t, entropyFile.Close())
@@ -127,6 +144,29 @@ func (tb *Testbed) ReadEntropy(t *testing.T, secretName string) []byte {
 	return *lastRead
 }
 
+func (tb *Testbed) WriteEntropy(t *testing.T, secretName string, entropy []byte) {
+	recipients := make([]age.Recipient, len(tb.GeneratorIdentities))
+	for i, id := range tb.GeneratorIdentities {
+		recipients[i] = id.Recipient()
+	}
+
+	entropyFilePath := internal.EntropyFilePath(secretName)
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(entropyFilePath), 0770))
+
+	entropyFile, err := os.Create(entropyFilePath)
+	require.NoError(t, err)
+
+	entropyWriter, err := age.Encrypt(entropyFile, recipients...)
+	require.NoError(t, err)
+
+	_, err = entropyWriter.Write(entropy)
+	require.NoError(t, err)
+
+	require.NoError(t, entropyWriter.Close())
+	require.NoError(t, entropyFile.Close())
+}
+
 func (tb *Testbed) ReadEntropyFile(t *testing.T, secretName string) []byte {
 	content, err := os.ReadFile(internal.EntropyFilePath(secretName))
 	assert.NoError(t, err)
@@ -154,7 +194,12 @@ func (tb *Testbed) ReadSecret(t *testing.T, identities []age.Identity, secretNam
 }
 
 func (tb *Testbed) RunGenerator(t *testing.T, config internal.Config) {
-	assert.NoError(t, generate.Run(context.Background(), IdentityFileName, config))
+	tb.RunGeneratorWithOptions(t, config, generate.Options{})
+}
+
+func (tb *Testbed) RunGeneratorWithOptions(t *testing.T, config internal.Config, options generate.Options) {
+	options.IdentityPath = IdentityFileName
+	assert.NoError(t, generate.Run(context.Background(), config, options))
 }
 
 func (tb *Testbed) ReadSecretFile(t *testing.T, secretName string) []byte {
@@ -0,0 +1,122 @@
+package generate_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tbx.at/secrets-generator/internal/rand/crypt"
+	"tbx.at/secrets-generator/internal/rand/django"
+	"tbx.at/secrets-generator/internal/rand/password"
+	"tbx.at/secrets-generator/internal/rand/ssha"
+	"tbx.at/secrets-generator/internal/rand/yescrypt"
+	"tbx.at/secrets-generator/internal/testutil"
+)
+
+type hashFunctionTest struct {
+	name string
+
+	jsonArgs     map[string]any
+	templateArgs string
+
+	hash func(rng io.Reader, password []byte) (string, error)
+}
+
+var hashFunctionTests = []hashFunctionTest{
+	{
+		name: "hashAPR1",
+		hash: crypt.APR1,
+	},
+	{
+		name:         "hashPBKDF2SHA256",
+		jsonArgs:     map[string]any{"iterations": float64(1000)},
+		templateArgs: "1000",
+		hash: func(rng io.Reader, password []byte) (string, error) {
+			return django.PBKDF2SHA256(rng, password, 1000)
+		},
+	},
+	{
+		name:         "hashSHA512Crypt",
+		jsonArgs:     map[string]any{"rounds": float64(5000)},
+		templateArgs: "5000",
+		hash: func(rng io.Reader, password []byte) (string, error) {
+			return crypt.SHA512(rng, password, 5000)
+		},
+	},
+	{
+		name: "hashSSHA",
+		hash: ssha.Hash,
+	},
+	{
+		name:         "hashScrypt",
+		jsonArgs:     map[string]any{"n": float64(1024), "r": float64(8), "p": float64(1)},
+		templateArgs: "1024 8 1",
+		hash: func(rng io.Reader, password []byte) (string, error) {
+			return django.Scrypt(rng, password, 1024, 8, 1)
+		},
+	},
+	{
+		name: "hashYescrypt",
+		hash: yescrypt.Hash,
+	},
+}
+
+func TestJSONHashFunctions(t *testing.T) {
+	for _, test := range hashFunctionTests {
+		t.Run(test.name, func(t *testing.T) {
+			password, err := password.GeneratePassword(rand.Reader, templatePasswordCharset, 32)
+			require.NoError(t, err)
+
+			args := map[string]any{
+				"data": string(password),
+			}
+			for name, value := range test.jsonArgs {
+				args[name] = value
+			}
+
+			testJSONFunction(t, jsonTestParameters{
+				Content: testutil.JSONFunctionCall(test.name, args),
+
+				CheckOutput: func(t *testing.T, parameters jsonTestCheckParameters) {
+					var hash string
+					assert.NoError(t, json.Unmarshal([]byte(parameters.output), &hash))
+
+					assertHashFromEntropy(t, test, parameters.testbed.ReadEntropy(t, parameters.secretName), password, hash)
+				},
+			})
+		})
+	}
+}
+
+func TestTemplateHashFunctions(t *testing.T) {
+	for _, test := range hashFunctionTests {
+		t.Run(test.name, func(t *testing.T) {
+			password, err := password.GeneratePassword(rand.Reader, templatePasswordCharset, 32)
+			require.NoError(t, err)
+
+			testTemplateFunction(t, templateTestParameters{
+				Data: map[string]any{
+					"Password": password,
+				},
+				Template: fmt.Sprintf("{{ %s .Password %s }}", test.name, test.templateArgs),
+
+				CheckOutput: func(t *testing.T, parameters templateTestCheckParameters) {
+					assertHashFromEntropy(t, test, parameters.testbed.ReadEntropy(t, parameters.secretName), password, parameters.output)
+				},
+			})
+		})
+	}
+}
+
+// assertHashFromEntropy checks that the hash can be reproduced from the recorded entropy, i.e. that the salt was read from it.
+func assertHashFromEntropy(t *testing.T, test hashFunctionTest, entropy, password []byte, hash string) {
+	expected, err := test.hash(bytes.NewReader(entropy), password)
+	require.NoError(t, err)
+
+	assert.Equal(t, expected, hash)
+}
@@ -0,0 +1,135 @@
+package generate_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tbx.at/secrets-generator/internal"
+)
+
+// fakePass is a pass compatible program that serves entries from the directory it is installed in.
+const fakePass = `#!/bin/sh
+[ "$1" = show ] || exit 1
+exec cat "$(dirname "$0")/store/$2"
+`
+
+func TestImport(t *testing.T) {
+	testbed := InitializeTest(t)
+
+	fileSecretName := testbed.GenerateSecretName()
+	envSecretName := testbed.GenerateSecretName()
+	passSecretName := testbed.GenerateSecretName()
+	gopassSecretName := testbed.GenerateSecretName()
+	commandSecretName := testbed.GenerateSecretName()
+
+	sourceFile := filepath.Join(t.TempDir(), "api-key")
+	require.NoError(t, os.WriteFile(sourceFile, []byte("file-key\n"), 0600))
+
+	t.Setenv("SECRETS_GENERATOR_TEST_IMPORT", "env-key")
+
+	// The fake pass is installed both as pass (found on PATH) and under another name to test PassCommand.
+	passDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(passDir, "store", "smtp"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(passDir, "store", "smtp", "password"), []byte("pass-key\nuser: mailer\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(passDir, "pass"), []byte(fakePass), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(passDir, "gopass"), []byte(fakePass), 0700))
+	t.Setenv("PATH", passDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	config := internal.Config{
+		PublicKeys: testbed.PublicKeys,
+		Secrets: map[string]internal.Secret{
+			fileSecretName: {Generation: internal.GenerationParams{
+				Import: &internal.GenerationParamsImport{File: sourceFile},
+			}},
+			envSecretName: {Generation: internal.GenerationParams{
+				Import: &internal.GenerationParamsImport{Env: "SECRETS_GENERATOR_TEST_IMPORT"},
+			}},
+			passSecretName: {Generation: internal.GenerationParams{
+				Import: &internal.GenerationParamsImport{Pass: "smtp/password"},
+			}},
+			gopassSecretName: {Generation: internal.GenerationParams{
+				Import: &internal.GenerationParamsImport{Pass: "smtp/password", PassCommand: filepath.Join(passDir, "gopass")},
+			}},
+			commandSecretName: {Generation: internal.GenerationParams{
+				Import: &internal.GenerationParamsImport{Command: []string{"echo", "command-key"}},
+			}},
+		},
+		SecretMounts: RandomMounts(map[string]int{
+			fileSecretName:    2,
+			envSecretName:     1,
+			passSecretName:    1,
+			gopassSecretName:  1,
+			commandSecretName: 1,
+		}),
+	}
+
+	testbed.RunGenerator(t, config)
+
+	for secretName, expected := range map[string]string{
+		fileSecretName:    "file-key\n",
+		envSecretName:     "env-key",
+		passSecretName:    "pass-key",
+		gopassSecretName:  "pass-key",
+		commandSecretName: "command-key",
+	} {
+		assert.Equal(t, expected, testbed.ReadSecret(t, testbed.IdentitiesForSecret(config.SecretMounts, secretName), secretName))
+	}
+
+	t.Run("unchanged source", func(t *testing.T) {
+		secretFileBefore := testbed.ReadSecretFile(t, fileSecretName)
+
+		testbed.RunGenerator(t, config)
+
+		assert.Equal(t, secretFileBefore, testbed.ReadSecretFile(t, fileSecretName))
+	})
+
+	t.Run("changed source", func(t *testing.T) {
+		passFileBefore := testbed.ReadSecretFile(t, passSecretName)
+
+		require.NoError(t, os.WriteFile(sourceFile, []byte("rotated-key\n"), 0600))
+		testbed.RunGenerator(t, config)
+
+		assert.Equal(t, "rotated-key\n", testbed.ReadSecret(t, testbed.IdentitiesForSecret(config.SecretMounts, fileSecretName), fileSecretName))
+		assert.Equal(t, passFileBefore, testbed.ReadSecretFile(t, passSecretName))
+	})
+}
+
+func TestImportReadsSourceOnce(t *testing.T) {
+	testbed := InitializeTest(t)
+
+	secretName := testbed.GenerateSecretName()
+
+	// The command logs every call, so that the test can count how often the source was read.
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	value := filepath.Join(dir, "value")
+	require.NoError(t, os.WriteFile(value, []byte("first-key"), 0600))
+
+	config := internal.Config{
+		PublicKeys: testbed.PublicKeys,
+		Secrets: map[string]internal.Secret{
+			secretName: {Generation: internal.GenerationParams{
+				Import: &internal.GenerationParamsImport{Command: []string{"sh", "-c", `echo >> "$1" && cat "$2"`, "sh", calls, value}},
+			}},
+		},
+		SecretMounts: RandomMounts(map[string]int{secretName: 1}),
+	}
+
+	readCalls := func() int {
+		data, err := os.ReadFile(calls)
+		require.NoError(t, err)
+		return len(data)
+	}
+
+	// The secret is missing, so it is checked and generated in the same run.
+	testbed.RunGenerator(t, config)
+	assert.Equal(t, 1, readCalls())
+
+	require.NoError(t, os.WriteFile(value, []byte("second-key"), 0600))
+	testbed.RunGenerator(t, config)
+	assert.Equal(t, 2, readCalls())
+	assert.Equal(t, "second-key", testbed.ReadSecret(t, testbed.IdentitiesForSecret(config.SecretMounts, secretName), secretName))
+}
@@ -98,7 +98,11 @@ func TestJSONNoFunction(t *testing.T) {
 		"hello":    "world",
 	}
 
-	expected, err := json.MarshalIndent(content, "  ", "  ")
+	// Byte slices are written as text, like they are in every other format.
+	expected, err := json.MarshalIndent(map[string]any{
+		"password": string(password),
+		"hello":    "world",
+	}, "  ", "  ")
 	require.NoError(t, err)
 
 	testJSONFunction(t, jsonTestParameters{
@@ -186,8 +190,97 @@ func TestJSONHashBcrypt(t *testing.T) {
 	})
 }
 
+func TestJSONStringReplace(t *testing.T) {
+	testJSONFunction(t, jsonTestParameters{
+		Content: testutil.JSONFunctionCall("stringReplace", map[string]any{
+			"s":   "abc",
+			"old": "a",
+			"new": "AA",
+			"n":   float64(-1),
+		}),
+
+		CheckOutput: func(t *testing.T, parameters jsonTestCheckParameters) {
+			assert.JSONEq(t, `"AAbc"`, parameters.output)
+		},
+	})
+}
+
+func TestJSONEncodingFunctions(t *testing.T) {
+	testJSONFunction(t, jsonTestParameters{
+		Content: testutil.JSONFunctionCall("base64Encode", map[string]any{
+			"data": testutil.JSONFunctionCall("toJSON", map[string]any{
+				"value": map[string]any{
+					"user": testutil.JSONFunctionCall("upper", map[string]any{
+						"data": "admin",
+					}),
+				},
+			}),
+		}),
+
+		CheckOutput: func(t *testing.T, parameters jsonTestCheckParameters) {
+			assert.JSONEq(t, `"eyJ1c2VyIjoiQURNSU4ifQ=="`, parameters.output)
+		},
+	})
+}
+
+func TestJSONDecodingFunctions(t *testing.T) {
+	testJSONFunction(t, jsonTestParameters{
+		Content: map[string]any{
+			"base64":    testutil.JSONFunctionCall("base64Decode", map[string]any{"data": "aGk="}),
+			"base64URL": testutil.JSONFunctionCall("base64URLDecode", map[string]any{"data": "aGk"}),
+			"hex":       testutil.JSONFunctionCall("hexDecode", map[string]any{"data": "6869"}),
+			"json":      testutil.JSONFunctionCall("fromJSON", map[string]any{"data": `{"a": [1, "b"]}`}),
+		},
+
+		CheckOutput: func(t *testing.T, parameters jsonTestCheckParameters) {
+			assert.JSONEq(t, `{"base64": "hi", "base64URL": "hi", "hex": "hi", "json": {"a": [1, "b"]}}`, parameters.output)
+		},
+	})
+}
+
+func TestJSONFormats(t *testing.T) {
+	content := map[string]any{
+		"server": map[string]any{
+			"password": testutil.JSONFunctionCall("fmt", map[string]any{
+				"format": "%s",
+				"args":   []any{"pa\"ss $word"},
+			}),
+			"port": float64(8080),
+		},
+	}
+
+	for format, expected := range map[string]string{
+		"yaml": "server:\n    password: pa\"ss $word\n    port: 8080\n",
+		"toml": "[server]\n  password = \"pa\\\"ss $word\"\n  port = 8080\n",
+		"ini":  "[server]\npassword = \"pa\\\"ss $word\"\nport = 8080\n",
+	} {
+		t.Run(format, func(t *testing.T) {
+			testJSONFunction(t, jsonTestParameters{
+				Content: content,
+				Format:  format,
+
+				CheckOutput: func(t *testing.T, parameters jsonTestCheckParameters) {
+					assert.Equal(t, expected, parameters.output)
+				},
+			})
+		})
+	}
+
+	t.Run("dotenv", func(t *testing.T) {
+		testJSONFunction(t, jsonTestParameters{
+			Content: content["server"],
+			Format:  "dotenv",
+
+			CheckOutput: func(t *testing.T, parameters jsonTestCheckParameters) {
+				assert.Equal(t, "password=\"pa\\\"ss \\$word\"\nport=\"8080\"\n", parameters.output)
+			},
+		})
+	})
+}
+
 type jsonTestParameters struct {
 	Content     any
+	Format      string
 	CheckOutput func(t *testing.T, parameters jsonTestCheckParameters)
 }
 
@@ -207,6 +300,7 @@ func testJSONFunction(t *testing.T, params jsonTestParameters) {
 				Generation: internal.GenerationParams{
 					JSON: &internal.GenerationParamsJSON{
 						Content: params.Content,
+						Format:  params.Format,
 					},
 				},
 			},
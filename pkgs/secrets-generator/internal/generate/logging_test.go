@@ -0,0 +1,78 @@
+package generate_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tbx.at/secrets-generator/internal"
+	"tbx.at/secrets-generator/internal/generate"
+)
+
+type logRecord struct {
+	Msg    string
+	Secret string
+	Reason string
+}
+
+func (tb *Testbed) runLogged(t *testing.T, config internal.Config) []logRecord {
+	out := new(bytes.Buffer)
+
+	tb.RunGeneratorWithOptions(t, config, generate.Options{
+		Logger: slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	})
+
+	var records []logRecord
+	decoder := json.NewDecoder(out)
+	for decoder.More() {
+		var record logRecord
+		require.NoError(t, decoder.Decode(&record))
+
+		// Whether a secret has to wait for another one depends on scheduling.
+		if record.Msg != "waiting for dependency" {
+			records = append(records, record)
+		}
+	}
+
+	return records
+}
+
+func TestLogging(t *testing.T) {
+	testbed := InitializeTest(t)
+
+	passwordSecretName := testbed.GenerateSecretName()
+	importedSecretName := testbed.GenerateSecretName()
+
+	config := internal.Config{
+		PublicKeys: testbed.PublicKeys,
+		Secrets: map[string]internal.Secret{
+			passwordSecretName: {
+				Generation: internal.GenerationParams{
+					Random: &internal.GenerationParamsRandom{
+						Length:   32,
+						Charsets: RandomCharsets(),
+					},
+				},
+			},
+			importedSecretName: {},
+		},
+		SecretMounts: RandomMounts(map[string]int{
+			passwordSecretName: 1,
+		}),
+	}
+
+	assert.ElementsMatch(t, []logRecord{
+		{Msg: "skipping secret", Secret: importedSecretName, Reason: "not generated"},
+		{Msg: "generating secret", Secret: passwordSecretName, Reason: string(generate.ReasonEntropyMissing)},
+		{Msg: "wrote secret", Secret: passwordSecretName},
+		{Msg: "wrote entropy", Secret: passwordSecretName},
+	}, testbed.runLogged(t, config))
+
+	assert.ElementsMatch(t, []logRecord{
+		{Msg: "skipping secret", Secret: importedSecretName, Reason: "not generated"},
+		{Msg: "skipping secret", Secret: passwordSecretName, Reason: "unchanged"},
+	}, testbed.runLogged(t, config))
+}
@@ -0,0 +1,48 @@
+package generate
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"tbx.at/secrets-generator/internal"
+)
+
+var (
+	ErrOutputConflict      = errors.New("output conflicts with another secret")
+	ErrOutputName          = errors.New("invalid output name")
+	ErrOutputsAndContent   = errors.New("secret has both content and outputs")
+	ErrOutputsNotSupported = errors.New("generator does not support multiple outputs")
+)
+
+// outputNameRX matches valid output names.
+// Output names become file names, so they are restricted to characters that are safe in paths.
+var outputNameRX = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9_.-]*$`)
+
+// ValidateOutputs checks the outputs of all secrets in config.
+func ValidateOutputs(config internal.Config) error {
+	for secretName, secret := range config.Secrets {
+		outputs := secret.Outputs()
+		if outputs == nil {
+			continue
+		}
+
+		if (secret.Generation.JSON != nil && secret.Generation.JSON.Content != nil) ||
+			(secret.Generation.Template != nil && secret.Generation.Template.Content != "") {
+			return fmt.Errorf("%w: %s", ErrOutputsAndContent, secretName)
+		}
+
+		for _, output := range outputs {
+			if !outputNameRX.MatchString(output) {
+				return fmt.Errorf("%w in secret %s: %q", ErrOutputName, secretName, output)
+			}
+
+			outputSecretName := internal.OutputSecretName(secretName, output)
+			if _, exists := config.Secrets[outputSecretName]; exists {
+				return fmt.Errorf("%w: output %s of secret %s has the same name as secret %s", ErrOutputConflict, output, secretName, outputSecretName)
+			}
+		}
+	}
+
+	return nil
+}
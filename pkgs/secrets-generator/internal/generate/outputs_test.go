@@ -0,0 +1,199 @@
+package generate_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tbx.at/secrets-generator/internal"
+	"tbx.at/secrets-generator/internal/generate"
+	"tbx.at/secrets-generator/internal/rand/bcrypt"
+	"tbx.at/secrets-generator/internal/testutil"
+)
+
+func TestOutputsTemplate(t *testing.T) {
+	testbed := InitializeTest(t)
+
+	passwordSecretName := testbed.GenerateSecretName()
+	bundleSecretName := testbed.GenerateSecretName()
+	readerSecretName := testbed.GenerateSecretName()
+
+	hashOutputName := internal.OutputSecretName(bundleSecretName, "hash")
+	configOutputName := internal.OutputSecretName(bundleSecretName, "config")
+
+	config := internal.Config{
+		PublicKeys: testbed.PublicKeys,
+
+		Secrets: map[string]internal.Secret{
+			passwordSecretName: {
+				Generation: internal.GenerationParams{
+					JSON: &internal.GenerationParamsJSON{
+						Content: "hunter2",
+					},
+				},
+			},
+			bundleSecretName: {
+				Generation: internal.GenerationParams{
+					Template: &internal.GenerationParamsTemplate{
+						Data: map[string]any{
+							"PasswordSecret": passwordSecretName,
+							"User":           "admin",
+						},
+						Outputs: map[string]string{
+							"hash":   `{{ hashBcrypt (readSecretJSON .PasswordSecret) 5 }}`,
+							"config": `user = {{ .User }}`,
+						},
+					},
+				},
+			},
+			readerSecretName: {
+				Generation: internal.GenerationParams{
+					Template: &internal.GenerationParamsTemplate{
+						Data: map[string]any{
+							"ConfigSecret": configOutputName,
+						},
+						Content: `{{ fmt "%s" (readSecret .ConfigSecret) }}`,
+					},
+				},
+			},
+		},
+
+		SecretMounts: RandomMounts(map[string]int{
+			hashOutputName:   1,
+			configOutputName: 2,
+			readerSecretName: 1,
+		}),
+	}
+
+	testbed.RunGenerator(t, config)
+
+	hash := testbed.ReadSecret(t, testbed.IdentitiesForSecret(config.SecretMounts, hashOutputName), hashOutputName)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(hash), []byte("hunter2")))
+
+	configOutput := testbed.ReadSecret(t, testbed.IdentitiesForSecret(config.SecretMounts, configOutputName), configOutputName)
+	assert.Equal(t, "user = admin", configOutput)
+
+	readerOutput := testbed.ReadSecret(t, testbed.IdentitiesForSecret(config.SecretMounts, readerSecretName), readerSecretName)
+	assert.Equal(t, "user = admin", readerOutput)
+
+	assert.NoFileExists(t, internal.SecretFilePath(bundleSecretName))
+	assert.FileExists(t, internal.EntropyFilePath(bundleSecretName))
+
+	t.Run("reproducability", func(t *testing.T) {
+		hashFileBefore := testbed.ReadSecretFile(t, hashOutputName)
+		configFileBefore := testbed.ReadSecretFile(t, configOutputName)
+		entropyFileBefore := testbed.ReadEntropyFile(t, bundleSecretName)
+
+		testbed.RunGenerator(t, config)
+
+		assert.Equal(t, hashFileBefore, testbed.ReadSecretFile(t, hashOutputName))
+		assert.Equal(t, configFileBefore, testbed.ReadSecretFile(t, configOutputName))
+		assert.Equal(t, entropyFileBefore, testbed.ReadEntropyFile(t, bundleSecretName))
+	})
+
+	t.Run("regenerated together", func(t *testing.T) {
+		hashFileBefore := testbed.ReadSecretFile(t, hashOutputName)
+
+		config.Secrets[bundleSecretName].Generation.Template.Data["User"] = "root"
+
+		testbed.RunGenerator(t, config)
+
+		configOutput := testbed.ReadSecret(t, testbed.IdentitiesForSecret(config.SecretMounts, configOutputName), configOutputName)
+		assert.Equal(t, "user = root", configOutput)
+
+		// The hash output itself didn't change, but it is regenerated with fresh entropy along with the other output.
+		assert.NotEqual(t, hashFileBefore, testbed.ReadSecretFile(t, hashOutputName))
+
+		readerOutput := testbed.ReadSecret(t, testbed.IdentitiesForSecret(config.SecretMounts, readerSecretName), readerSecretName)
+		assert.Equal(t, "user = root", readerOutput)
+	})
+}
+
+func TestOutputsJSON(t *testing.T) {
+	testbed := InitializeTest(t)
+
+	secretName := testbed.GenerateSecretName()
+
+	config := internal.Config{
+		PublicKeys: testbed.PublicKeys,
+
+		Secrets: map[string]internal.Secret{
+			secretName: {
+				Generation: internal.GenerationParams{
+					JSON: &internal.GenerationParamsJSON{
+						Outputs: map[string]internal.GenerationParamsJSONOutput{
+							"json": {
+								Content: map[string]any{"a": "b"},
+							},
+							"env": {
+								Content: map[string]any{
+									"A": testutil.JSONFunctionCall("upper", map[string]any{"data": "b"}),
+								},
+								Format: "dotenv",
+							},
+						},
+					},
+				},
+			},
+		},
+
+		SecretMounts: RandomMounts(map[string]int{
+			internal.OutputSecretName(secretName, "json"): 1,
+			internal.OutputSecretName(secretName, "env"):  1,
+		}),
+	}
+
+	testbed.RunGenerator(t, config)
+
+	jsonOutputName := internal.OutputSecretName(secretName, "json")
+	assert.JSONEq(t, `{"a":"b"}`, testbed.ReadSecret(t, testbed.IdentitiesForSecret(config.SecretMounts, jsonOutputName), jsonOutputName))
+
+	envOutputName := internal.OutputSecretName(secretName, "env")
+	assert.Equal(t, "A=\"B\"\n", testbed.ReadSecret(t, testbed.IdentitiesForSecret(config.SecretMounts, envOutputName), envOutputName))
+}
+
+func TestOutputsInvalid(t *testing.T) {
+	for name, test := range map[string]struct {
+		secrets  map[string]internal.Secret
+		expected error
+	}{
+		"content and outputs": {
+			secrets: map[string]internal.Secret{
+				"a": {Generation: internal.GenerationParams{Template: &internal.GenerationParamsTemplate{
+					Content: "content",
+					Outputs: map[string]string{"b": "output"},
+				}}},
+			},
+			expected: generate.ErrOutputsAndContent,
+		},
+		"output name": {
+			secrets: map[string]internal.Secret{
+				"a": {Generation: internal.GenerationParams{Template: &internal.GenerationParamsTemplate{
+					Outputs: map[string]string{"../b": "output"},
+				}}},
+			},
+			expected: generate.ErrOutputName,
+		},
+		"conflict": {
+			secrets: map[string]internal.Secret{
+				"a": {Generation: internal.GenerationParams{Template: &internal.GenerationParamsTemplate{
+					Outputs: map[string]string{"b": "output"},
+				}}},
+				"a/b": {},
+			},
+			expected: generate.ErrOutputConflict,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			testbed := InitializeTest(t)
+
+			err := generate.Run(context.Background(), internal.Config{
+				PublicKeys: testbed.PublicKeys,
+				Secrets:    test.secrets,
+			}, generate.Options{IdentityPath: IdentityFileName})
+			require.Error(t, err)
+			assert.ErrorIs(t, err, test.expected)
+		})
+	}
+}
@@ -1,14 +1,19 @@
 package generate_test
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"tbx.at/secrets-generator/internal"
 	"tbx.at/secrets-generator/internal/generate"
 	"tbx.at/secrets-generator/internal/generator/random"
+	"tbx.at/secrets-generator/internal/rand/password"
 )
 
 func TestRandomGenerate(t *testing.T) {
@@ -146,6 +151,92 @@ func TestRandomEmptyCharset(t *testing.T) {
 		}),
 	}
 
-	err := generate.Run(context.Background(), IdentityFileName, config)
+	err := generate.Run(context.Background(), config, generate.Options{IdentityPath: IdentityFileName})
 	assert.ErrorContains(t, err, fmt.Sprintf("while generating secret %s: %s", secretName, random.ErrEmptyCharset.Error()))
 }
+
+func TestRandomLegacyEntropy(t *testing.T) {
+	secretLength := 32
+
+	testbed := InitializeTest(t)
+	secretName := testbed.GenerateSecretName()
+
+	config := internal.Config{
+		PublicKeys: testbed.PublicKeys,
+		Secrets: map[string]internal.Secret{
+			secretName: {
+				Generation: internal.GenerationParams{
+					Random: &internal.GenerationParamsRandom{
+						Length: secretLength,
+						Charsets: map[string]bool{
+							"lowercase": true,
+						},
+					},
+				},
+			},
+		},
+		SecretMounts: RandomMounts(map[string]int{
+			secretName: 3,
+		}),
+	}
+
+	// Entropy files written before the algorithm version was recorded don't have a header.
+	entropy := make([]byte, 4*secretLength)
+	_, err := rand.Read(entropy)
+	require.NoError(t, err)
+
+	legacyPassword, err := password.GeneratePasswordLegacy(bytes.NewReader(entropy), random.SupportedCharsets["lowercase"], secretLength)
+	require.NoError(t, err)
+
+	testbed.WriteEntropy(t, secretName, entropy)
+	testbed.WriteSecret(t, testbed.RecipientsForSecret(config.SecretMounts, secretName), secretName, string(legacyPassword))
+
+	entropyFileBefore := testbed.ReadEntropyFile(t, secretName)
+	secretFileBefore := testbed.ReadSecretFile(t, secretName)
+
+	testbed.RunGenerator(t, config)
+
+	assert.Equal(t, entropyFileBefore, testbed.ReadEntropyFile(t, secretName))
+	assert.Equal(t, secretFileBefore, testbed.ReadSecretFile(t, secretName))
+}
+
+func TestRandomEntropyLength(t *testing.T) {
+	secretLength := 128
+
+	testbed := InitializeTest(t)
+	secretName := testbed.GenerateSecretName()
+
+	config := internal.Config{
+		PublicKeys: testbed.PublicKeys,
+		Secrets: map[string]internal.Secret{
+			secretName: {
+				Generation: internal.GenerationParams{
+					Random: &internal.GenerationParamsRandom{
+						Length:   secretLength,
+						Charsets: RandomCharsets(),
+					},
+				},
+			},
+		},
+		SecretMounts: RandomMounts(map[string]int{
+			secretName: 3,
+		}),
+	}
+
+	testbed.RunGenerator(t, config)
+
+	assert.Len(t, testbed.ReadEntropy(t, secretName), password.SeedLength)
+
+	paramsHash, err := internal.HashGenerationParams(config.Secrets[secretName].Generation)
+	require.NoError(t, err)
+
+	header := testbed.ReadEntropyHeader(t, secretName)
+	require.NotNil(t, header)
+
+	assert.Equal(t, internal.EntropyFormatVersion, header.FormatVersion)
+	assert.Equal(t, internal.AlgorithmCurrent, header.Algorithm)
+	assert.Equal(t, internal.GenerationTypeRandom, header.Generator)
+	assert.Equal(t, paramsHash, header.ParamsHash)
+	assert.Equal(t, int64(password.SeedLength), header.Length)
+	assert.WithinDuration(t, time.Now(), header.Timestamp, time.Minute)
+}
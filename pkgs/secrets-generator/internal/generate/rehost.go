@@ -0,0 +1,79 @@
+package generate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"tbx.at/secrets-generator/internal"
+)
+
+var ErrRehostUnknown = errors.New("cannot rehost unknown host")
+
+// RehostSummary lists what Rehost did with the secrets mounted on a host.
+type RehostSummary struct {
+	// Rehosted holds the names of the secrets that were encrypted to the current keys of the host.
+	Rehosted []string
+
+	// Missing holds the names of the secrets that are mounted on the host but haven't been generated or set yet.
+	Missing []string
+}
+
+// Rehost encrypts the secrets mounted on hostname again with the keys currently listed for it in config.PublicKeys.
+// This is needed after the host key of a host changes, like when it is reinstalled.
+// The contents of the secrets don't change and secrets that aren't mounted on the host are left alone.
+func Rehost(ctx context.Context, config internal.Config, options Options, hostname string) (RehostSummary, error) {
+	var summary RehostSummary
+
+	if _, ok := config.PublicKeys[hostname]; !ok {
+		return summary, fmt.Errorf("%w: %s", ErrRehostUnknown, hostname)
+	}
+
+	generatorIdentities, generatorRecipients, err := options.generatorKeys()
+	if err != nil {
+		return summary, err
+	}
+
+	recipients, err := internal.ParseRecipients(config.PublicKeys)
+	if err != nil {
+		return summary, err
+	}
+
+	var storedNames []string
+	for _, mount := range config.SecretMounts {
+		if mount.Host == hostname && !slices.Contains(storedNames, mount.Secret) {
+			storedNames = append(storedNames, mount.Secret)
+		}
+	}
+	slices.Sort(storedNames)
+
+	logger := options.logger()
+
+	for _, storedName := range storedNames {
+		if err := ctx.Err(); err != nil {
+			return summary, err
+		}
+
+		// Other hosts that have the secret mounted stay recipients.
+		secretRecipients, err := secretRecipients(config, recipients, generatorRecipients, storedName)
+		if err != nil {
+			return summary, err
+		}
+
+		err = reencryptFile(ctx, options.storage(), options.encoding(), internal.EncodedSecretKey(options.encoding(), storedName), generatorIdentities, secretRecipients)
+		if errors.Is(err, os.ErrNotExist) {
+			logger.Warn("skipping secret", "secret", storedName, "reason", "missing")
+			summary.Missing = append(summary.Missing, storedName)
+			continue
+		} else if err != nil {
+			return summary, fmt.Errorf("while rehosting secret %s: %w", storedName, err)
+		}
+
+		logger.Info("wrote secret", "secret", storedName, "recipients", len(secretRecipients))
+		summary.Rehosted = append(summary.Rehosted, storedName)
+	}
+
+	return summary, nil
+}
@@ -0,0 +1,72 @@
+package generate_test
+
+import (
+	"context"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tbx.at/secrets-generator/internal"
+	"tbx.at/secrets-generator/internal/generate"
+)
+
+func TestRehost(t *testing.T) {
+	testbed := InitializeTest(t)
+
+	sharedSecretName := testbed.GenerateSecretName()
+	otherSecretName := testbed.GenerateSecretName()
+	missingSecretName := testbed.GenerateSecretName()
+
+	randomSecret := internal.Secret{
+		Generation: internal.GenerationParams{
+			Random: &internal.GenerationParamsRandom{Length: 32, Charsets: RandomCharsets()},
+		},
+	}
+
+	config := internal.Config{
+		PublicKeys: testbed.PublicKeys,
+		Secrets: map[string]internal.Secret{
+			sharedSecretName:  randomSecret,
+			otherSecretName:   randomSecret,
+			missingSecretName: {},
+		},
+		SecretMounts: map[string]internal.SecretMount{
+			"shared/maws":     {Host: HostMaws, Secret: sharedSecretName},
+			"shared/flyfish":  {Host: HostFlyfish, Secret: sharedSecretName},
+			"other/flyfish":   {Host: HostFlyfish, Secret: otherSecretName},
+			"missing/maws":    {Host: HostMaws, Secret: missingSecretName},
+			"missing/maws/v2": {Host: HostMaws, Secret: missingSecretName},
+		},
+	}
+
+	testbed.RunGenerator(t, config)
+
+	sharedSecret := testbed.ReadSecret(t, testbed.IdentitiesForSecret(config.SecretMounts, sharedSecretName), sharedSecretName)
+	otherSecretFile := testbed.ReadSecretFile(t, otherSecretName)
+
+	// Reinstall the host with a new key.
+	newIdentity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	testbed.PublicKeys[HostMaws] = []string{newIdentity.Recipient().String()}
+	testbed.Recipients[HostMaws] = []age.Recipient{newIdentity.Recipient()}
+	testbed.Identities[HostMaws] = []age.Identity{newIdentity}
+
+	options := generate.Options{IdentityPath: IdentityFileName}
+	summary, err := generate.Rehost(context.Background(), config, options, HostMaws)
+	require.NoError(t, err)
+
+	assert.Equal(t, generate.RehostSummary{
+		Rehosted: []string{sharedSecretName},
+		Missing:  []string{missingSecretName},
+	}, summary)
+
+	assert.Equal(t, sharedSecret, testbed.ReadSecret(t, testbed.IdentitiesForSecret(config.SecretMounts, sharedSecretName), sharedSecretName), "all hosts can decrypt the rehosted secret")
+	assert.Equal(t, otherSecretFile, testbed.ReadSecretFile(t, otherSecretName), "secrets not mounted on the host are left alone")
+
+	t.Run("unknown host", func(t *testing.T) {
+		_, err := generate.Rehost(context.Background(), config, options, "unknown")
+		assert.ErrorIs(t, err, generate.ErrRehostUnknown)
+	})
+}
@@ -0,0 +1,108 @@
+package generate
+
+import (
+	"context"
+	"strings"
+
+	"filippo.io/age"
+	"tbx.at/secrets-generator/internal"
+	"tbx.at/secrets-generator/internal/storage"
+)
+
+// RotateIdentity re-encrypts all secret and entropy files from the generator identity in options.IdentityPath to the one in newIdentityPath.
+// Secret files stay encrypted to the hosts they are mounted on and the plaintext of all files is left unchanged.
+// Files that the new identity can already decrypt are skipped, so an interrupted rotation is resumed by running it again.
+// Every file is replaced atomically, so it can always be decrypted with either the old or the new identity.
+func RotateIdentity(ctx context.Context, config internal.Config, options Options, newIdentityPath string) error {
+	oldIdentities, _, err := options.generatorKeys()
+	if err != nil {
+		return err
+	}
+
+	newIdentities, newRecipients, err := internal.ParseGeneratorKeys(newIdentityPath)
+	if err != nil {
+		return err
+	}
+
+	recipients, err := internal.ParseRecipients(config.PublicKeys)
+	if err != nil {
+		return err
+	}
+
+	logger := options.logger()
+	secretStorage := options.storage()
+	secretEncoding := options.encoding()
+
+	var rotated, skipped int
+
+	rotate := func(encoding internal.SecretEncoding, key string, fileRecipients []age.Recipient) error {
+		encrypted, err := secretStorage.Read(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		// A file the new identity can decrypt was rotated by an earlier run.
+		if _, err := encoding.Decrypt(encrypted, newIdentities); err == nil {
+			logger.Debug("skipping file", "key", key, "reason", "already rotated")
+			skipped++
+			return nil
+		}
+
+		if err := reencryptFile(ctx, secretStorage, encoding, key, oldIdentities, fileRecipients); err != nil {
+			return err
+		}
+
+		logger.Info("rotated file", "key", key, "recipients", len(fileRecipients))
+		rotated++
+		return nil
+	}
+
+	// Secret files are encrypted to the generator and the hosts that have them mounted.
+	err = forEachFile(ctx, secretStorage, internal.SecretsDataDirectory, secretEncoding.Extension(), func(key, storedName string) error {
+		fileRecipients, err := secretRecipients(config, recipients, newRecipients, storedName)
+		if err != nil {
+			return err
+		}
+
+		return rotate(secretEncoding, key, fileRecipients)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Entropy files are only ever encrypted to the generator.
+	err = forEachFile(ctx, secretStorage, internal.SecretsEntropyDirectory, internal.AgeEncoding{}.Extension(), func(key, secretName string) error {
+		return rotate(internal.AgeEncoding{}, key, newRecipients)
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("rotated identity", "rotated", rotated, "skipped", skipped)
+	return nil
+}
+
+// forEachFile calls fn for every file with extension in directory of the storage with the name of the secret that the file belongs to.
+func forEachFile(ctx context.Context, secretStorage storage.Storage, directory, extension string, fn func(key, secretName string) error) error {
+	keys, err := secretStorage.List(ctx, directory+"/")
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		secretName, ok := strings.CutSuffix(strings.TrimPrefix(key, directory+"/"), extension)
+		if !ok {
+			continue
+		}
+
+		if err := fn(key, secretName); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
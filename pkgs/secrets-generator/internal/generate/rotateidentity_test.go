@@ -0,0 +1,126 @@
+package generate_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tbx.at/secrets-generator/internal"
+	"tbx.at/secrets-generator/internal/generate"
+)
+
+const NewIdentityFileName = "new-identity"
+
+func TestRotateIdentity(t *testing.T) {
+	testbed := InitializeTest(t)
+
+	randomSecretName := testbed.GenerateSecretName()
+	templateSecretName := testbed.GenerateSecretName()
+	manualSecretName := testbed.GenerateSecretName()
+
+	config := internal.Config{
+		PublicKeys: testbed.PublicKeys,
+		Secrets: map[string]internal.Secret{
+			randomSecretName: {
+				Generation: internal.GenerationParams{
+					Random: &internal.GenerationParamsRandom{Length: 32, Charsets: RandomCharsets()},
+				},
+			},
+			templateSecretName: {
+				Generation: internal.GenerationParams{
+					Template: &internal.GenerationParamsTemplate{
+						Data:    map[string]any{"Secret": randomSecretName},
+						Content: `password={{ readSecret .Secret | printf "%s" }}`,
+					},
+				},
+			},
+			manualSecretName: {},
+		},
+		SecretMounts: RandomMounts(map[string]int{
+			randomSecretName:   2,
+			templateSecretName: 1,
+			manualSecretName:   1,
+		}),
+	}
+
+	testbed.RunGenerator(t, config)
+	testbed.WriteSecret(t, testbed.RecipientsForSecret(config.SecretMounts, manualSecretName), manualSecretName, "manual")
+
+	secretNames := []string{randomSecretName, templateSecretName, manualSecretName}
+
+	secrets := make(map[string]string)
+	for _, secretName := range secretNames {
+		secrets[secretName] = testbed.ReadSecret(t, testbed.IdentitiesForSecret(config.SecretMounts, secretName), secretName)
+	}
+	entropy := testbed.ReadEntropy(t, randomSecretName)
+
+	oldIdentities := testbed.GeneratorIdentities
+	newIdentity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(NewIdentityFileName, []byte(newIdentity.String()+"\n"), 0600))
+
+	rotateIdentity := func() {
+		options := generate.Options{IdentityPath: IdentityFileName}
+		require.NoError(t, generate.RotateIdentity(context.Background(), config, options, NewIdentityFileName))
+	}
+
+	rotateIdentity()
+	testbed.GeneratorIdentities = []*age.X25519Identity{newIdentity}
+
+	assertRotated := func(t *testing.T) {
+		for _, secretName := range secretNames {
+			assert.Equal(t, secrets[secretName], testbed.ReadSecret(t, testbed.IdentitiesForSecret(config.SecretMounts, secretName), secretName), "hosts can still decrypt the secret")
+			assert.True(t, decryptable(testbed.ReadSecretFile(t, secretName), newIdentity), "new identity can decrypt the secret")
+			assert.False(t, decryptable(testbed.ReadSecretFile(t, secretName), oldIdentities[0]), "old identity can't decrypt the secret")
+		}
+
+		assert.Equal(t, entropy, testbed.ReadEntropy(t, randomSecretName))
+		assert.False(t, decryptable(testbed.ReadEntropyFile(t, randomSecretName), oldIdentities[0]), "old identity can't decrypt the entropy")
+
+		entries, err := os.ReadDir(filepath.Join(internal.SecretsDirectory, internal.SecretsDataDirectory))
+		require.NoError(t, err)
+		for _, entry := range entries {
+			assert.NotContains(t, entry.Name(), ".tmp-", "temporary files are removed")
+		}
+	}
+
+	assertRotated(t)
+
+	t.Run("resume", func(t *testing.T) {
+		// Simulate an interrupted rotation by encrypting one secret to the old identity again.
+		rotatedFile := testbed.ReadSecretFile(t, randomSecretName)
+
+		testbed.GeneratorIdentities = oldIdentities
+		testbed.WriteSecret(t, testbed.RecipientsForSecret(config.SecretMounts, manualSecretName), manualSecretName, secrets[manualSecretName])
+		testbed.GeneratorIdentities = []*age.X25519Identity{newIdentity}
+
+		rotateIdentity()
+		assertRotated(t)
+
+		assert.Equal(t, rotatedFile, testbed.ReadSecretFile(t, randomSecretName), "rotated files are left alone")
+	})
+
+	t.Run("generate", func(t *testing.T) {
+		require.NoError(t, os.Rename(NewIdentityFileName, IdentityFileName))
+
+		rotatedFile := testbed.ReadSecretFile(t, templateSecretName)
+		testbed.RunGenerator(t, config)
+		assert.Equal(t, rotatedFile, testbed.ReadSecretFile(t, templateSecretName), "secrets are unchanged for the new identity")
+	})
+}
+
+func decryptable(ciphertext []byte, identity age.Identity) bool {
+	decrypted, err := age.Decrypt(bytes.NewReader(ciphertext), identity)
+	if err != nil {
+		return false
+	}
+
+	_, err = io.ReadAll(decrypted)
+	return err == nil
+}
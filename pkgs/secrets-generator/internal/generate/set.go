@@ -0,0 +1,51 @@
+package generate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"tbx.at/secrets-generator/internal"
+)
+
+var (
+	ErrSetGenerated = errors.New("cannot set secret that is generated")
+	ErrSetUnknown   = errors.New("cannot set unknown secret")
+)
+
+// Set stores value as the secret called secretName and then runs Run, so that secrets that read it are regenerated.
+// The value is encrypted to the same recipients Run would use.
+// Only secrets without generation parameters can be set because Run would replace generated ones.
+func Set(ctx context.Context, config internal.Config, options Options, secretName string, value []byte) error {
+	secret, ok := config.Secrets[secretName]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrSetUnknown, secretName)
+	}
+
+	if secret.Generation.Type() != "" {
+		return fmt.Errorf("%w: %s", ErrSetGenerated, secretName)
+	}
+
+	_, generatorRecipients, err := options.generatorKeys()
+	if err != nil {
+		return err
+	}
+
+	recipients, err := internal.ParseRecipients(config.PublicKeys)
+	if err != nil {
+		return err
+	}
+
+	setRecipients, err := secretRecipients(config, recipients, generatorRecipients, secretName)
+	if err != nil {
+		return err
+	}
+
+	if err := writeSecretFile(ctx, options.storage(), options.encoding(), internal.EncodedSecretKey(options.encoding(), secretName), setRecipients, value); err != nil {
+		return err
+	}
+
+	options.logger().Info("wrote secret", "secret", secretName, "recipients", len(setRecipients))
+
+	return Run(ctx, config, options)
+}
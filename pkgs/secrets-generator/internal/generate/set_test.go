@@ -0,0 +1,57 @@
+package generate_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"tbx.at/secrets-generator/internal"
+	"tbx.at/secrets-generator/internal/generate"
+)
+
+func TestSet(t *testing.T) {
+	testbed := InitializeTest(t)
+
+	manualSecretName := testbed.GenerateSecretName()
+	dependentSecretName := testbed.GenerateSecretName()
+
+	config := internal.Config{
+		PublicKeys: testbed.PublicKeys,
+		Secrets: map[string]internal.Secret{
+			manualSecretName: {},
+			dependentSecretName: {
+				Generation: internal.GenerationParams{
+					Template: &internal.GenerationParamsTemplate{
+						Data:    map[string]any{"Secret": manualSecretName},
+						Content: `password={{ readSecret .Secret | printf "%s" }}`,
+					},
+				},
+			},
+		},
+		SecretMounts: RandomMounts(map[string]int{
+			manualSecretName:    2,
+			dependentSecretName: 1,
+		}),
+	}
+
+	options := generate.Options{IdentityPath: IdentityFileName}
+
+	assert.NoError(t, generate.Set(context.Background(), config, options, manualSecretName, []byte("hunter2")))
+	assert.Equal(t, "hunter2", testbed.ReadSecret(t, testbed.IdentitiesForSecret(config.SecretMounts, manualSecretName), manualSecretName))
+	assert.Equal(t, "password=hunter2", testbed.ReadSecret(t, testbed.IdentitiesForSecret(config.SecretMounts, dependentSecretName), dependentSecretName))
+
+	t.Run("dependents regenerated", func(t *testing.T) {
+		assert.NoError(t, generate.Set(context.Background(), config, options, manualSecretName, []byte("hunter3")))
+		assert.Equal(t, "password=hunter3", testbed.ReadSecret(t, testbed.IdentitiesForSecret(config.SecretMounts, dependentSecretName), dependentSecretName))
+	})
+
+	t.Run("generated", func(t *testing.T) {
+		err := generate.Set(context.Background(), config, options, dependentSecretName, []byte("hunter2"))
+		assert.ErrorIs(t, err, generate.ErrSetGenerated)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		err := generate.Set(context.Background(), config, options, "unknown", []byte("hunter2"))
+		assert.ErrorIs(t, err, generate.ErrSetUnknown)
+	})
+}
@@ -0,0 +1,123 @@
+package generate_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+	"tbx.at/secrets-generator/internal"
+	"tbx.at/secrets-generator/internal/generate"
+	"tbx.at/secrets-generator/internal/sops"
+	"tbx.at/secrets-generator/internal/storage"
+	"tbx.at/secrets-generator/internal/testutil"
+)
+
+func TestSOPS(t *testing.T) {
+	for _, format := range sops.Formats {
+		t.Run(format, func(t *testing.T) {
+			testbed := InitializeTest(t)
+
+			randomSecretName := testbed.GenerateSecretName()
+			jsonSecretName := testbed.GenerateSecretName()
+
+			config := internal.Config{
+				PublicKeys: testbed.PublicKeys,
+				Secrets: map[string]internal.Secret{
+					randomSecretName: {
+						Generation: internal.GenerationParams{
+							Random: &internal.GenerationParamsRandom{Length: 32, Charsets: RandomCharsets()},
+						},
+					},
+					jsonSecretName: {
+						Generation: internal.GenerationParams{
+							JSON: &internal.GenerationParamsJSON{
+								Format: format,
+								Content: map[string]any{
+									"user":    "admin",
+									"port":    float64(5432),
+									"ratio":   1.5,
+									"enabled": true,
+									"hash": testutil.JSONFunctionCall("hashArgon2id", map[string]any{
+										"data": testutil.JSONFunctionCall("readSecret", map[string]any{
+											"name": randomSecretName,
+										}),
+										"memory":      float64(1024),
+										"iterations":  float64(1),
+										"parallelism": float64(1),
+									}),
+								},
+							},
+						},
+					},
+				},
+				SecretMounts: RandomMounts(map[string]int{
+					randomSecretName: 1,
+					jsonSecretName:   2,
+				}),
+			}
+
+			memory := storage.NewMemory()
+			encoding := sops.Encoding{Format: format}
+			options := generate.Options{IdentityPath: IdentityFileName, Storage: memory, Encoding: encoding}
+
+			require.NoError(t, generate.Run(context.Background(), config, options))
+
+			readFile := func(secretName string) []byte {
+				encrypted, err := memory.Read(context.Background(), internal.EncodedSecretKey(encoding, secretName))
+				require.NoError(t, err)
+				return encrypted
+			}
+
+			readSecret := func(secretName string) string {
+				decrypted, err := encoding.Decrypt(readFile(secretName), testbed.IdentitiesForSecret(config.SecretMounts, secretName))
+				require.NoError(t, err)
+				return string(decrypted)
+			}
+
+			randomSecret := readSecret(randomSecretName)
+			assert.Len(t, randomSecret, 32)
+
+			// Values of JSON secrets are encrypted one by one.
+			var file map[string]any
+			require.NoError(t, yaml.Unmarshal(readFile(jsonSecretName), &file))
+			assert.Contains(t, file, "sops")
+			for _, key := range []string{"user", "port", "ratio", "enabled", "hash"} {
+				assert.Regexp(t, `^ENC\[AES256_GCM,`, file[key], key)
+			}
+
+			var content map[string]any
+			require.NoError(t, yaml.Unmarshal([]byte(readSecret(jsonSecretName)), &content))
+			assert.Equal(t, "admin", content["user"])
+			assert.Equal(t, 5432, content["port"])
+			assert.Equal(t, 1.5, content["ratio"])
+			assert.Equal(t, true, content["enabled"])
+			assert.Contains(t, content["hash"], "$argon2id$")
+
+			keys, err := memory.List(context.Background(), "")
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{
+				internal.EncodedSecretKey(encoding, randomSecretName),
+				internal.EncodedSecretKey(encoding, jsonSecretName),
+				internal.EntropyKey(randomSecretName),
+				internal.EntropyKey(jsonSecretName),
+			}, keys)
+
+			// Unchanged secrets are detected by decrypting the sops files.
+			encrypted := readFile(jsonSecretName)
+			require.NoError(t, generate.Run(context.Background(), config, options))
+			assert.Equal(t, encrypted, readFile(jsonSecretName))
+
+			// Secrets are still regenerated if anything they depend on changes.
+			require.NoError(t, generate.Run(context.Background(), config, generate.Options{
+				IdentityPath: IdentityFileName,
+				Storage:      memory,
+				Encoding:     encoding,
+				Rotate:       []string{randomSecretName},
+			}))
+			assert.NotEqual(t, encrypted, readFile(jsonSecretName))
+			assert.NotEqual(t, randomSecret, readSecret(randomSecretName))
+		})
+	}
+}
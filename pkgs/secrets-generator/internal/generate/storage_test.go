@@ -0,0 +1,88 @@
+package generate_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tbx.at/secrets-generator/internal"
+	"tbx.at/secrets-generator/internal/generate"
+	"tbx.at/secrets-generator/internal/storage"
+)
+
+func TestStorage(t *testing.T) {
+	testbed := InitializeTest(t)
+
+	randomSecretName := testbed.GenerateSecretName()
+	templateSecretName := testbed.GenerateSecretName()
+
+	config := internal.Config{
+		PublicKeys: testbed.PublicKeys,
+		Secrets: map[string]internal.Secret{
+			randomSecretName: {
+				Generation: internal.GenerationParams{
+					Random: &internal.GenerationParamsRandom{Length: 32, Charsets: RandomCharsets()},
+				},
+			},
+			templateSecretName: {
+				Generation: internal.GenerationParams{
+					Template: &internal.GenerationParamsTemplate{
+						Data:    map[string]any{"Secret": randomSecretName},
+						Content: `password={{ readSecret .Secret | printf "%s" }}`,
+					},
+				},
+			},
+		},
+		SecretMounts: RandomMounts(map[string]int{
+			randomSecretName:   1,
+			templateSecretName: 1,
+		}),
+	}
+
+	memory := storage.NewMemory()
+	options := generate.Options{IdentityPath: IdentityFileName, Storage: memory}
+
+	require.NoError(t, generate.Run(context.Background(), config, options))
+
+	readSecret := func(secretName string) string {
+		encrypted, err := memory.Read(context.Background(), internal.SecretKey(secretName))
+		require.NoError(t, err)
+
+		decrypted, err := age.Decrypt(bytes.NewReader(encrypted), testbed.IdentitiesForSecret(config.SecretMounts, secretName)...)
+		require.NoError(t, err)
+
+		secret, err := io.ReadAll(decrypted)
+		require.NoError(t, err)
+
+		return string(secret)
+	}
+
+	randomSecret := readSecret(randomSecretName)
+	assert.Len(t, randomSecret, 32)
+	assert.Equal(t, "password="+randomSecret, readSecret(templateSecretName))
+
+	keys, err := memory.List(context.Background(), "")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		internal.SecretKey(randomSecretName),
+		internal.SecretKey(templateSecretName),
+		internal.EntropyKey(randomSecretName),
+		internal.EntropyKey(templateSecretName),
+	}, keys)
+
+	assert.NoFileExists(t, internal.SecretFilePath(randomSecretName), "nothing is written to the local storage")
+
+	// Unchanged secrets are detected through the storage as well.
+	encrypted, err := memory.Read(context.Background(), internal.SecretKey(templateSecretName))
+	require.NoError(t, err)
+
+	require.NoError(t, generate.Run(context.Background(), config, options))
+
+	reencrypted, err := memory.Read(context.Background(), internal.SecretKey(templateSecretName))
+	require.NoError(t, err)
+	assert.Equal(t, encrypted, reencrypted)
+}
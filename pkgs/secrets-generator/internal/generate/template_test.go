@@ -1,6 +1,7 @@
 package generate_test
 
 import (
+	"context"
 	"crypto/rand"
 	"fmt"
 	mathrand "math/rand"
@@ -9,6 +10,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"tbx.at/secrets-generator/internal"
+	"tbx.at/secrets-generator/internal/generate"
 	"tbx.at/secrets-generator/internal/rand/argon2id"
 	"tbx.at/secrets-generator/internal/rand/bcrypt"
 	"tbx.at/secrets-generator/internal/rand/password"
@@ -137,6 +139,103 @@ func TestTemplateStringReplace(t *testing.T) {
 	})
 }
 
+func TestTemplateEncodingFunctions(t *testing.T) {
+	testTemplateFunction(t, templateTestParameters{
+		Data:     map[string]any{},
+		Template: `{{ split "," "a,b" | join "-" | upper | base64Encode }}`,
+
+		CheckOutput: func(t *testing.T, parameters templateTestCheckParameters) {
+			assert.Equal(t, "QS1C", parameters.output)
+		},
+	})
+}
+
+func TestTemplateDecodingFunctions(t *testing.T) {
+	testTemplateFunction(t, templateTestParameters{
+		Data:     map[string]any{},
+		Template: "{{ base64Decode \"aGk=\" }} {{ base64URLDecode \"aGk\" }} {{ hexDecode \"6869\" }} {{ fromJSON `{\"a\": [1, \"b\"]}` }} {{ index (fromJSON `{\"a\": [1, \"b\"]}`).a 1 }}",
+
+		CheckOutput: func(t *testing.T, parameters templateTestCheckParameters) {
+			assert.Equal(t, `hi hi hi {"a":[1,"b"]} b`, parameters.output)
+		},
+	})
+}
+
+func TestTemplateStrict(t *testing.T) {
+	for name, test := range map[string]struct {
+		content  string
+		data     map[string]any
+		expected string
+	}{
+		"missing key": {
+			content:  "user = {{ .User }}\npassword = {{ .Pasword }}",
+			data:     map[string]any{"User": "admin", "Password": "hunter2"},
+			expected: `template: %s:2:14: executing "%[1]s" at <.Pasword>: map has no entry for key "Pasword"`,
+		},
+		"nil value": {
+			content:  "user = {{ .User }}\npassword = {{ .Password }}",
+			data:     map[string]any{"User": "admin", "Password": nil},
+			expected: "template: %s: template output contains <no value> at output line 2, column 12",
+		},
+		"parse error": {
+			content:  "user = {{ .User }}\npassword = {{ .Password }",
+			data:     map[string]any{},
+			expected: "template: %s:2: unexpected \"}\" in operand",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			testbed := InitializeTest(t)
+			secretName := testbed.GenerateSecretName()
+
+			err := generate.Run(context.Background(), internal.Config{
+				PublicKeys: testbed.PublicKeys,
+				Secrets: map[string]internal.Secret{
+					secretName: {
+						Generation: internal.GenerationParams{
+							Template: &internal.GenerationParamsTemplate{
+								Data:    test.data,
+								Content: test.content,
+							},
+						},
+					},
+				},
+			}, generate.Options{IdentityPath: IdentityFileName})
+
+			assert.ErrorContains(t, err, fmt.Sprintf(test.expected, secretName))
+		})
+	}
+}
+
+func TestTemplateNotStrict(t *testing.T) {
+	testbed := InitializeTest(t)
+	secretName := testbed.GenerateSecretName()
+
+	strict := false
+
+	config := internal.Config{
+		PublicKeys: testbed.PublicKeys,
+		Secrets: map[string]internal.Secret{
+			secretName: {
+				Generation: internal.GenerationParams{
+					Template: &internal.GenerationParamsTemplate{
+						Data:    map[string]any{},
+						Content: "{{ .Missing }}",
+						Strict:  &strict,
+					},
+				},
+			},
+		},
+		SecretMounts: RandomMounts(map[string]int{
+			secretName: 1,
+		}),
+	}
+
+	testbed.RunGenerator(t, config)
+
+	output := testbed.ReadSecret(t, testbed.IdentitiesForSecret(config.SecretMounts, secretName), secretName)
+	assert.Equal(t, "<no value>", output)
+}
+
 type templateTestParameters struct {
 	Data        map[string]any
 	Template    string
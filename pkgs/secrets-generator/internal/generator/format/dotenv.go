@@ -0,0 +1,49 @@
+package format
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var dotenvKeyRX = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// dotenvEscaper escapes the characters that are special inside double quotes.
+// The same escaping is understood by systemd's EnvironmentFile= and POSIX shells, so the files can be used with both.
+// Line breaks are written as \n and \r like dotenv libraries expect, so that every variable stays on one line.
+// Shells and systemd read those as a backslash followed by a letter instead.
+var dotenvEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"$", `\$`,
+	"`", "\\`",
+	"\n", `\n`,
+	"\r", `\r`,
+)
+
+// encodeDotenv writes an object with scalar values as KEY="value" lines.
+func encodeDotenv(w io.Writer, value any) error {
+	object, ok := value.(map[string]any)
+	if !ok {
+		return fmt.Errorf("%w for %s: got %T", ErrNotAnObject, FormatDotenv, value)
+	}
+
+	var out strings.Builder
+
+	for _, key := range sortedKeys(object) {
+		if !dotenvKeyRX.MatchString(key) {
+			return fmt.Errorf("%w for %s: %q is not a valid variable name", ErrInvalidKey, FormatDotenv, key)
+		}
+
+		str, err := scalarString(key, object[key])
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(&out, "%s=\"%s\"\n", key, dotenvEscaper.Replace(str))
+	}
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
@@ -0,0 +1,117 @@
+// Package format serializes the values produced by the JSON generator into configuration file formats.
+package format
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Names of the formats as used in the configuration.
+const (
+	FormatDotenv = "dotenv"
+	FormatINI    = "ini"
+	FormatJSON   = "json"
+	FormatTOML   = "toml"
+	FormatYAML   = "yaml"
+)
+
+// Formats lists the names of all formats.
+var Formats = []string{FormatDotenv, FormatINI, FormatJSON, FormatTOML, FormatYAML}
+
+var (
+	ErrUnknownFormat = errors.New("unknown output format")
+	ErrNotAnObject   = errors.New("value must be an object")
+	ErrNotAScalar    = errors.New("value must be a string, number, boolean or null")
+	ErrInvalidKey    = errors.New("invalid key")
+)
+
+// Encode writes value to w in the given format.
+// Objects are written with their keys sorted so that the output is deterministic.
+// Values are normalized for every format.
+// For JSON this only changes byte slices, like secrets returned by readSecret, which are written as text instead of base64.
+func Encode(w io.Writer, format string, value any) error {
+	switch format {
+	case "", FormatJSON:
+		return json.NewEncoder(w).Encode(Normalize(value))
+	case FormatDotenv:
+		return encodeDotenv(w, Normalize(value))
+	case FormatINI:
+		return encodeINI(w, Normalize(value))
+	case FormatTOML:
+		object, ok := Normalize(value).(map[string]any)
+		if !ok {
+			return fmt.Errorf("%w for %s: got %T", ErrNotAnObject, FormatTOML, value)
+		}
+
+		return toml.NewEncoder(w).Encode(object)
+	case FormatYAML:
+		encoder := yaml.NewEncoder(w)
+		if err := encoder.Encode(Normalize(value)); err != nil {
+			return err
+		}
+
+		return encoder.Close()
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+}
+
+// Normalize prepares values for encoding so that all encoders agree on them.
+// Byte slices (like the contents of secrets) become strings instead of base64 or lists of numbers,
+// and floats without a fractional part (all numbers from JSON are floats) become integers.
+func Normalize(value any) any {
+	switch value := value.(type) {
+	case []byte:
+		return string(value)
+	case float64:
+		if value == math.Trunc(value) && value >= math.MinInt64 && value < math.MaxInt64 {
+			return int64(value)
+		}
+		return value
+	case []any:
+		normalized := make([]any, len(value))
+		for i, v := range value {
+			normalized[i] = Normalize(v)
+		}
+		return normalized
+	case map[string]any:
+		normalized := make(map[string]any, len(value))
+		for k, v := range value {
+			normalized[k] = Normalize(v)
+		}
+		return normalized
+	default:
+		return value
+	}
+}
+
+func sortedKeys(object map[string]any) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+// scalarString formats scalar values for formats that only know strings.
+func scalarString(key string, value any) (string, error) {
+	switch value := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case bool, int64, float64:
+		return fmt.Sprint(value), nil
+	default:
+		return "", fmt.Errorf("%w: %s: got %T", ErrNotAScalar, key, value)
+	}
+}
@@ -0,0 +1,128 @@
+package format_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+	"tbx.at/secrets-generator/internal/generator/format"
+)
+
+var value = map[string]any{
+	"name":    "app",
+	"port":    float64(8080),
+	"ratio":   0.5,
+	"enabled": true,
+	"secret":  []byte("pa\"ss $word\n;#`\\"),
+	"db": map[string]any{
+		"user": "admin",
+		"host": " db ",
+	},
+}
+
+func encode(t *testing.T, name string, value any) string {
+	out := new(bytes.Buffer)
+	require.NoError(t, format.Encode(out, name, value))
+	return out.String()
+}
+
+func TestEncodeYAML(t *testing.T) {
+	assert.Equal(t, `db:
+    host: ' db '
+    user: admin
+enabled: true
+name: app
+port: 8080
+ratio: 0.5
+secret: |-
+    pa"ss $word
+    ;#`+"`"+`\
+`, encode(t, format.FormatYAML, value))
+
+	var decoded map[string]any
+	require.NoError(t, yaml.Unmarshal([]byte(encode(t, format.FormatYAML, value)), &decoded))
+	assert.Equal(t, string(value["secret"].([]byte)), decoded["secret"])
+}
+
+func TestEncodeTOML(t *testing.T) {
+	assert.Equal(t, `enabled = true
+name = "app"
+port = 8080
+ratio = 0.5
+secret = "pa\"ss $word\n;#`+"`"+`\\"
+
+[db]
+  host = " db "
+  user = "admin"
+`, encode(t, format.FormatTOML, value))
+
+	var decoded map[string]any
+	_, err := toml.Decode(encode(t, format.FormatTOML, value), &decoded)
+	require.NoError(t, err)
+	assert.Equal(t, string(value["secret"].([]byte)), decoded["secret"])
+	assert.Equal(t, " db ", decoded["db"].(map[string]any)["host"])
+}
+
+func TestEncodeINI(t *testing.T) {
+	assert.Equal(t, `enabled = true
+name = app
+port = 8080
+ratio = 0.5
+secret = "pa\"ss $word\n;#`+"`"+`\\"
+
+[db]
+host = " db "
+user = admin
+`, encode(t, format.FormatINI, value))
+}
+
+func TestEncodeDotenv(t *testing.T) {
+	assert.Equal(t, `A_B="1"
+EMPTY=""
+SECRET="pa\"ss \$word\n;#\`+"`"+`\\"
+`, encode(t, format.FormatDotenv, map[string]any{
+		"SECRET": value["secret"],
+		"A_B":    float64(1),
+		"EMPTY":  nil,
+	}))
+}
+
+func TestEncodeJSONUnchanged(t *testing.T) {
+	assert.Equal(t, "{\"a\":1,\"b\":\"\\u003c\\u003e\"}\n", encode(t, format.FormatJSON, map[string]any{"b": "<>", "a": float64(1)}))
+	assert.Equal(t, encode(t, format.FormatJSON, value), encode(t, "", value))
+}
+
+func TestEncodeJSONBytes(t *testing.T) {
+	assert.Equal(t, "{\"port\":8080,\"secret\":\"hi\"}\n", encode(t, format.FormatJSON, map[string]any{"secret": []byte("hi"), "port": float64(8080)}))
+}
+
+func TestEncodeDotenvLineBreaks(t *testing.T) {
+	encoded := encode(t, format.FormatDotenv, map[string]any{"CERT": "line 1\r\nline 2\n", "NEXT": "x"})
+	assert.Equal(t, "CERT=\"line 1\\r\\nline 2\\n\"\nNEXT=\"x\"\n", encoded)
+}
+
+func TestEncodeErrors(t *testing.T) {
+	for _, test := range []struct {
+		format   string
+		value    any
+		expected error
+	}{
+		{"xml", map[string]any{}, format.ErrUnknownFormat},
+		{format.FormatTOML, []any{}, format.ErrNotAnObject},
+		{format.FormatINI, "string", format.ErrNotAnObject},
+		{format.FormatDotenv, []any{}, format.ErrNotAnObject},
+		{format.FormatINI, map[string]any{"s": map[string]any{"nested": map[string]any{}}}, format.ErrNotAScalar},
+		{format.FormatINI, map[string]any{"list": []any{}}, format.ErrNotAScalar},
+		{format.FormatINI, map[string]any{"a=b": "c"}, format.ErrInvalidKey},
+		{format.FormatINI, map[string]any{"a]": map[string]any{}}, format.ErrInvalidKey},
+		{format.FormatDotenv, map[string]any{"A": map[string]any{}}, format.ErrNotAScalar},
+		{format.FormatDotenv, map[string]any{"1A": "b"}, format.ErrInvalidKey},
+		{format.FormatDotenv, map[string]any{"A-B": "b"}, format.ErrInvalidKey},
+	} {
+		err := format.Encode(new(bytes.Buffer), test.format, test.value)
+		assert.ErrorIs(t, err, test.expected, "%s %v", test.format, test.value)
+	}
+}
@@ -0,0 +1,88 @@
+package format
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// iniEscaper escapes the characters that are special inside double quotes.
+var iniEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\t", `\t`,
+)
+
+// encodeINI writes an object as an INI file.
+// Scalar values at the top level are written before the first section, objects become sections.
+// Sections can only contain scalar values.
+func encodeINI(w io.Writer, value any) error {
+	object, ok := value.(map[string]any)
+	if !ok {
+		return fmt.Errorf("%w for %s: got %T", ErrNotAnObject, FormatINI, value)
+	}
+
+	var globals, sections []string
+
+	for _, key := range sortedKeys(object) {
+		if _, ok := object[key].(map[string]any); ok {
+			sections = append(sections, key)
+		} else {
+			globals = append(globals, key)
+		}
+	}
+
+	var out strings.Builder
+
+	if err := writeINIPairs(&out, "", object, globals); err != nil {
+		return err
+	}
+
+	for i, section := range sections {
+		if section == "" || strings.ContainsAny(section, "[]\r\n") {
+			return fmt.Errorf("%w for %s: %q is not a valid section name", ErrInvalidKey, FormatINI, section)
+		}
+
+		if i > 0 || len(globals) > 0 {
+			out.WriteByte('\n')
+		}
+
+		fmt.Fprintf(&out, "[%s]\n", section)
+
+		sectionObject := object[section].(map[string]any)
+		if err := writeINIPairs(&out, section+".", sectionObject, sortedKeys(sectionObject)); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+func writeINIPairs(out *strings.Builder, prefix string, object map[string]any, keys []string) error {
+	for _, key := range keys {
+		if key == "" || key != strings.TrimSpace(key) || strings.ContainsAny(key, "=[]\"\\;#\r\n") {
+			return fmt.Errorf("%w for %s: %q is not a valid key", ErrInvalidKey, FormatINI, prefix+key)
+		}
+
+		str, err := scalarString(prefix+key, object[key])
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "%s = %s\n", key, quoteINI(str))
+	}
+
+	return nil
+}
+
+// quoteINI quotes values that would otherwise be changed by an INI parser, like values with comment characters or surrounding whitespace.
+func quoteINI(str string) string {
+	if str != "" && str == strings.TrimSpace(str) && !strings.ContainsAny(str, "\"\\;#=\r\n\t") {
+		return str
+	}
+
+	return `"` + iniEscaper.Replace(str) + `"`
+}
@@ -0,0 +1,141 @@
+package functions
+
+import (
+	"fmt"
+	"strings"
+
+	"tbx.at/secrets-generator/internal/rand/argon2id"
+	"tbx.at/secrets-generator/internal/rand/bcrypt"
+	"tbx.at/secrets-generator/internal/rand/crypt"
+	"tbx.at/secrets-generator/internal/rand/django"
+	"tbx.at/secrets-generator/internal/rand/ssha"
+	"tbx.at/secrets-generator/internal/rand/yescrypt"
+)
+
+func init() {
+	register(
+		&Function{
+			Name: "fmt",
+			Params: []Param{
+				{Name: "format", Type: TypeString},
+				{Name: "args", Type: TypeList, Variadic: true},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				return fmt.Sprintf(args[0].(string), args[1].([]any)...), nil
+			},
+		},
+
+		&Function{
+			Name: "hashAPR1",
+			Params: []Param{
+				{Name: "data", Type: TypeBytes},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				return crypt.APR1(ctx.RNG, args[0].([]byte))
+			},
+		},
+
+		&Function{
+			Name: "hashArgon2id",
+			Params: []Param{
+				{Name: "data", Type: TypeBytes},
+				{Name: "memory", Type: TypeInt},
+				{Name: "iterations", Type: TypeInt},
+				{Name: "parallelism", Type: TypeInt},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				return argon2id.CreateHash(ctx.RNG, string(args[0].([]byte)), &argon2id.Params{
+					Memory:      uint32(args[1].(int)),
+					Iterations:  uint32(args[2].(int)),
+					Parallelism: uint8(args[3].(int)),
+					SaltLength:  16,
+					KeyLength:   32,
+				})
+			},
+		},
+
+		&Function{
+			Name: "hashBcrypt",
+			Params: []Param{
+				{Name: "data", Type: TypeBytes},
+				{Name: "rounds", Type: TypeInt},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				hash, err := bcrypt.GenerateFromPassword(ctx.RNG, args[0].([]byte), args[1].(int))
+				if err != nil {
+					return nil, err
+				}
+
+				return string(hash), nil
+			},
+		},
+
+		&Function{
+			Name: "hashPBKDF2SHA256",
+			Params: []Param{
+				{Name: "data", Type: TypeBytes},
+				{Name: "iterations", Type: TypeInt},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				return django.PBKDF2SHA256(ctx.RNG, args[0].([]byte), args[1].(int))
+			},
+		},
+
+		&Function{
+			Name: "hashSHA512Crypt",
+			Params: []Param{
+				{Name: "data", Type: TypeBytes},
+				{Name: "rounds", Type: TypeInt},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				return crypt.SHA512(ctx.RNG, args[0].([]byte), args[1].(int))
+			},
+		},
+
+		&Function{
+			Name: "hashSSHA",
+			Params: []Param{
+				{Name: "data", Type: TypeBytes},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				return ssha.Hash(ctx.RNG, args[0].([]byte))
+			},
+		},
+
+		&Function{
+			Name: "hashScrypt",
+			Params: []Param{
+				{Name: "data", Type: TypeBytes},
+				{Name: "n", Type: TypeInt},
+				{Name: "r", Type: TypeInt},
+				{Name: "p", Type: TypeInt},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				return django.Scrypt(ctx.RNG, args[0].([]byte), args[1].(int), args[2].(int), args[3].(int))
+			},
+		},
+
+		&Function{
+			Name: "hashYescrypt",
+			Params: []Param{
+				{Name: "data", Type: TypeBytes},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				return yescrypt.Hash(ctx.RNG, args[0].([]byte))
+			},
+		},
+
+		&Function{
+			Name: "stringReplace",
+			Params: []Param{
+				{Name: "s", Type: TypeString},
+				{Name: "old", Type: TypeString},
+				{Name: "new", Type: TypeString},
+				{Name: "n", Type: TypeInt},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				return strings.Replace(args[0].(string), args[1].(string), args[2].(string), args[3].(int)), nil
+			},
+		},
+	)
+}
@@ -0,0 +1,193 @@
+package functions
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"tbx.at/secrets-generator/internal/generator/format"
+)
+
+func init() {
+	register(
+		&Function{
+			Name: "base64Decode",
+			Params: []Param{
+				{Name: "data", Type: TypeBytes},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				decoded, err := base64.StdEncoding.DecodeString(string(args[0].([]byte)))
+				return string(decoded), err
+			},
+		},
+
+		&Function{
+			Name: "base64Encode",
+			Params: []Param{
+				{Name: "data", Type: TypeBytes},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				return base64.StdEncoding.EncodeToString(args[0].([]byte)), nil
+			},
+		},
+
+		&Function{
+			Name: "base64URLDecode",
+			Params: []Param{
+				{Name: "data", Type: TypeBytes},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				// Padding is often left out in URLs, so both forms are accepted.
+				decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(string(args[0].([]byte)), "="))
+				return string(decoded), err
+			},
+		},
+
+		&Function{
+			Name: "base64URLEncode",
+			Params: []Param{
+				{Name: "data", Type: TypeBytes},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				return base64.URLEncoding.EncodeToString(args[0].([]byte)), nil
+			},
+		},
+
+		&Function{
+			Name: "fromJSON",
+			Params: []Param{
+				{Name: "data", Type: TypeBytes},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				var value any
+				if err := json.Unmarshal(args[0].([]byte), &value); err != nil {
+					return nil, err
+				}
+
+				return value, nil
+			},
+		},
+
+		&Function{
+			Name: "hexDecode",
+			Params: []Param{
+				{Name: "data", Type: TypeBytes},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				decoded, err := hex.DecodeString(string(args[0].([]byte)))
+				return string(decoded), err
+			},
+		},
+
+		&Function{
+			Name: "hexEncode",
+			Params: []Param{
+				{Name: "data", Type: TypeBytes},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				return hex.EncodeToString(args[0].([]byte)), nil
+			},
+		},
+
+		&Function{
+			Name: "shellQuote",
+			Params: []Param{
+				{Name: "data", Type: TypeBytes},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				return "'" + strings.ReplaceAll(string(args[0].([]byte)), "'", `'\''`) + "'", nil
+			},
+		},
+
+		&Function{
+			Name: "toJSON",
+			Params: []Param{
+				{Name: "value", Type: TypeAny},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				buf := new(bytes.Buffer)
+
+				encoder := json.NewEncoder(buf)
+				encoder.SetEscapeHTML(false)
+
+				if err := encoder.Encode(format.Normalize(args[0])); err != nil {
+					return nil, err
+				}
+
+				return strings.TrimSuffix(buf.String(), "\n"), nil
+			},
+		},
+
+		&Function{
+			Name: "toDotenv",
+			Params: []Param{
+				{Name: "value", Type: TypeAny},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				return encode(format.FormatDotenv, args[0])
+			},
+		},
+
+		&Function{
+			Name: "toINI",
+			Params: []Param{
+				{Name: "value", Type: TypeAny},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				return encode(format.FormatINI, args[0])
+			},
+		},
+
+		&Function{
+			Name: "toTOML",
+			Params: []Param{
+				{Name: "value", Type: TypeAny},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				return encode(format.FormatTOML, args[0])
+			},
+		},
+
+		&Function{
+			Name: "toYAML",
+			Params: []Param{
+				{Name: "value", Type: TypeAny},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				return encode(format.FormatYAML, args[0])
+			},
+		},
+
+		&Function{
+			Name: "urlPathEscape",
+			Params: []Param{
+				{Name: "data", Type: TypeBytes},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				return url.PathEscape(string(args[0].([]byte))), nil
+			},
+		},
+
+		&Function{
+			Name: "urlQueryEscape",
+			Params: []Param{
+				{Name: "data", Type: TypeBytes},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				return url.QueryEscape(string(args[0].([]byte))), nil
+			},
+		},
+	)
+}
+
+func encode(name string, value any) (string, error) {
+	buf := new(bytes.Buffer)
+	if err := format.Encode(buf, name, value); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,70 @@
+package functions_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tbx.at/secrets-generator/internal/generator/format"
+	"tbx.at/secrets-generator/internal/generator/functions"
+)
+
+var encodingTests = []struct {
+	name     string
+	args     []any
+	expected any
+}{
+	{"base64Decode", []any{"aGk/Pz4+"}, "hi??>>"},
+	{"base64Encode", []any{[]byte("hi??>>")}, "aGk/Pz4+"},
+	{"base64URLDecode", []any{"aGk_Pz4-"}, "hi??>>"},
+	{"base64URLDecode", []any{"aGk"}, "hi"},
+	{"base64URLDecode", []any{"aGk="}, "hi"},
+	{"base64URLEncode", []any{"hi??>>"}, "aGk_Pz4-"},
+	{"fromJSON", []any{[]byte(`{"a":[1,"b"]}`)}, map[string]any{"a": []any{float64(1), "b"}}},
+	{"hexDecode", []any{"00ff"}, "\x00\xff"},
+	{"hexEncode", []any{[]byte{0x00, 0xff}}, "00ff"},
+	{"hmacSHA256", []any{"key", "The quick brown fox jumps over the lazy dog"}, "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+	{"join", []any{",", []any{"a", []byte("b"), float64(1)}}, "a,b,1"},
+	{"lower", []any{"AbC"}, "abc"},
+	{"sha256sum", []any{"abc"}, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+	{"shellQuote", []any{"it's"}, `'it'\''s'`},
+	{"split", []any{",", []byte("a,b")}, []any{"a", "b"}},
+	{"toDotenv", []any{map[string]any{"B": "it's \"$HOME\"", "A": float64(1)}}, "A=\"1\"\nB=\"it's \\\"\\$HOME\\\"\"\n"},
+	{"toINI", []any{map[string]any{"s": map[string]any{"k": "a;b"}, "g": true}}, "g = true\n\n[s]\nk = \"a;b\"\n"},
+	{"toJSON", []any{map[string]any{"b": []byte("<secret>"), "a": float64(1), "c": 1.5}}, `{"a":1,"b":"<secret>","c":1.5}`},
+	{"toTOML", []any{map[string]any{"b": []byte("secret"), "a": float64(1), "t": map[string]any{"c": true}}}, "a = 1\nb = \"secret\"\n\n[t]\n  c = true\n"},
+	{"toYAML", []any{map[string]any{"b": []byte("secret"), "a": float64(1)}}, "a: 1\nb: secret\n"},
+	{"trim", []any{[]byte(" abc\n")}, "abc"},
+	{"upper", []any{"AbC"}, "ABC"},
+	{"urlPathEscape", []any{"a b/c"}, "a%20b%2Fc"},
+	{"urlQueryEscape", []any{"a b&c"}, "a+b%26c"},
+}
+
+// TestEncodingFunctions checks that the functions give the same result for named and positional arguments.
+func TestEncodingFunctions(t *testing.T) {
+	for _, test := range encodingTests {
+		function, err := functions.Lookup(test.name)
+		require.NoError(t, err)
+
+		positional, err := function.CallPositional(ctx, test.args)
+		require.NoError(t, err, test.name)
+		assert.Equal(t, test.expected, positional, test.name)
+
+		named := make(map[string]any, len(test.args))
+		for i, param := range function.Params {
+			named[param.Name] = test.args[i]
+		}
+
+		namedResult, err := function.CallNamed(ctx, named)
+		require.NoError(t, err, test.name)
+		assert.Equal(t, test.expected, namedResult, test.name)
+	}
+}
+
+func TestToTOMLNotATable(t *testing.T) {
+	function, err := functions.Lookup("toTOML")
+	require.NoError(t, err)
+
+	_, err = function.CallPositional(ctx, []any{[]any{"a"}})
+	assert.ErrorIs(t, err, format.ErrNotAnObject)
+}
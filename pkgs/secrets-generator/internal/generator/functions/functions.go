@@ -0,0 +1,337 @@
+// Package functions contains the functions that are available to the JSON and template generators.
+//
+// Every function declares its parameters once.
+// The JSON generator passes arguments by parameter name, the template generator passes them by position.
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"slices"
+	"strings"
+
+	"tbx.at/secrets-generator/internal"
+)
+
+var (
+	ErrArgumentMissing      = errors.New("function argument is missing")
+	ErrArgumentUnknown      = errors.New("function argument is unknown")
+	ErrArgumentWrongType    = errors.New("function argument has wrong type")
+	ErrArgumentCount        = errors.New("wrong number of function arguments")
+	ErrFunctionDoesNotExist = errors.New("function does not exist")
+	ErrCancelled            = errors.New("function call cancelled")
+	ErrUndeclaredDependency = errors.New("secret is read without being a dependency")
+)
+
+// Type is the type of a function parameter.
+// Arguments are converted to the Go type noted for each Type before they are passed to a function.
+type Type int
+
+const (
+	// TypeAny accepts any value (any).
+	TypeAny Type = iota
+
+	// TypeBytes accepts strings and byte slices ([]byte).
+	// Other values are formatted with fmt.Sprint.
+	TypeBytes
+
+	// TypeInt accepts integers and floats without a fractional part (int).
+	TypeInt
+
+	// TypeList accepts lists ([]any).
+	TypeList
+
+	// TypeString accepts strings (string).
+	TypeString
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeAny:
+		return "any"
+	case TypeBytes:
+		return "bytes"
+	case TypeInt:
+		return "int"
+	case TypeList:
+		return "list"
+	case TypeString:
+		return "string"
+	default:
+		return fmt.Sprintf("Type(%d)", int(t))
+	}
+}
+
+// Param is a parameter of a function.
+type Param struct {
+	Name string
+	Type Type
+
+	// Optional parameters can be left out, in which case nil is passed to the function.
+	// Only the last parameters of a function can be optional.
+	Optional bool
+
+	// Variadic marks the last parameter of a function as taking all remaining positional arguments.
+	// Its type must be TypeList. Named arguments pass a list instead.
+	Variadic bool
+
+	// Secret marks parameters that take the name of a secret the function reads.
+	Secret bool
+}
+
+// Context is passed to every function call.
+type Context struct {
+	context.Context
+
+	// RNG is the source of randomness for functions like salted hashes.
+	RNG io.Reader
+
+	Completion  *internal.CompletionMap
+	SecretStore *internal.SecretStore
+
+	// Dependencies holds the names of the secrets that may be read, including outputs.
+	// Any secret may be read if it is nil.
+	Dependencies map[string]bool
+
+	// Logger reports progress like waiting for other secrets. It may be nil.
+	Logger *slog.Logger
+}
+
+// Function is a function that can be called from generators.
+type Function struct {
+	Name   string
+	Params []Param
+
+	// Call is called with one argument per parameter, each converted to the Go type for its Type.
+	Call func(ctx Context, args []any) (any, error)
+}
+
+// Lookup returns the function called name.
+func Lookup(name string) (*Function, error) {
+	function, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrFunctionDoesNotExist, name)
+	}
+
+	return function, nil
+}
+
+// All returns all functions sorted by name.
+func All() []*Function {
+	all := make([]*Function, 0, len(registry))
+	for _, function := range registry {
+		all = append(all, function)
+	}
+
+	slices.SortFunc(all, func(a, b *Function) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return all
+}
+
+// CallNamed calls the function with arguments given by parameter name.
+func (f *Function) CallNamed(ctx Context, args map[string]any) (any, error) {
+	if err := f.CheckNamed(args); err != nil {
+		return nil, err
+	}
+
+	converted := make([]any, len(f.Params))
+
+	for i, param := range f.Params {
+		arg, ok := args[param.Name]
+		if !ok {
+			continue
+		}
+
+		var err error
+		converted[i], err = f.convert(param, arg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return f.Call(ctx, converted)
+}
+
+// CheckNamed checks that arguments given by parameter name match the parameters of the function.
+// Only the names are checked, not the values.
+func (f *Function) CheckNamed(args map[string]any) error {
+	for name := range args {
+		if !slices.ContainsFunc(f.Params, func(param Param) bool { return param.Name == name }) {
+			return fmt.Errorf("%w in call to %s: %s", ErrArgumentUnknown, f.Name, name)
+		}
+	}
+
+	for _, param := range f.Params {
+		if _, ok := args[param.Name]; !ok && !param.Optional {
+			return fmt.Errorf("%w in call to %s: %s", ErrArgumentMissing, f.Name, param.Name)
+		}
+	}
+
+	return nil
+}
+
+// CallPositional calls the function with arguments given in the order of its parameters.
+func (f *Function) CallPositional(ctx Context, args []any) (any, error) {
+	if err := f.CheckPositional(len(args)); err != nil {
+		return nil, err
+	}
+
+	params := f.Params
+
+	if len(params) > 0 && params[len(params)-1].Variadic {
+		fixed := len(params) - 1
+		args = append(slices.Clip(args[:fixed]), []any(slices.Clone(args[fixed:])))
+	}
+
+	converted := make([]any, len(params))
+
+	for i, param := range params[:len(args)] {
+		var err error
+		converted[i], err = f.convert(param, args[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return f.Call(ctx, converted)
+}
+
+// CheckPositional checks that the function can be called with count positional arguments.
+func (f *Function) CheckPositional(count int) error {
+	params := f.Params
+
+	if len(params) > 0 && params[len(params)-1].Variadic {
+		if fixed := len(params) - 1; count < fixed {
+			return fmt.Errorf("%w in call to %s: wanted at least %d, got %d", ErrArgumentCount, f.Name, fixed, count)
+		}
+
+		return nil
+	}
+
+	if required := f.required(); count < required || count > len(params) {
+		if required == len(params) {
+			return fmt.Errorf("%w in call to %s: wanted %d, got %d", ErrArgumentCount, f.Name, len(params), count)
+		}
+
+		return fmt.Errorf("%w in call to %s: wanted %d to %d, got %d", ErrArgumentCount, f.Name, required, len(params), count)
+	}
+
+	return nil
+}
+
+// required returns the number of parameters that are not optional.
+func (f *Function) required() int {
+	for i, param := range f.Params {
+		if param.Optional {
+			return i
+		}
+	}
+
+	return len(f.Params)
+}
+
+func (f *Function) convert(param Param, arg any) (any, error) {
+	converted, ok := convert(param.Type, arg)
+	if !ok {
+		return nil, fmt.Errorf("%w in call to %s: %s: wanted %s, got %T", ErrArgumentWrongType, f.Name, param.Name, param.Type, arg)
+	}
+
+	return converted, nil
+}
+
+func convert(t Type, arg any) (any, bool) {
+	switch t {
+	case TypeAny:
+		return arg, true
+	case TypeBytes:
+		return toBytes(arg), true
+	case TypeInt:
+		return toInt(arg)
+	case TypeList:
+		list, ok := arg.([]any)
+		return list, ok
+	case TypeString:
+		str, ok := arg.(string)
+		return str, ok
+	default:
+		return nil, false
+	}
+}
+
+// toBytes converts arguments to bytes.
+// Arguments that are neither strings nor bytes are formatted with fmt.Sprint.
+func toBytes(arg any) []byte {
+	switch arg := arg.(type) {
+	case []byte:
+		return arg
+	case string:
+		return []byte(arg)
+	default:
+		return []byte(fmt.Sprint(arg))
+	}
+}
+
+// toInt converts arguments to int.
+// Numbers from JSON are float64, so floats are accepted as long as they don't have a fractional part.
+func toInt(arg any) (any, bool) {
+	switch arg := arg.(type) {
+	case int:
+		return arg, true
+	case int8:
+		return int(arg), true
+	case int16:
+		return int(arg), true
+	case int32:
+		return int(arg), true
+	case int64:
+		return int(arg), true
+	case uint8:
+		return int(arg), true
+	case uint16:
+		return int(arg), true
+	case uint32:
+		return int(arg), true
+	case float32:
+		return floatToInt(float64(arg))
+	case float64:
+		return floatToInt(arg)
+	default:
+		return nil, false
+	}
+}
+
+func floatToInt(f float64) (any, bool) {
+	if f != math.Trunc(f) || f < math.MinInt || f >= math.MaxInt {
+		return nil, false
+	}
+
+	return int(f), true
+}
+
+var registry = make(map[string]*Function)
+
+func register(functions ...*Function) {
+	for _, function := range functions {
+		if _, exists := registry[function.Name]; exists {
+			panic("function registered twice: " + function.Name)
+		}
+
+		for i, param := range function.Params {
+			if param.Variadic && (i != len(function.Params)-1 || param.Type != TypeList) {
+				panic("invalid variadic parameter in function " + function.Name + ": " + param.Name)
+			}
+
+			if i > 0 && function.Params[i-1].Optional && !param.Optional {
+				panic("required parameter after optional parameter in function " + function.Name + ": " + param.Name)
+			}
+		}
+
+		registry[function.Name] = function
+	}
+}
@@ -0,0 +1,94 @@
+package functions_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tbx.at/secrets-generator/internal/generator/functions"
+)
+
+var ctx = functions.Context{
+	Context: context.Background(),
+}
+
+func TestLookupUnknown(t *testing.T) {
+	_, err := functions.Lookup("doesNotExist")
+	assert.ErrorIs(t, err, functions.ErrFunctionDoesNotExist)
+	assert.ErrorContains(t, err, "doesNotExist")
+}
+
+func TestCallNamedAndPositional(t *testing.T) {
+	function, err := functions.Lookup("stringReplace")
+	require.NoError(t, err)
+
+	named, err := function.CallNamed(ctx, map[string]any{
+		"s":   "abc",
+		"old": "a",
+		"new": "AA",
+		"n":   float64(-1),
+	})
+	require.NoError(t, err)
+
+	positional, err := function.CallPositional(ctx, []any{"abc", "a", "AA", -1})
+	require.NoError(t, err)
+
+	assert.Equal(t, "AAbc", named)
+	assert.Equal(t, "AAbc", positional)
+}
+
+func TestCallVariadic(t *testing.T) {
+	function, err := functions.Lookup("fmt")
+	require.NoError(t, err)
+
+	named, err := function.CallNamed(ctx, map[string]any{
+		"format": "%s-%d",
+		"args":   []any{"a", 1},
+	})
+	require.NoError(t, err)
+
+	positional, err := function.CallPositional(ctx, []any{"%s-%d", "a", 1})
+	require.NoError(t, err)
+
+	noArgs, err := function.CallPositional(ctx, []any{"plain"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "a-1", named)
+	assert.Equal(t, "a-1", positional)
+	assert.Equal(t, "plain", noArgs)
+}
+
+func TestCallErrors(t *testing.T) {
+	function, err := functions.Lookup("hashBcrypt")
+	require.NoError(t, err)
+
+	_, err = function.CallNamed(ctx, map[string]any{"data": "password"})
+	assert.ErrorIs(t, err, functions.ErrArgumentMissing)
+	assert.ErrorContains(t, err, "in call to hashBcrypt: rounds")
+
+	_, err = function.CallNamed(ctx, map[string]any{"data": "password", "rounds": float64(5), "cost": float64(5)})
+	assert.ErrorIs(t, err, functions.ErrArgumentUnknown)
+	assert.ErrorContains(t, err, "in call to hashBcrypt: cost")
+
+	_, err = function.CallNamed(ctx, map[string]any{"data": "password", "rounds": "5"})
+	assert.ErrorIs(t, err, functions.ErrArgumentWrongType)
+	assert.ErrorContains(t, err, "in call to hashBcrypt: rounds: wanted int, got string")
+
+	_, err = function.CallPositional(ctx, []any{"password", 5.5})
+	assert.ErrorIs(t, err, functions.ErrArgumentWrongType)
+	assert.ErrorContains(t, err, "in call to hashBcrypt: rounds: wanted int, got float64")
+
+	_, err = function.CallPositional(ctx, []any{"password"})
+	assert.ErrorIs(t, err, functions.ErrArgumentCount)
+	assert.ErrorContains(t, err, "in call to hashBcrypt: wanted 2, got 1")
+}
+
+func TestAllSorted(t *testing.T) {
+	all := functions.All()
+	require.NotEmpty(t, all)
+
+	for i := 1; i < len(all); i++ {
+		assert.Less(t, all[i-1].Name, all[i].Name)
+	}
+}
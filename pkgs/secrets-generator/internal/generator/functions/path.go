@@ -0,0 +1,136 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrPathInvalid  = errors.New("invalid path")
+	ErrPathNotFound = errors.New("path not found")
+)
+
+// lookupPath returns the value at path inside a parsed JSON value.
+//
+// Paths are made of object keys separated by dots and list indices in brackets, like "a.b[0]".
+// Keys that contain dots or brackets can be quoted in brackets, like `a["b.c"]`.
+// The empty path refers to the value itself.
+func lookupPath(value any, path string) (any, error) {
+	steps, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, step := range steps {
+		switch cast := value.(type) {
+		case map[string]any:
+			key, ok := step.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s: cannot index object with %d", ErrPathNotFound, formatPath(steps[:i+1]), step)
+			}
+
+			value, ok = cast[key]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", ErrPathNotFound, formatPath(steps[:i+1]))
+			}
+		case []any:
+			index, ok := step.(int)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s: cannot look up key %q in list", ErrPathNotFound, formatPath(steps[:i+1]), step)
+			}
+
+			if index >= len(cast) {
+				return nil, fmt.Errorf("%w: %s: list has %d elements", ErrPathNotFound, formatPath(steps[:i+1]), len(cast))
+			}
+
+			value = cast[index]
+		default:
+			return nil, fmt.Errorf("%w: %s: cannot look up %v in %T", ErrPathNotFound, formatPath(steps[:i+1]), step, value)
+		}
+	}
+
+	return value, nil
+}
+
+// parsePath splits a path into its steps, which are either keys (string) or indices (int).
+func parsePath(path string) ([]any, error) {
+	var steps []any
+
+	rest := path
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, `["`):
+			quoted, err := strconv.QuotedPrefix(rest[1:])
+			if err != nil || !strings.HasPrefix(rest[1+len(quoted):], "]") {
+				return nil, fmt.Errorf("%w: %q: unterminated quoted key", ErrPathInvalid, path)
+			}
+
+			key, err := strconv.Unquote(quoted)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q: %w", ErrPathInvalid, path, err)
+			}
+
+			steps = append(steps, key)
+			rest = rest[1+len(quoted)+1:]
+		case strings.HasPrefix(rest, "["):
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: %q: unterminated index", ErrPathInvalid, path)
+			}
+
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("%w: %q: index %q is not a non-negative integer", ErrPathInvalid, path, rest[1:end])
+			}
+
+			steps = append(steps, index)
+			rest = rest[end+1:]
+		default:
+			if len(steps) > 0 {
+				if !strings.HasPrefix(rest, ".") {
+					return nil, fmt.Errorf("%w: %q: expected '.' or '[' before %q", ErrPathInvalid, path, rest)
+				}
+
+				rest = rest[1:]
+			}
+
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+
+			if end == 0 {
+				return nil, fmt.Errorf("%w: %q: empty key", ErrPathInvalid, path)
+			}
+
+			steps = append(steps, rest[:end])
+			rest = rest[end:]
+		}
+	}
+
+	return steps, nil
+}
+
+func formatPath(steps []any) string {
+	var formatted strings.Builder
+
+	for i, step := range steps {
+		switch step := step.(type) {
+		case int:
+			fmt.Fprintf(&formatted, "[%d]", step)
+		case string:
+			if strings.ContainsAny(step, ".[]\"") || step == "" {
+				fmt.Fprintf(&formatted, "[%q]", step)
+			} else {
+				if i > 0 {
+					formatted.WriteByte('.')
+				}
+				formatted.WriteString(step)
+			}
+		}
+	}
+
+	return formatted.String()
+}
@@ -0,0 +1,93 @@
+package functions
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func init() {
+	register(
+		&Function{
+			Name: "readSecret",
+			Params: []Param{
+				{Name: "name", Type: TypeString, Secret: true},
+
+				// If path is given, the secret is parsed as JSON and the value at path is returned instead of the raw bytes.
+				{Name: "path", Type: TypeString, Optional: true},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				if path, ok := args[1].(string); ok {
+					return readSecretField(ctx, args[0].(string), path)
+				}
+
+				return readSecret(ctx, args[0].(string))
+			},
+		},
+
+		&Function{
+			Name: "readSecretField",
+			Params: []Param{
+				{Name: "name", Type: TypeString, Secret: true},
+				{Name: "path", Type: TypeString},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				return readSecretField(ctx, args[0].(string), args[1].(string))
+			},
+		},
+
+		&Function{
+			Name: "readSecretJSON",
+			Params: []Param{
+				{Name: "name", Type: TypeString, Secret: true},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				return readSecretField(ctx, args[0].(string), "")
+			},
+		},
+	)
+}
+
+// readSecret waits for the secret called name to be generated and returns its contents.
+func readSecret(ctx Context, name string) ([]byte, error) {
+	if ctx.Dependencies != nil && !ctx.Dependencies[name] {
+		return nil, fmt.Errorf("%w: %s", ErrUndeclaredDependency, name)
+	}
+
+	done := ctx.Completion.Done(name)
+
+	select {
+	case <-done:
+	default:
+		if ctx.Logger != nil {
+			ctx.Logger.Info("waiting for dependency", "dependency", name)
+		}
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, ErrCancelled
+	}
+
+	return ctx.SecretStore.LoadSecret(ctx, name)
+}
+
+// readSecretField parses the secret called name as JSON and returns the value at path.
+func readSecretField(ctx Context, name, path string) (any, error) {
+	data, err := readSecret(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("secret %s is not valid JSON: %w", name, err)
+	}
+
+	field, err := lookupPath(value, path)
+	if err != nil {
+		return nil, fmt.Errorf("in secret %s: %w", name, err)
+	}
+
+	return field, nil
+}
@@ -0,0 +1,107 @@
+package functions_test
+
+import (
+	"context"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tbx.at/secrets-generator/internal"
+	"tbx.at/secrets-generator/internal/generator/functions"
+	"tbx.at/secrets-generator/internal/storage"
+)
+
+func secretsContext(secrets map[string]string) functions.Context {
+	secretStore := internal.NewSecretStore(storage.NewMemory(), internal.AgeEncoding{}, []age.Identity{}, internal.SecretStoreOptions{})
+	for name, data := range secrets {
+		secretStore.StoreSecret(name, []byte(data))
+	}
+
+	return functions.Context{
+		Context:     context.Background(),
+		Completion:  internal.NewCompletionMap(map[string]internal.Secret{}),
+		SecretStore: secretStore,
+	}
+}
+
+func TestReadSecretField(t *testing.T) {
+	ctx := secretsContext(map[string]string{
+		"credentials": `{"a": {"b": [1, {"c.d": "nested"}]}, "list": ["x"]}`,
+	})
+
+	function, err := functions.Lookup("readSecretField")
+	require.NoError(t, err)
+
+	for path, expected := range map[string]any{
+		"":                 map[string]any{"a": map[string]any{"b": []any{float64(1), map[string]any{"c.d": "nested"}}}, "list": []any{"x"}},
+		"a.b[0]":           float64(1),
+		`a.b[1]["c.d"]`:    "nested",
+		`["a"].b[1]`:       map[string]any{"c.d": "nested"},
+		"list[0]":          "x",
+		`["list"][0]`:      "x",
+		`a["b"][1]["c.d"]`: "nested",
+	} {
+		value, err := function.CallPositional(ctx, []any{"credentials", path})
+		require.NoError(t, err, path)
+		assert.Equal(t, expected, value, path)
+	}
+}
+
+func TestReadSecretFieldErrors(t *testing.T) {
+	ctx := secretsContext(map[string]string{
+		"credentials": `{"a": {"b": [1]}}`,
+		"raw":         "not json",
+	})
+
+	function, err := functions.Lookup("readSecretField")
+	require.NoError(t, err)
+
+	for path, expected := range map[string]error{
+		"a.c":      functions.ErrPathNotFound,
+		"a.b[1]":   functions.ErrPathNotFound,
+		"a[0]":     functions.ErrPathNotFound,
+		"a.b.c":    functions.ErrPathNotFound,
+		"a.b[0].c": functions.ErrPathNotFound,
+		"a..b":     functions.ErrPathInvalid,
+		"a.b[x]":   functions.ErrPathInvalid,
+		"a.b[-1]":  functions.ErrPathInvalid,
+		"a.b[0":    functions.ErrPathInvalid,
+		`a["b`:     functions.ErrPathInvalid,
+		"a.b[0]c":  functions.ErrPathInvalid,
+	} {
+		_, err := function.CallPositional(ctx, []any{"credentials", path})
+		assert.ErrorIs(t, err, expected, path)
+	}
+
+	_, err = function.CallPositional(ctx, []any{"credentials", "a.b[1]"})
+	assert.ErrorContains(t, err, "in secret credentials: path not found: a.b[1]: list has 1 elements")
+
+	_, err = function.CallPositional(ctx, []any{"raw", ""})
+	assert.ErrorContains(t, err, "secret raw is not valid JSON")
+}
+
+func TestReadSecretOptionalPath(t *testing.T) {
+	ctx := secretsContext(map[string]string{
+		"credentials": `{"a": "b"}`,
+	})
+
+	function, err := functions.Lookup("readSecret")
+	require.NoError(t, err)
+
+	raw, err := function.CallNamed(ctx, map[string]any{"name": "credentials"})
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`{"a": "b"}`), raw)
+
+	field, err := function.CallNamed(ctx, map[string]any{"name": "credentials", "path": "a"})
+	require.NoError(t, err)
+	assert.Equal(t, "b", field)
+
+	field, err = function.CallPositional(ctx, []any{"credentials", "a"})
+	require.NoError(t, err)
+	assert.Equal(t, "b", field)
+
+	_, err = function.CallPositional(ctx, []any{"credentials", "a", "b"})
+	assert.ErrorIs(t, err, functions.ErrArgumentCount)
+	assert.ErrorContains(t, err, "in call to readSecret: wanted 1 to 2, got 3")
+}
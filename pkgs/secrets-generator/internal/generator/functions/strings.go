@@ -0,0 +1,102 @@
+package functions
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+func init() {
+	register(
+		&Function{
+			Name: "hmacSHA256",
+			Params: []Param{
+				{Name: "key", Type: TypeBytes},
+				{Name: "data", Type: TypeBytes},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				mac := hmac.New(sha256.New, args[0].([]byte))
+				mac.Write(args[1].([]byte))
+				return hex.EncodeToString(mac.Sum(nil)), nil
+			},
+		},
+
+		&Function{
+			Name: "join",
+			Params: []Param{
+				{Name: "separator", Type: TypeString},
+				{Name: "list", Type: TypeList},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				list := args[1].([]any)
+
+				elems := make([]string, len(list))
+				for i, elem := range list {
+					elems[i] = string(toBytes(elem))
+				}
+
+				return strings.Join(elems, args[0].(string)), nil
+			},
+		},
+
+		&Function{
+			Name: "lower",
+			Params: []Param{
+				{Name: "data", Type: TypeBytes},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				return strings.ToLower(string(args[0].([]byte))), nil
+			},
+		},
+
+		&Function{
+			Name: "sha256sum",
+			Params: []Param{
+				{Name: "data", Type: TypeBytes},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				sum := sha256.Sum256(args[0].([]byte))
+				return hex.EncodeToString(sum[:]), nil
+			},
+		},
+
+		&Function{
+			Name: "split",
+			Params: []Param{
+				{Name: "separator", Type: TypeString},
+				{Name: "data", Type: TypeBytes},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				parts := strings.Split(string(args[1].([]byte)), args[0].(string))
+
+				list := make([]any, len(parts))
+				for i, part := range parts {
+					list[i] = part
+				}
+
+				return list, nil
+			},
+		},
+
+		&Function{
+			Name: "trim",
+			Params: []Param{
+				{Name: "data", Type: TypeBytes},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				return strings.TrimSpace(string(args[0].([]byte))), nil
+			},
+		},
+
+		&Function{
+			Name: "upper",
+			Params: []Param{
+				{Name: "data", Type: TypeBytes},
+			},
+			Call: func(ctx Context, args []any) (any, error) {
+				return strings.ToUpper(string(args[0].([]byte))), nil
+			},
+		},
+	)
+}
@@ -11,3 +11,12 @@ type Generator interface {
 	Deterministic() bool
 	Generate(ctx context.Context, rng io.Reader, secret internal.Secret, output io.Writer) error
 }
+
+// MultiOutputGenerator is implemented by generators that support secrets with multiple outputs (see internal.Secret.Outputs).
+type MultiOutputGenerator interface {
+	Generator
+
+	// GenerateOutputs generates all outputs of a secret into the writers for their names.
+	// Outputs are generated in the order of secret.Outputs() so that entropy is consumed deterministically.
+	GenerateOutputs(ctx context.Context, rng io.Reader, secret internal.Secret, outputs map[string]io.Writer) error
+}
@@ -0,0 +1,189 @@
+// Package importer imports secrets that are not generated, like API keys from vendors, from external sources.
+package importer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+
+	"tbx.at/secrets-generator/internal"
+)
+
+var (
+	ErrEnvNotSet       = errors.New("environment variable is not set")
+	ErrNoSource        = errors.New("import has no source")
+	ErrMultipleSources = errors.New("import has multiple sources")
+)
+
+// DefaultPassCommand is used to read entries from a pass store if no other command is configured.
+const DefaultPassCommand = "pass"
+
+// Source reads the value of an imported secret.
+type Source interface {
+	Read(ctx context.Context) ([]byte, error)
+}
+
+// FileSource reads a secret from a file.
+type FileSource struct {
+	Path string
+}
+
+func (s FileSource) Read(ctx context.Context) ([]byte, error) {
+	return os.ReadFile(s.Path)
+}
+
+// EnvSource reads a secret from an environment variable.
+type EnvSource struct {
+	Name string
+}
+
+func (s EnvSource) Read(ctx context.Context) ([]byte, error) {
+	value, ok := os.LookupEnv(s.Name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrEnvNotSet, s.Name)
+	}
+
+	return []byte(value), nil
+}
+
+// PassSource reads a secret from the first line of an entry in a pass store.
+// Command can be any program that understands "show <entry>" like pass does.
+type PassSource struct {
+	Command string
+	Entry   string
+}
+
+func (s PassSource) Read(ctx context.Context) ([]byte, error) {
+	output, err := run(ctx, s.Command, "show", s.Entry)
+	if err != nil {
+		return nil, err
+	}
+
+	password, _, _ := bytes.Cut(output, []byte("\n"))
+	return password, nil
+}
+
+// CommandSource reads a secret from the output of a command.
+// One trailing newline is removed from the output.
+type CommandSource struct {
+	Args []string
+}
+
+func (s CommandSource) Read(ctx context.Context) ([]byte, error) {
+	output, err := run(ctx, s.Args[0], s.Args[1:]...)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(output, []byte("\n")), nil
+}
+
+func run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = os.Stderr
+	return cmd.Output()
+}
+
+// NewSource returns the source configured in params.
+func NewSource(params internal.GenerationParamsImport) (Source, error) {
+	var sources []Source
+
+	if params.File != "" {
+		sources = append(sources, FileSource{Path: params.File})
+	}
+
+	if params.Env != "" {
+		sources = append(sources, EnvSource{Name: params.Env})
+	}
+
+	if params.Pass != "" {
+		command := params.PassCommand
+		if command == "" {
+			command = DefaultPassCommand
+		}
+
+		sources = append(sources, PassSource{Command: command, Entry: params.Pass})
+	}
+
+	if len(params.Command) > 0 {
+		sources = append(sources, CommandSource{Args: params.Command})
+	}
+
+	switch len(sources) {
+	case 0:
+		return nil, ErrNoSource
+	case 1:
+		return sources[0], nil
+	default:
+		return nil, ErrMultipleSources
+	}
+}
+
+// GeneratorImport copies secrets from their source.
+// It is deterministic because it doesn't use any entropy, so a secret is only imported again when the value at its source changes.
+//
+// Checking whether a secret changed and generating it again both call Generate.
+// Values are read from their source once and reused for later calls with the same params, so commands don't run twice and both calls see the same value.
+// A GeneratorImport should therefore only be used for a single run, after which Wipe clears the values.
+type GeneratorImport struct {
+	values map[*internal.GenerationParamsImport][]byte
+	mutex  sync.Mutex
+}
+
+func (gen *GeneratorImport) Deterministic() bool {
+	return true
+}
+
+func (gen *GeneratorImport) Generate(ctx context.Context, rng io.Reader, secret internal.Secret, output io.Writer) error {
+	value, err := gen.read(ctx, secret.Generation.Import)
+	if err != nil {
+		return err
+	}
+
+	_, err = output.Write(value)
+	return err
+}
+
+// read returns the value at the source in params, reading it only if it wasn't read before.
+// Failed reads aren't cached, so that the next call tries again.
+func (gen *GeneratorImport) read(ctx context.Context, params *internal.GenerationParamsImport) ([]byte, error) {
+	gen.mutex.Lock()
+	defer gen.mutex.Unlock()
+
+	if value, ok := gen.values[params]; ok {
+		return value, nil
+	}
+
+	source, err := NewSource(*params)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := source.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if gen.values == nil {
+		gen.values = make(map[*internal.GenerationParamsImport][]byte)
+	}
+	gen.values[params] = value
+
+	return value, nil
+}
+
+// Wipe overwrites and forgets the values read so far.
+func (gen *GeneratorImport) Wipe() {
+	gen.mutex.Lock()
+	defer gen.mutex.Unlock()
+
+	for params, value := range gen.values {
+		clear(value)
+		delete(gen.values, params)
+	}
+}
@@ -2,38 +2,34 @@ package json
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"reflect"
+	"log/slog"
 	"slices"
 
 	"tbx.at/secrets-generator/internal"
-	"tbx.at/secrets-generator/internal/rand/argon2id"
-	"tbx.at/secrets-generator/internal/rand/bcrypt"
-)
-
-const (
-	functionNameFmt          = "fmt"
-	functionNameHashArgon2id = "hashArgon2id"
-	functionNameHashBcrypt   = "hashBcrypt"
-	functionNameReadSecret   = "readSecret"
+	"tbx.at/secrets-generator/internal/generator/format"
+	"tbx.at/secrets-generator/internal/generator/functions"
 )
 
 var (
-	ErrArgumentMissing      = errors.New("function argument is missing")
-	ErrArgumentsNotInMap    = errors.New("function arguments are not in a map")
-	ErrFunctionDoesNotExist = errors.New("function does not exist")
-	ErrGenerationCancelled  = errors.New("generation cancelled")
-	ErrMissingArguments     = errors.New("function call is missing arguments")
-	ErrMissingName          = errors.New("function call is missing a name")
-	ErrNameNotAString       = errors.New("function name is not a string")
+	ErrArgumentsNotInMap = errors.New("function arguments are not in a map")
+	ErrMissingArguments  = errors.New("function call is missing arguments")
+	ErrMissingName       = errors.New("function call is missing a name")
+	ErrNameNotAString    = errors.New("function name is not a string")
 )
 
 type GeneratorJSON struct {
 	Completion  *internal.CompletionMap
 	SecretStore *internal.SecretStore
+
+	// Dependencies holds the secrets that each secret may read by name, see functions.Context.
+	// Secrets may read any secret if it is nil.
+	Dependencies map[string]map[string]bool
+
+	// Logger reports progress like waiting for other secrets. It may be nil.
+	Logger *slog.Logger
 }
 
 func (gen *GeneratorJSON) Deterministic() bool {
@@ -41,25 +37,63 @@ func (gen *GeneratorJSON) Deterministic() bool {
 }
 
 func (gen *GeneratorJSON) Generate(ctx context.Context, rng io.Reader, secret internal.Secret, output io.Writer) error {
-	content, err := gen.walkJSON(ctx, rng, secret.Generation.JSON.Content)
+	return gen.generate(gen.functionContext(ctx, rng, secret), secret.Generation.JSON.Content, secret.Generation.JSON.Format, output)
+}
+
+func (gen *GeneratorJSON) GenerateOutputs(ctx context.Context, rng io.Reader, secret internal.Secret, outputs map[string]io.Writer) error {
+	fctx := gen.functionContext(ctx, rng, secret)
+
+	for _, name := range secret.Outputs() {
+		output := secret.Generation.JSON.Outputs[name]
+
+		if err := gen.generate(fctx, output.Content, output.Format, outputs[name]); err != nil {
+			return fmt.Errorf("in output %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// functionContext returns the context for the function calls in the content of secret.
+func (gen *GeneratorJSON) functionContext(ctx context.Context, rng io.Reader, secret internal.Secret) functions.Context {
+	fctx := functions.Context{
+		Context:     ctx,
+		RNG:         rng,
+		Completion:  gen.Completion,
+		SecretStore: gen.SecretStore,
+	}
+
+	if gen.Dependencies != nil {
+		fctx.Dependencies = gen.Dependencies[secret.Name]
+	}
+
+	if gen.Logger != nil {
+		fctx.Logger = gen.Logger.With("secret", secret.Name)
+	}
+
+	return fctx
+}
+
+func (gen *GeneratorJSON) generate(fctx functions.Context, content any, outputFormat string, output io.Writer) error {
+	walked, err := gen.walkJSON(fctx, content)
 	if err != nil {
 		return err
 	}
 
-	return json.NewEncoder(output).Encode(content)
+	return format.Encode(output, outputFormat, walked)
 }
 
-func (gen *GeneratorJSON) walkJSON(ctx context.Context, rng io.Reader, value any) (walked any, err error) {
+func (gen *GeneratorJSON) walkJSON(fctx functions.Context, value any) (walked any, err error) {
 	switch cast := value.(type) {
 	case map[string]any:
-		if cast["__secretsGeneratorType"] == "functionCall" {
-			return gen.processFunctionCall(ctx, rng, cast)
+		if IsFunctionCall(cast) {
+			return gen.processFunctionCall(fctx, cast)
 		}
-		return gen.processObject(ctx, rng, cast)
+		return gen.processObject(fctx, cast)
 	case []any:
 		walked := make([]any, len(cast))
 		for i, v := range cast {
-			walked[i], err = gen.walkJSON(ctx, rng, v)
+			walked[i], err = gen.walkJSON(fctx, v)
 			if err != nil {
 				return nil, err
 			}
@@ -70,7 +104,7 @@ func (gen *GeneratorJSON) walkJSON(ctx context.Context, rng io.Reader, value any
 	}
 }
 
-func (gen *GeneratorJSON) processObject(ctx context.Context, rng io.Reader, object map[string]any) (walked any, err error) {
+func (gen *GeneratorJSON) processObject(fctx functions.Context, object map[string]any) (walked any, err error) {
 	sortedKeys := make([]string, 0, len(object))
 	for key := range object {
 		sortedKeys = append(sortedKeys, key)
@@ -82,7 +116,7 @@ func (gen *GeneratorJSON) processObject(ctx context.Context, rng io.Reader, obje
 	for _, key := range sortedKeys {
 		value := object[key]
 
-		walkedObject[key], err = gen.walkJSON(ctx, rng, value)
+		walkedObject[key], err = gen.walkJSON(fctx, value)
 		if err != nil {
 			return nil, err
 		}
@@ -91,214 +125,104 @@ func (gen *GeneratorJSON) processObject(ctx context.Context, rng io.Reader, obje
 	return walkedObject, nil
 }
 
-func (gen *GeneratorJSON) processFunctionCall(ctx context.Context, rng io.Reader, call map[string]any) (walked any, err error) {
-	nameRaw, ok := call["name"]
-	if !ok {
-		return nil, ErrMissingName
-	}
-
-	name, ok := nameRaw.(string)
-	if !ok {
-		return nil, ErrNameNotAString
-	}
-
-	argsRaw, ok := call["arguments"]
-	if !ok {
-		return nil, ErrMissingArguments
-	}
-
-	argsWalked, err := gen.walkJSON(ctx, rng, argsRaw)
-	if err != nil {
-		return nil, err
-	}
-
-	argsMap, ok := argsWalked.(map[string]any)
-	if !ok {
-		return nil, ErrArgumentsNotInMap
-	}
-
-	fctx := functionCtx{
-		Context: ctx,
-		name:    name,
-		rng:     rng,
-		args:    argsMap,
-	}
-
-	switch name {
-	case functionNameFmt:
-		return gen.functionFmt(fctx)
-	case functionNameHashArgon2id:
-		return gen.functionHashArgon2id(fctx)
-	case functionNameHashBcrypt:
-		return gen.functionHashBcrypt(fctx)
-	case functionNameReadSecret:
-		return gen.functionReadSecret(fctx)
-	default:
-		return nil, ErrFunctionDoesNotExist
-	}
-}
-
-type functionCtx struct {
-	context.Context
-	name string
-	rng  io.Reader
-	args map[string]any
-}
-
-func (gen *GeneratorJSON) functionFmt(ctx functionCtx) (walked any, err error) {
-	format, err := getString(ctx, "format")
-	if err != nil {
-		return nil, err
-	}
-
-	argsRaw, err := getSlice(ctx, "args")
-	if err != nil {
-		return nil, err
-	}
-
-	argsWalked, err := gen.walkJSON(ctx, ctx.rng, argsRaw)
-	if err != nil {
-		return nil, err
-	}
-
-	args := argsWalked.([]any)
-
-	return fmt.Sprintf(format, args...), nil
-}
-
-func (gen *GeneratorJSON) functionHashArgon2id(ctx functionCtx) (walked any, err error) {
-	data, err := getAny(ctx, "data")
+func (gen *GeneratorJSON) processFunctionCall(fctx functions.Context, call map[string]any) (walked any, err error) {
+	name, args, err := ParseFunctionCall(call)
 	if err != nil {
 		return nil, err
 	}
 
-	var str string
-
-	switch data := data.(type) {
-	case []byte:
-		str = string(data)
-	case string:
-		str = data
-	default:
-		str = fmt.Sprint(data)
-	}
-
-	memory, err := getNumber(ctx, "memory")
+	argsWalked, err := gen.processObject(fctx, args)
 	if err != nil {
 		return nil, err
 	}
 
-	iterations, err := getNumber(ctx, "iterations")
+	function, err := functions.Lookup(name)
 	if err != nil {
 		return nil, err
 	}
 
-	parallelism, err := getNumber(ctx, "parallelism")
-	if err != nil {
-		return nil, err
-	}
-
-	return argon2id.CreateHash(ctx.rng, str, &argon2id.Params{
-		Memory:      uint32(memory),
-		Iterations:  uint32(iterations),
-		Parallelism: uint8(parallelism),
-		SaltLength:  16,
-		KeyLength:   32,
-	})
+	return function.CallNamed(fctx, argsWalked.(map[string]any))
 }
 
-func (gen *GeneratorJSON) functionHashBcrypt(ctx functionCtx) (walked any, err error) {
-	data, err := getAny(ctx, "data")
-	if err != nil {
-		return nil, err
-	}
-
-	var bytes []byte
-
-	switch data := data.(type) {
-	case []byte:
-		bytes = data
-	case string:
-		bytes = []byte(data)
-	default:
-		bytes = []byte(fmt.Sprint(data))
-	}
-
-	rounds, err := getNumber(ctx, "rounds")
-	if err != nil {
-		return nil, err
-	}
-
-	hash, err := bcrypt.GenerateFromPassword(ctx.rng, bytes, int(rounds))
-	if err != nil {
-		return nil, err
-	}
-
-	return string(hash), nil
+// IsFunctionCall returns whether an object in the content is a function call.
+func IsFunctionCall(object map[string]any) bool {
+	return object["__secretsGeneratorType"] == "functionCall"
 }
 
-func (gen *GeneratorJSON) functionReadSecret(ctx functionCtx) (walked any, err error) {
-	name, err := getString(ctx, "name")
-	if err != nil {
-		return nil, err
+// ParseFunctionCall returns the name and the (unevaluated) arguments of a function call.
+func ParseFunctionCall(call map[string]any) (name string, args map[string]any, err error) {
+	nameRaw, ok := call["name"]
+	if !ok {
+		return "", nil, ErrMissingName
 	}
 
-	select {
-	case <-gen.Completion.Done(name):
-	case <-ctx.Done():
-		return nil, ErrGenerationCancelled
+	name, ok = nameRaw.(string)
+	if !ok {
+		return "", nil, ErrNameNotAString
 	}
 
-	return gen.SecretStore.LoadSecret(name)
-}
-
-func getNumber(ctx functionCtx, name string) (float64, error) {
-	argRaw, ok := ctx.args[name]
+	argsRaw, ok := call["arguments"]
 	if !ok {
-		return 0, fmt.Errorf("%w in call to %s: %s", ErrArgumentMissing, ctx.name, name)
+		return "", nil, ErrMissingArguments
 	}
 
-	arg, ok := argRaw.(float64)
+	args, ok = argsRaw.(map[string]any)
 	if !ok {
-		return 0, fmt.Errorf("argument %s has wrong type in call to %s: wanted float64, got %s", name, ctx.name, reflect.TypeOf(argRaw).Name())
+		return "", nil, ErrArgumentsNotInMap
 	}
 
-	return arg, nil
+	return name, args, nil
 }
 
-func getString(ctx functionCtx, name string) (string, error) {
-	argRaw, ok := ctx.args[name]
-	if !ok {
-		return "", fmt.Errorf("%w in call to %s: %s", ErrArgumentMissing, ctx.name, name)
-	}
+// ReadSecrets returns the names of the secrets that the content of a secret reads, sorted and without duplicates.
+// Only names that are given as strings are found, not names that are the results of other function calls.
+func ReadSecrets(params internal.GenerationParamsJSON) []string {
+	var names []string
 
-	arg, ok := argRaw.(string)
-	if !ok {
-		return "", fmt.Errorf("argument %s has wrong type in call to %s: wanted string, got %s", name, ctx.name, reflect.TypeOf(argRaw).Name())
+	names = appendReadSecrets(names, params.Content)
+	for _, output := range params.Outputs {
+		names = appendReadSecrets(names, output.Content)
 	}
 
-	return arg, nil
+	slices.Sort(names)
+	return slices.Compact(names)
 }
 
-func getSlice(ctx functionCtx, name string) ([]any, error) {
-	argRaw, ok := ctx.args[name]
-	if !ok {
-		return nil, fmt.Errorf("%w in call to %s: %s", ErrArgumentMissing, ctx.name, name)
-	}
+func appendReadSecrets(names []string, value any) []string {
+	switch cast := value.(type) {
+	case []any:
+		for _, item := range cast {
+			names = appendReadSecrets(names, item)
+		}
 
-	arg, ok := argRaw.([]any)
-	if !ok {
-		return nil, fmt.Errorf("argument %s has wrong type in call to %s: wanted []any, got %s", name, ctx.name, reflect.TypeOf(argRaw).Name())
-	}
+	case map[string]any:
+		if !IsFunctionCall(cast) {
+			for _, item := range cast {
+				names = appendReadSecrets(names, item)
+			}
+			return names
+		}
 
-	return arg, nil
-}
+		name, args, err := ParseFunctionCall(cast)
+		if err != nil {
+			return names
+		}
 
-func getAny(ctx functionCtx, name string) (any, error) {
-	arg, ok := ctx.args[name]
-	if !ok {
-		return nil, fmt.Errorf("%w in call to %s: %s", ErrArgumentMissing, ctx.name, name)
+		for _, arg := range args {
+			names = appendReadSecrets(names, arg)
+		}
+
+		function, err := functions.Lookup(name)
+		if err != nil {
+			return names
+		}
+
+		for _, param := range function.Params {
+			if target, ok := args[param.Name].(string); ok && param.Secret {
+				names = append(names, target)
+			}
+		}
 	}
 
-	return arg, nil
+	return names
 }
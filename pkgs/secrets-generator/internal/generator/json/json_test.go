@@ -13,6 +13,7 @@ import (
 	"tbx.at/secrets-generator/internal"
 	"tbx.at/secrets-generator/internal/generator/json"
 	"tbx.at/secrets-generator/internal/rand/bcrypt"
+	"tbx.at/secrets-generator/internal/storage"
 	"tbx.at/secrets-generator/internal/testutil"
 )
 
@@ -45,7 +46,7 @@ func TestNestedFunctionCall(t *testing.T) {
 		},
 	}
 
-	secretStore := internal.NewSecretStore([]age.Identity{})
+	secretStore := internal.NewSecretStore(storage.NewMemory(), internal.AgeEncoding{}, []age.Identity{}, internal.SecretStoreOptions{})
 	secretStore.StoreSecret("password", []byte(password))
 
 	completionMap := internal.NewCompletionMap(map[string]internal.Secret{
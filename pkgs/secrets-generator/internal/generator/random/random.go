@@ -59,7 +59,12 @@ func (gen *GeneratorRandom) Generate(ctx context.Context, rng io.Reader, secret
 		return ErrEmptyCharset
 	}
 
-	password, err := password.GeneratePassword(rng, charset.String(), secret.Generation.Random.Length)
+	generatePassword := password.GeneratePassword
+	if internal.EntropyAlgorithm(rng) == internal.AlgorithmLegacy {
+		generatePassword = password.GeneratePasswordLegacy
+	}
+
+	password, err := generatePassword(rng, charset.String(), secret.Generation.Random.Length)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,172 @@
+package template
+
+import (
+	"slices"
+	"text/template/parse"
+
+	"tbx.at/secrets-generator/internal"
+	"tbx.at/secrets-generator/internal/generator/functions"
+)
+
+// ReadSecrets returns the names of the secrets that the templates of a secret read, sorted and without duplicates.
+// Only names that are known without executing the templates are found: string literals and strings from the template data.
+// Templates that can't be parsed are skipped, since generating the secret fails anyway.
+func ReadSecrets(secretName string, params internal.GenerationParamsTemplate) []string {
+	contents := map[string]string{secretName: params.Content}
+	if params.Outputs != nil {
+		contents = make(map[string]string, len(params.Outputs))
+		for output, content := range params.Outputs {
+			contents[internal.OutputSecretName(secretName, output)] = content
+		}
+	}
+
+	var names []string
+
+	for name, content := range contents {
+		tmpl, err := Parse(name, content, params)
+		if err != nil {
+			continue
+		}
+
+		for _, t := range tmpl.Templates() {
+			if t.Tree == nil {
+				continue
+			}
+
+			// Templates other than the main one can be called with any data.
+			r := secretReader{data: params.Data}
+			r.walk(t.Tree.Root, t.Name() == name)
+			names = append(names, r.names...)
+		}
+	}
+
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// secretReader collects the names passed to function parameters that name secrets.
+type secretReader struct {
+	data  map[string]any
+	names []string
+}
+
+// walk walks node. atRoot is whether dot refers to the template data at node.
+func (r *secretReader) walk(node parse.Node, atRoot bool) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+		for _, child := range node.Nodes {
+			r.walk(child, atRoot)
+		}
+
+	case *parse.ActionNode:
+		r.walk(node.Pipe, atRoot)
+
+	case *parse.IfNode:
+		r.walkBranch(&node.BranchNode, atRoot, atRoot)
+
+	case *parse.RangeNode:
+		r.walkBranch(&node.BranchNode, atRoot, false)
+
+	case *parse.WithNode:
+		r.walkBranch(&node.BranchNode, atRoot, false)
+
+	case *parse.TemplateNode:
+		r.walk(node.Pipe, atRoot)
+
+	case *parse.PipeNode:
+		if node == nil {
+			return
+		}
+		for _, cmd := range node.Cmds {
+			r.walkCommand(cmd, atRoot)
+		}
+
+	case *parse.ChainNode:
+		r.walk(node.Node, atRoot)
+	}
+}
+
+func (r *secretReader) walkBranch(node *parse.BranchNode, atRoot, listAtRoot bool) {
+	r.walk(node.Pipe, atRoot)
+	r.walk(node.List, listAtRoot)
+	r.walk(node.ElseList, atRoot)
+}
+
+func (r *secretReader) walkCommand(cmd *parse.CommandNode, atRoot bool) {
+	for _, arg := range cmd.Args {
+		r.walk(arg, atRoot)
+	}
+
+	ident, ok := cmd.Args[0].(*parse.IdentifierNode)
+	if !ok {
+		return
+	}
+
+	function, err := functions.Lookup(ident.Ident)
+	if err != nil {
+		// Builtin template functions like printf are not in the registry.
+		return
+	}
+
+	for i, arg := range cmd.Args[1:] {
+		if i >= len(function.Params) || !function.Params[i].Secret {
+			continue
+		}
+
+		if name, ok := ResolveString(r.data, arg, atRoot); ok {
+			r.names = append(r.names, name)
+		}
+	}
+}
+
+// ResolveString returns the value of arg if it is a string that is known without executing the template.
+// atRoot is whether dot refers to data where arg is evaluated.
+func ResolveString(data map[string]any, arg parse.Node, atRoot bool) (string, bool) {
+	var value any
+	var ok bool
+
+	switch arg := arg.(type) {
+	case *parse.StringNode:
+		return arg.Text, true
+	case *parse.FieldNode:
+		if !atRoot {
+			return "", false
+		}
+		value, _, ok = ResolveData(data, arg.Ident)
+	case *parse.VariableNode:
+		if arg.Ident[0] != "$" {
+			return "", false
+		}
+		value, _, ok = ResolveData(data, arg.Ident[1:])
+	}
+
+	if !ok {
+		return "", false
+	}
+
+	s, ok := value.(string)
+	return s, ok
+}
+
+// ResolveData returns the value in data at path.
+// If a key along path doesn't exist, it is returned as missing.
+// Lookups on anything other than objects can't be resolved without executing the template, so they return neither a value nor a missing key.
+func ResolveData(data map[string]any, path []string) (value any, missing string, ok bool) {
+	current := any(data)
+	for _, key := range path {
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil, "", false
+		}
+
+		current, ok = object[key]
+		if !ok {
+			return nil, key, false
+		}
+	}
+
+	return current, "", true
+}
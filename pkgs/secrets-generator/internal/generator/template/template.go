@@ -1,23 +1,36 @@
 package template
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"strings"
 	texttemplate "text/template"
 
 	"tbx.at/secrets-generator/internal"
-	"tbx.at/secrets-generator/internal/rand/argon2id"
-	"tbx.at/secrets-generator/internal/rand/bcrypt"
+	"tbx.at/secrets-generator/internal/generator/format"
+	"tbx.at/secrets-generator/internal/generator/functions"
 )
 
-var ErrTemplateExecutionCancelled = errors.New("template execution cancelled")
+// noValue is what text/template renders for missing values.
+const noValue = "<no value>"
+
+var ErrNoValue = errors.New("template output contains " + noValue)
 
 type GeneratorTemplate struct {
 	Completion  *internal.CompletionMap
 	SecretStore *internal.SecretStore
+
+	// Dependencies holds the secrets that each secret may read by name, see functions.Context.
+	// Secrets may read any secret if it is nil.
+	Dependencies map[string]map[string]bool
+
+	// Logger reports progress like waiting for other secrets. It may be nil.
+	Logger *slog.Logger
 }
 
 func (gen *GeneratorTemplate) Deterministic() bool {
@@ -25,68 +38,182 @@ func (gen *GeneratorTemplate) Deterministic() bool {
 }
 
 func (gen *GeneratorTemplate) Generate(ctx context.Context, rng io.Reader, secret internal.Secret, output io.Writer) error {
-	tmpl, err := texttemplate.New("").
-		Funcs(texttemplate.FuncMap{
-			"fmt": fmt.Sprintf,
-
-			"hashArgon2id": func(data any, memory, iterations uint32, parallelism uint8) (string, error) {
-				var str string
-
-				switch data := data.(type) {
-				case []byte:
-					str = string(data)
-				case string:
-					str = data
-				default:
-					str = fmt.Sprint(data)
-				}
-
-				return argon2id.CreateHash(rng, str, &argon2id.Params{
-					Memory:      memory,
-					Iterations:  iterations,
-					Parallelism: parallelism,
-					SaltLength:  16,
-					KeyLength:   32,
-				})
-			},
-
-			"hashBcrypt": func(data any, rounds int) (string, error) {
-				var bytes []byte
-
-				switch data := data.(type) {
-				case []byte:
-					bytes = data
-				case string:
-					bytes = []byte(data)
-				default:
-					bytes = []byte(fmt.Sprint(data))
-				}
-
-				hash, err := bcrypt.GenerateFromPassword(rng, bytes, rounds)
-				if err != nil {
-					return "", err
-				}
-
-				return string(hash), nil
-			},
-
-			"readSecret": func(name string) ([]byte, error) {
-				select {
-				case <-gen.Completion.Done(name):
-				case <-ctx.Done():
-					return nil, ErrTemplateExecutionCancelled
-				}
-
-				return gen.SecretStore.LoadSecret(name)
-			},
-
-			"stringReplace": strings.Replace,
-		}).
-		Parse(secret.Generation.Template.Content)
+	return gen.execute(ctx, rng, secret, secret.Name, secret.Generation.Template.Content, output)
+}
 
+func (gen *GeneratorTemplate) GenerateOutputs(ctx context.Context, rng io.Reader, secret internal.Secret, outputs map[string]io.Writer) error {
+	for _, name := range secret.Outputs() {
+		templateName := internal.OutputSecretName(secret.Name, name)
+
+		if err := gen.execute(ctx, rng, secret, templateName, secret.Generation.Template.Outputs[name], outputs[name]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// execute executes a template.
+// The template is named after the secret (or output) so that errors point to where they occurred, like "template: name:3:5: ...".
+func (gen *GeneratorTemplate) execute(ctx context.Context, rng io.Reader, secret internal.Secret, name, content string, output io.Writer) error {
+	params := *secret.Generation.Template
+
+	fctx := functions.Context{
+		Context:     ctx,
+		RNG:         rng,
+		Completion:  gen.Completion,
+		SecretStore: gen.SecretStore,
+	}
+
+	if gen.Dependencies != nil {
+		fctx.Dependencies = gen.Dependencies[secret.Name]
+	}
+
+	if gen.Logger != nil {
+		fctx.Logger = gen.Logger.With("secret", name)
+	}
+
+	tmpl := texttemplate.New(name).Funcs(funcMap(fctx))
+
+	if !params.IsStrict() {
+		tmpl, err := tmpl.Parse(content)
+		if err != nil {
+			return err
+		}
+
+		return tmpl.Execute(output, params.Data)
+	}
+
+	tmpl, err := tmpl.Option("missingkey=error").Parse(content)
 	if err != nil {
 		return err
 	}
 
-	return tmpl.Execute(output, secret.Generation.Template.Data)
+	// Missing keys are caught by the missingkey option, but nil values (like null in Data) still render as <no value>.
+	// The output is buffered so that it can be checked for those before it is written.
+	generated := new(bytes.Buffer)
+	if err := tmpl.Execute(generated, params.Data); err != nil {
+		return err
+	}
+
+	if err := CheckNoValue(name, generated.Bytes()); err != nil {
+		return err
+	}
+
+	_, err = output.Write(generated.Bytes())
+	return err
+}
+
+// CheckNoValue returns an error if output contains the text that text/template renders for missing values.
+func CheckNoValue(name string, output []byte) error {
+	index := bytes.Index(output, []byte(noValue))
+	if index < 0 {
+		return nil
+	}
+
+	line := bytes.Count(output[:index], []byte("\n")) + 1
+	column := index - bytes.LastIndexByte(output[:index], '\n')
+
+	return fmt.Errorf("template: %s: %w at output line %d, column %d", name, ErrNoValue, line, column)
+}
+
+// Parse parses a template the same way it is parsed for generation, without executing it.
+func Parse(name, content string, params internal.GenerationParamsTemplate) (*texttemplate.Template, error) {
+	tmpl := texttemplate.New(name).Funcs(funcMap(functions.Context{}))
+
+	if params.IsStrict() {
+		tmpl = tmpl.Option("missingkey=error")
+	}
+
+	return tmpl.Parse(content)
+}
+
+// funcMap makes all functions available to templates.
+func funcMap(fctx functions.Context) texttemplate.FuncMap {
+	funcMap := make(texttemplate.FuncMap)
+	for _, function := range functions.All() {
+		funcMap[function.Name] = func(args ...any) (any, error) {
+			for i, arg := range args {
+				args[i] = functionValue(arg)
+			}
+
+			result, err := function.CallPositional(fctx, args)
+			if err != nil {
+				return nil, err
+			}
+
+			return templateValue(result), nil
+		}
+	}
+
+	return funcMap
+}
+
+// templateValue converts the result of a function so that templates render it the way the JSON generator writes it.
+// Byte slices become strings, and objects and lists render as JSON while field access, index and range still work on them.
+func templateValue(value any) any {
+	switch value := value.(type) {
+	case []byte:
+		return string(value)
+	case map[string]any:
+		object := make(jsonObject, len(value))
+		for key, v := range value {
+			object[key] = templateValue(v)
+		}
+		return object
+	case []any:
+		list := make(jsonList, len(value))
+		for i, v := range value {
+			list[i] = templateValue(v)
+		}
+		return list
+	default:
+		return value
+	}
+}
+
+// functionValue undoes templateValue for values that are passed to functions.
+func functionValue(value any) any {
+	switch value := value.(type) {
+	case jsonObject:
+		object := make(map[string]any, len(value))
+		for key, v := range value {
+			object[key] = functionValue(v)
+		}
+		return object
+	case jsonList:
+		list := make([]any, len(value))
+		for i, v := range value {
+			list[i] = functionValue(v)
+		}
+		return list
+	default:
+		return value
+	}
+}
+
+// jsonObject is an object returned by a function, like the result of fromJSON.
+type jsonObject map[string]any
+
+func (o jsonObject) String() string {
+	return renderJSON(o)
+}
+
+// jsonList is a list returned by a function.
+type jsonList []any
+
+func (l jsonList) String() string {
+	return renderJSON(l)
+}
+
+func renderJSON(value any) string {
+	buf := new(bytes.Buffer)
+
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+
+	// Objects and lists only contain values that came from JSON, so encoding them can't fail.
+	_ = encoder.Encode(format.Normalize(functionValue(value)))
+
+	return strings.TrimSuffix(buf.String(), "\n")
 }
@@ -0,0 +1,360 @@
+// Package lint finds problems in a configuration without generating any secrets.
+// It doesn't need any identities, so it can run anywhere the configuration is available.
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"text/template/parse"
+
+	"tbx.at/secrets-generator/internal"
+	"tbx.at/secrets-generator/internal/generate"
+	"tbx.at/secrets-generator/internal/generator/functions"
+	"tbx.at/secrets-generator/internal/generator/importer"
+	"tbx.at/secrets-generator/internal/generator/json"
+	"tbx.at/secrets-generator/internal/generator/random"
+	"tbx.at/secrets-generator/internal/generator/template"
+)
+
+var (
+	ErrEmptyProgram    = errors.New("script has no program")
+	ErrMissingKey      = errors.New("template refers to a key that is missing from its data")
+	ErrMultipleMethods = errors.New("secret specifies multiple generation methods")
+	ErrUnknownHost     = errors.New("unknown host")
+	ErrUnknownSecret   = errors.New("unknown secret")
+	ErrZeroLength      = errors.New("random secret has zero length")
+)
+
+// Lint checks config and returns all problems found, ordered by secret and mount name.
+func Lint(config internal.Config) []error {
+	l := linter{
+		config: config,
+		stored: make(map[string]bool),
+	}
+
+	for secretName, secret := range config.Secrets {
+		for _, name := range internal.StoredSecretNames(secretName, secret) {
+			l.stored[name] = true
+		}
+	}
+
+	if err := generate.ValidateOutputs(config); err != nil {
+		l.problems = append(l.problems, err)
+	}
+
+	if _, err := generate.Dependencies(config); err != nil {
+		l.problems = append(l.problems, err)
+	}
+
+	for _, secretName := range sortedKeys(config.Secrets) {
+		l.lintSecret(secretName, config.Secrets[secretName])
+	}
+
+	for _, mountName := range sortedKeys(config.SecretMounts) {
+		l.lintMount(mountName, config.SecretMounts[mountName])
+	}
+
+	l.problems = append(l.problems, internal.CheckMounts(config.SecretMounts)...)
+
+	return l.problems
+}
+
+type linter struct {
+	config internal.Config
+
+	// stored contains the names of all secrets that can be mounted and read (see internal.StoredSecretNames).
+	stored map[string]bool
+
+	problems []error
+}
+
+func (l *linter) report(secretName string, err error) {
+	l.problems = append(l.problems, fmt.Errorf("secret %s: %w", secretName, err))
+}
+
+func (l *linter) lintSecret(secretName string, secret internal.Secret) {
+	params := secret.Generation
+
+	methods := 0
+	for _, set := range []bool{params.Import != nil, params.JSON != nil, params.Random != nil, params.Script != nil, params.Template != nil, params.Custom != nil} {
+		if set {
+			methods++
+		}
+	}
+
+	if methods > 1 {
+		l.report(secretName, ErrMultipleMethods)
+		return
+	}
+
+	switch {
+	case params.Import != nil:
+		if _, err := importer.NewSource(*params.Import); err != nil {
+			l.report(secretName, err)
+		}
+	case params.JSON != nil:
+		l.lintJSON(secretName, *params.JSON)
+	case params.Random != nil:
+		l.lintRandom(secretName, *params.Random)
+	case params.Script != nil:
+		if params.Script.Program == "" {
+			l.report(secretName, ErrEmptyProgram)
+		}
+	case params.Template != nil:
+		l.lintTemplate(secretName, *params.Template)
+	}
+}
+
+func (l *linter) lintMount(mountName string, mount internal.SecretMount) {
+	if _, ok := l.config.PublicKeys[mount.Host]; !ok {
+		l.problems = append(l.problems, fmt.Errorf("mount %s: %w: %s", mountName, ErrUnknownHost, mount.Host))
+	}
+
+	if !l.stored[mount.Secret] {
+		l.problems = append(l.problems, fmt.Errorf("mount %s: %w: %s", mountName, ErrUnknownSecret, mount.Secret))
+	}
+}
+
+func (l *linter) lintRandom(secretName string, params internal.GenerationParamsRandom) {
+	if params.Length <= 0 {
+		l.report(secretName, ErrZeroLength)
+	}
+
+	enabled := false
+	for _, charset := range sortedKeys(params.Charsets) {
+		if _, ok := random.SupportedCharsets[charset]; !ok {
+			l.report(secretName, fmt.Errorf("%w: %s", random.ErrUnknownCharset, charset))
+			continue
+		}
+
+		enabled = enabled || params.Charsets[charset]
+	}
+
+	if !enabled {
+		l.report(secretName, random.ErrEmptyCharset)
+	}
+}
+
+// checkReadSecret reports target if it is passed to a parameter naming a secret and the secret does not exist.
+func (l *linter) checkReadSecret(secretName string, function *functions.Function, param int, target string) {
+	if param >= len(function.Params) || !function.Params[param].Secret {
+		return
+	}
+
+	if !l.stored[target] {
+		l.report(secretName, fmt.Errorf("%w read by %s: %s", ErrUnknownSecret, function.Name, target))
+	}
+}
+
+func (l *linter) lintJSON(secretName string, params internal.GenerationParamsJSON) {
+	l.lintJSONValue(secretName, params.Content)
+
+	for _, output := range sortedKeys(params.Outputs) {
+		l.lintJSONValue(secretName, params.Outputs[output].Content)
+	}
+}
+
+func (l *linter) lintJSONValue(secretName string, value any) {
+	switch cast := value.(type) {
+	case []any:
+		for _, item := range cast {
+			l.lintJSONValue(secretName, item)
+		}
+
+	case map[string]any:
+		if !json.IsFunctionCall(cast) {
+			for _, key := range sortedKeys(cast) {
+				l.lintJSONValue(secretName, cast[key])
+			}
+			return
+		}
+
+		name, args, err := json.ParseFunctionCall(cast)
+		if err != nil {
+			l.report(secretName, err)
+			return
+		}
+
+		for _, key := range sortedKeys(args) {
+			l.lintJSONValue(secretName, args[key])
+		}
+
+		function, err := functions.Lookup(name)
+		if err != nil {
+			l.report(secretName, err)
+			return
+		}
+
+		if err := function.CheckNamed(args); err != nil {
+			l.report(secretName, err)
+			return
+		}
+
+		for i, param := range function.Params {
+			if target, ok := args[param.Name].(string); ok {
+				l.checkReadSecret(secretName, function, i, target)
+			}
+		}
+	}
+}
+
+func (l *linter) lintTemplate(secretName string, params internal.GenerationParamsTemplate) {
+	if params.Outputs == nil {
+		l.lintTemplateContent(secretName, secretName, params.Content, params)
+		return
+	}
+
+	for _, output := range sortedKeys(params.Outputs) {
+		l.lintTemplateContent(secretName, internal.OutputSecretName(secretName, output), params.Outputs[output], params)
+	}
+}
+
+func (l *linter) lintTemplateContent(secretName, name, content string, params internal.GenerationParamsTemplate) {
+	tmpl, err := template.Parse(name, content, params)
+	if err != nil {
+		l.report(secretName, err)
+		return
+	}
+
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil {
+			continue
+		}
+
+		w := templateWalker{
+			linter:     l,
+			secretName: secretName,
+			tree:       t.Tree,
+			params:     params,
+		}
+
+		// Templates other than the main one can be called with any data.
+		w.walk(t.Tree.Root, t.Name() == name)
+	}
+}
+
+// templateWalker checks the function calls and data lookups in a parsed template.
+type templateWalker struct {
+	*linter
+
+	secretName string
+	tree       *parse.Tree
+	params     internal.GenerationParamsTemplate
+}
+
+func (w *templateWalker) reportNode(node parse.Node, err error) {
+	location, _ := w.tree.ErrorContext(node)
+	w.report(w.secretName, fmt.Errorf("%s: %w", location, err))
+}
+
+// walk walks node. atRoot is whether dot refers to the template data at node.
+func (w *templateWalker) walk(node parse.Node, atRoot bool) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+		for _, child := range node.Nodes {
+			w.walk(child, atRoot)
+		}
+
+	case *parse.ActionNode:
+		w.walk(node.Pipe, atRoot)
+
+	case *parse.IfNode:
+		w.walkBranch(&node.BranchNode, atRoot, atRoot)
+
+	case *parse.RangeNode:
+		w.walkBranch(&node.BranchNode, atRoot, false)
+
+	case *parse.WithNode:
+		w.walkBranch(&node.BranchNode, atRoot, false)
+
+	case *parse.TemplateNode:
+		w.walk(node.Pipe, atRoot)
+
+	case *parse.PipeNode:
+		if node == nil {
+			return
+		}
+		for i, cmd := range node.Cmds {
+			// Commands after the first receive the result of the previous command as an additional last argument.
+			w.walkCommand(cmd, i > 0, atRoot)
+		}
+
+	case *parse.ChainNode:
+		w.walk(node.Node, atRoot)
+
+	case *parse.FieldNode:
+		if atRoot {
+			w.lookupData(node, node.Ident)
+		}
+
+	case *parse.VariableNode:
+		if node.Ident[0] == "$" {
+			w.lookupData(node, node.Ident[1:])
+		}
+	}
+}
+
+func (w *templateWalker) walkBranch(node *parse.BranchNode, atRoot, listAtRoot bool) {
+	w.walk(node.Pipe, atRoot)
+	w.walk(node.List, listAtRoot)
+	w.walk(node.ElseList, atRoot)
+}
+
+func (w *templateWalker) walkCommand(cmd *parse.CommandNode, piped bool, atRoot bool) {
+	for _, arg := range cmd.Args {
+		w.walk(arg, atRoot)
+	}
+
+	ident, ok := cmd.Args[0].(*parse.IdentifierNode)
+	if !ok {
+		return
+	}
+
+	function, err := functions.Lookup(ident.Ident)
+	if err != nil {
+		// Builtin template functions like printf are not in the registry.
+		return
+	}
+
+	args := cmd.Args[1:]
+	count := len(args)
+	if piped {
+		count++
+	}
+
+	if err := function.CheckPositional(count); err != nil {
+		w.reportNode(cmd, err)
+		return
+	}
+
+	for i, arg := range args {
+		if target, ok := template.ResolveString(w.params.Data, arg, atRoot); ok {
+			w.checkReadSecret(w.secretName, function, i, target)
+		}
+	}
+}
+
+// lookupData reports a missing key if the template is strict and path leads to a key that doesn't exist in the data.
+func (w *templateWalker) lookupData(node parse.Node, path []string) {
+	if !w.params.IsStrict() {
+		return
+	}
+
+	if _, missing, _ := template.ResolveData(w.params.Data, path); missing != "" {
+		w.reportNode(node, fmt.Errorf("%w: %s", ErrMissingKey, missing))
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+	return keys
+}
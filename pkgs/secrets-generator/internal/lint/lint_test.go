@@ -0,0 +1,279 @@
+package lint_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tbx.at/secrets-generator/internal"
+	"tbx.at/secrets-generator/internal/generate"
+	"tbx.at/secrets-generator/internal/generator/functions"
+	"tbx.at/secrets-generator/internal/generator/importer"
+	"tbx.at/secrets-generator/internal/generator/random"
+	"tbx.at/secrets-generator/internal/lint"
+	"tbx.at/secrets-generator/internal/testutil"
+)
+
+var allCharsets = map[string]bool{"lowercase": true, "numbers": true, "special": true, "uppercase": true}
+
+func validConfig() internal.Config {
+	return internal.Config{
+		PublicKeys: map[string][]string{
+			"host": {"age1..."},
+		},
+
+		Secrets: map[string]internal.Secret{
+			"password": {Generation: internal.GenerationParams{
+				Random: &internal.GenerationParamsRandom{Charsets: allCharsets, Length: 32},
+			}},
+			"hash": {Generation: internal.GenerationParams{
+				JSON: &internal.GenerationParamsJSON{
+					Content: testutil.JSONFunctionCall("hashBcrypt", map[string]any{
+						"data":   testutil.JSONFunctionCall("readSecret", map[string]any{"name": "password"}),
+						"rounds": float64(10),
+					}),
+				},
+			}},
+			"config": {Generation: internal.GenerationParams{
+				Template: &internal.GenerationParamsTemplate{
+					Data: map[string]any{
+						"Password": "password",
+						"Users":    []any{"a", "b"},
+					},
+					Outputs: map[string]string{
+						"env": `PASSWORD={{ readSecret .Password | printf "%s" }}`,
+						"users": `{{ range .Users }}{{ . }} {{ readSecret $.Password }}{{ end }}` +
+							`{{ with .Users }}{{ .Anything }}{{ end }}`,
+					},
+				},
+			}},
+			"script": {Generation: internal.GenerationParams{
+				Script: &internal.GenerationParamsScript{Program: "/bin/generate"},
+			}},
+			"imported": {},
+			"vendor": {Generation: internal.GenerationParams{
+				Import: &internal.GenerationParamsImport{Pass: "vendor/api-key"},
+			}},
+		},
+
+		SecretMounts: map[string]internal.SecretMount{
+			"password": {Host: "host", Secret: "password"},
+			"env":      {Host: "host", Secret: "config/env"},
+			"imported": {Host: "host", Secret: "imported"},
+		},
+	}
+}
+
+func TestLintValid(t *testing.T) {
+	assert.Empty(t, lint.Lint(validConfig()))
+}
+
+func TestLintProblems(t *testing.T) {
+	for name, test := range map[string]struct {
+		secret   internal.Secret
+		expected error
+		message  string
+	}{
+		"multiple methods": {
+			secret: internal.Secret{Generation: internal.GenerationParams{
+				Random: &internal.GenerationParamsRandom{Charsets: allCharsets, Length: 1},
+				Script: &internal.GenerationParamsScript{Program: "/bin/generate"},
+			}},
+			expected: lint.ErrMultipleMethods,
+		},
+		"custom and another method": {
+			secret: internal.Secret{Generation: internal.GenerationParams{
+				Random: &internal.GenerationParamsRandom{Charsets: allCharsets, Length: 1},
+				Custom: &internal.GenerationParamsCustom{Type: "token"},
+			}},
+			expected: lint.ErrMultipleMethods,
+		},
+		"unknown charset": {
+			secret: internal.Secret{Generation: internal.GenerationParams{
+				Random: &internal.GenerationParamsRandom{Charsets: map[string]bool{"numbers": true, "emoji": true}, Length: 1},
+			}},
+			expected: random.ErrUnknownCharset,
+			message:  "secret problem: unknown charset: emoji",
+		},
+		"empty charset": {
+			secret: internal.Secret{Generation: internal.GenerationParams{
+				Random: &internal.GenerationParamsRandom{Charsets: map[string]bool{"numbers": false}, Length: 1},
+			}},
+			expected: random.ErrEmptyCharset,
+		},
+		"zero length": {
+			secret: internal.Secret{Generation: internal.GenerationParams{
+				Random: &internal.GenerationParamsRandom{Charsets: allCharsets},
+			}},
+			expected: lint.ErrZeroLength,
+		},
+		"empty program": {
+			secret: internal.Secret{Generation: internal.GenerationParams{
+				Script: &internal.GenerationParamsScript{},
+			}},
+			expected: lint.ErrEmptyProgram,
+		},
+		"unparsable template": {
+			secret: internal.Secret{Generation: internal.GenerationParams{
+				Template: &internal.GenerationParamsTemplate{Content: `{{ if }}`},
+			}},
+			message: "secret problem: template: problem:1: missing value for if",
+		},
+		"unknown template function": {
+			secret: internal.Secret{Generation: internal.GenerationParams{
+				Template: &internal.GenerationParamsTemplate{Content: `{{ doesNotExist }}`},
+			}},
+			message: `secret problem: template: problem:1: function "doesNotExist" not defined`,
+		},
+		"template argument count": {
+			secret: internal.Secret{Generation: internal.GenerationParams{
+				Template: &internal.GenerationParamsTemplate{Content: "\n{{ \"a\" | hashBcrypt }}"},
+			}},
+			expected: functions.ErrArgumentCount,
+			message:  `secret problem: problem:2:9: wrong number of function arguments in call to hashBcrypt: wanted 2, got 1`,
+		},
+		"template missing key": {
+			secret: internal.Secret{Generation: internal.GenerationParams{
+				Template: &internal.GenerationParamsTemplate{
+					Data:    map[string]any{"A": map[string]any{"B": "c"}},
+					Content: `{{ .A.B }} {{ .A.C }}`,
+				},
+			}},
+			expected: lint.ErrMissingKey,
+			message:  `secret problem: problem:1:16: template refers to a key that is missing from its data: C`,
+		},
+		"template read secret literal": {
+			secret: internal.Secret{Generation: internal.GenerationParams{
+				Template: &internal.GenerationParamsTemplate{Content: `{{ readSecretJSON "missing" }}`},
+			}},
+			expected: lint.ErrUnknownSecret,
+			message:  "secret problem: unknown secret read by readSecretJSON: missing",
+		},
+		"template read secret data": {
+			secret: internal.Secret{Generation: internal.GenerationParams{
+				Template: &internal.GenerationParamsTemplate{
+					Data:    map[string]any{"Secret": "config"},
+					Content: `{{ readSecret .Secret }}`,
+				},
+			}},
+			expected: lint.ErrUnknownSecret,
+			message:  "secret problem: unknown secret read by readSecret: config",
+		},
+		"json unknown function": {
+			secret: internal.Secret{Generation: internal.GenerationParams{
+				JSON: &internal.GenerationParamsJSON{
+					Content: []any{testutil.JSONFunctionCall("doesNotExist", map[string]any{})},
+				},
+			}},
+			expected: functions.ErrFunctionDoesNotExist,
+		},
+		"json missing argument": {
+			secret: internal.Secret{Generation: internal.GenerationParams{
+				JSON: &internal.GenerationParamsJSON{
+					Content: map[string]any{"hash": testutil.JSONFunctionCall("hashBcrypt", map[string]any{"data": "a"})},
+				},
+			}},
+			expected: functions.ErrArgumentMissing,
+		},
+		"json read secret": {
+			secret: internal.Secret{Generation: internal.GenerationParams{
+				JSON: &internal.GenerationParamsJSON{
+					Outputs: map[string]internal.GenerationParamsJSONOutput{
+						"a": {Content: testutil.JSONFunctionCall("readSecretField", map[string]any{"name": "missing", "path": "a"})},
+					},
+				},
+			}},
+			expected: lint.ErrUnknownSecret,
+		},
+		"import without source": {
+			secret: internal.Secret{Generation: internal.GenerationParams{
+				Import: &internal.GenerationParamsImport{PassCommand: "gopass"},
+			}},
+			expected: importer.ErrNoSource,
+		},
+		"import with multiple sources": {
+			secret: internal.Secret{Generation: internal.GenerationParams{
+				Import: &internal.GenerationParamsImport{File: "/run/keys/api", Env: "API_KEY"},
+			}},
+			expected: importer.ErrMultipleSources,
+		},
+		"outputs and content": {
+			secret: internal.Secret{Generation: internal.GenerationParams{
+				Template: &internal.GenerationParamsTemplate{Content: "a", Outputs: map[string]string{"b": "b"}},
+			}},
+			expected: generate.ErrOutputsAndContent,
+		},
+		"unknown dependency": {
+			secret: internal.Secret{
+				Generation: internal.GenerationParams{
+					Template: &internal.GenerationParamsTemplate{Content: "a"},
+				},
+				DependsOn: []string{"missing"},
+			},
+			expected: generate.ErrUnknownDependency,
+		},
+		"dependency on itself": {
+			secret: internal.Secret{Generation: internal.GenerationParams{
+				Template: &internal.GenerationParamsTemplate{Content: `{{ readSecret "problem" }}`},
+			}},
+			expected: generate.ErrDependencyCycle,
+			message:  "secrets depend on each other: problem -> problem",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config := validConfig()
+			config.Secrets["problem"] = test.secret
+
+			problems := lint.Lint(config)
+			require.Len(t, problems, 1)
+
+			if test.expected != nil {
+				assert.ErrorIs(t, problems[0], test.expected)
+			}
+
+			if test.message != "" {
+				assert.EqualError(t, problems[0], test.message)
+			}
+		})
+	}
+}
+
+func TestLintTemplateNotStrict(t *testing.T) {
+	config := validConfig()
+	config.Secrets["lenient"] = internal.Secret{Generation: internal.GenerationParams{
+		Template: &internal.GenerationParamsTemplate{
+			Content: `{{ .Missing }}`,
+			Strict:  new(bool),
+		},
+	}}
+
+	assert.Empty(t, lint.Lint(config))
+}
+
+func TestLintMounts(t *testing.T) {
+	config := validConfig()
+	config.SecretMounts["a"] = internal.SecretMount{Host: "unknown", Secret: "password"}
+	config.SecretMounts["b"] = internal.SecretMount{Host: "host", Secret: "config"}
+
+	problems := lint.Lint(config)
+	require.Len(t, problems, 2)
+
+	assert.ErrorIs(t, problems[0], lint.ErrUnknownHost)
+	assert.EqualError(t, problems[0], "mount a: unknown host: unknown")
+
+	// Secrets with outputs can only be mounted per output.
+	assert.ErrorIs(t, problems[1], lint.ErrUnknownSecret)
+	assert.EqualError(t, problems[1], "mount b: unknown secret: config")
+}
+
+func TestLintMountMetadata(t *testing.T) {
+	config := validConfig()
+	config.SecretMounts["a"] = internal.SecretMount{Host: "host", Secret: "password", Mode: "u=r,go=", Path: "/run/agenix/password"}
+	config.SecretMounts["b"] = internal.SecretMount{Host: "host", Secret: "password", Mode: "a=r", Path: "/run/agenix/password"}
+
+	problems := lint.Lint(config)
+	require.Len(t, problems, 2)
+
+	assert.ErrorIs(t, problems[0], internal.ErrWorldReadable)
+	assert.ErrorIs(t, problems[1], internal.ErrDuplicatePath)
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package internal
+
+// lockMemory always fails since locking memory is only supported on Unix systems.
+func lockMemory(size int) ([]byte, error) {
+	return nil, ErrLockMemoryUnsupported
+}
+
+func unlockMemory(memory []byte) {
+	clear(memory)
+}
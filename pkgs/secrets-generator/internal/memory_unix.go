@@ -0,0 +1,41 @@
+//go:build unix
+
+package internal
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockMemory allocates size bytes that are locked into memory, so that they are never written to swap.
+// The memory is mapped separately from the Go heap, which lets unlockMemory release it without affecting anything else.
+func lockMemory(size int) ([]byte, error) {
+	if size == 0 {
+		return []byte{}, nil
+	}
+
+	memory, err := unix.Mmap(-1, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANON)
+	if err != nil {
+		return nil, os.NewSyscallError("mmap", err)
+	}
+
+	if err := unix.Mlock(memory); err != nil {
+		_ = unix.Munmap(memory)
+		return nil, os.NewSyscallError("mlock", err)
+	}
+
+	return memory, nil
+}
+
+// unlockMemory wipes and releases memory allocated by lockMemory.
+func unlockMemory(memory []byte) {
+	clear(memory)
+
+	if len(memory) == 0 {
+		return
+	}
+
+	// Unmapping the memory unlocks it as well.
+	_ = unix.Munmap(memory)
+}
@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidMode = errors.New("invalid file mode")
+
+// Permission bits for each class of users in a file mode, in the order user, group, others.
+var modeClasses = map[byte]fs.FileMode{
+	'u': 0700,
+	'g': 0070,
+	'o': 0007,
+}
+
+// ParseMode parses a file mode as accepted by chmod, like the mode of a secret mount.
+// Modes are either octal numbers like "0400" or symbolic like "u=r,go=".
+// Symbolic modes are applied to a file without any permissions, so permissions that aren't mentioned are unset.
+func ParseMode(mode string) (fs.FileMode, error) {
+	if mode != "" && strings.Trim(mode, "01234567") == "" {
+		octal, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil || octal > 07777 {
+			return 0, fmt.Errorf("%w: %s", ErrInvalidMode, mode)
+		}
+
+		return fromUnixMode(uint32(octal)), nil
+	}
+
+	var result fs.FileMode
+
+	for _, clause := range strings.Split(mode, ",") {
+		if err := applyClause(&result, clause); err != nil {
+			return 0, fmt.Errorf("%w: %s", ErrInvalidMode, mode)
+		}
+	}
+
+	return result, nil
+}
+
+// applyClause applies one comma-separated clause of a symbolic mode, like "go-w", to mode.
+func applyClause(mode *fs.FileMode, clause string) error {
+	// An empty list of classes means all of them.
+	// chmod would respect the umask in that case, but there is no umask for mounted secrets.
+	classes := fs.FileMode(0)
+	i := 0
+	for ; i < len(clause) && strings.IndexByte("ugoa", clause[i]) >= 0; i++ {
+		if clause[i] == 'a' {
+			classes |= 0777
+		} else {
+			classes |= modeClasses[clause[i]]
+		}
+	}
+	if classes == 0 {
+		classes = 0777
+	}
+
+	if i == len(clause) {
+		return ErrInvalidMode
+	}
+
+	for i < len(clause) {
+		op := clause[i]
+		if op != '+' && op != '-' && op != '=' {
+			return ErrInvalidMode
+		}
+		i++
+
+		var perms, special fs.FileMode
+
+		if i < len(clause) && modeClasses[clause[i]] != 0 {
+			// Permissions can be copied from another class, like in "g=u".
+			from := modeClasses[clause[i]]
+			bits := (*mode & from) / (from & 0111)
+			perms = bits * 0111
+			i++
+		} else {
+			for ; i < len(clause) && strings.IndexByte("rwxXst", clause[i]) >= 0; i++ {
+				switch clause[i] {
+				case 'r':
+					perms |= 0444
+				case 'w':
+					perms |= 0222
+				case 'x':
+					perms |= 0111
+				case 'X':
+					// Secrets are always files, so X only adds execute permission if some class already has it.
+					if *mode&0111 != 0 {
+						perms |= 0111
+					}
+				case 's':
+					if classes&0700 != 0 {
+						special |= fs.ModeSetuid
+					}
+					if classes&0070 != 0 {
+						special |= fs.ModeSetgid
+					}
+				case 't':
+					special |= fs.ModeSticky
+				}
+			}
+		}
+
+		perms &= classes
+
+		switch op {
+		case '+':
+			*mode |= perms | special
+		case '-':
+			*mode &^= perms | special
+		case '=':
+			*mode &^= classes
+			if classes&0700 != 0 {
+				*mode &^= fs.ModeSetuid
+			}
+			if classes&0070 != 0 {
+				*mode &^= fs.ModeSetgid
+			}
+			*mode |= perms | special
+		}
+	}
+
+	return nil
+}
+
+// fromUnixMode converts the permission and special bits of a Unix file mode to an fs.FileMode.
+func fromUnixMode(mode uint32) fs.FileMode {
+	result := fs.FileMode(mode & 0777)
+
+	if mode&04000 != 0 {
+		result |= fs.ModeSetuid
+	}
+	if mode&02000 != 0 {
+		result |= fs.ModeSetgid
+	}
+	if mode&01000 != 0 {
+		result |= fs.ModeSticky
+	}
+
+	return result
+}
@@ -0,0 +1,53 @@
+package internal_test
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"tbx.at/secrets-generator/internal"
+)
+
+func TestParseMode(t *testing.T) {
+	for mode, expected := range map[string]fs.FileMode{
+		"u=r,go=":    0400,
+		"u=rw,g=r":   0640,
+		"a=r":        0444,
+		"=r":         0444,
+		"ug=rw,o-rw": 0660,
+		"u=rwx,g=u":  0770,
+		"u=rw,go=u":  0666,
+		"u=r,u+w":    0600,
+		"a=rw,go-w":  0644,
+		"u=x,a+X":    0111,
+		"u=r,a+X":    0400,
+		"u=rwxs":     0700 | fs.ModeSetuid,
+		"u+s,u=r":    0400,
+		"a=rt":       0444 | fs.ModeSticky,
+		"u=r-w":      0400,
+		"0400":       0400,
+		"640":        0640,
+		"4750":       0750 | fs.ModeSetuid,
+	} {
+		actual, err := internal.ParseMode(mode)
+		if assert.NoError(t, err, mode) {
+			assert.Equal(t, expected, actual, "%s: %v", mode, actual)
+		}
+	}
+}
+
+func TestParseModeInvalid(t *testing.T) {
+	for _, mode := range []string{
+		"",
+		"u",
+		"u=q",
+		"u=r,",
+		"r",
+		"99",
+		"17777",
+		"u=r go=",
+	} {
+		_, err := internal.ParseMode(mode)
+		assert.ErrorIs(t, err, internal.ErrInvalidMode, mode)
+	}
+}
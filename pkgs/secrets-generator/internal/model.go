@@ -1,6 +1,10 @@
 package internal
 
-import "path/filepath"
+import (
+	"path"
+	"path/filepath"
+	"slices"
+)
 
 const (
 	SecretsDirectory = "secrets"
@@ -16,18 +20,103 @@ type Config struct {
 }
 
 type Secret struct {
+	// Name is the key of the secret in Config.Secrets.
+	// It is filled in before the secret is passed to a generator.
+	Name string `json:"-"`
+
 	Generation GenerationParams `json:"generation"`
+
+	// DependsOn holds the secrets that the secret reads, in addition to those found in its generation parameters.
+	// Entries can also name outputs of secrets.
+	DependsOn []string `json:"dependsOn,omitempty"`
 }
 
 type GenerationParams struct {
+	Import   *GenerationParamsImport   `json:"import,omitempty"`
 	JSON     *GenerationParamsJSON     `json:"json"`
 	Random   *GenerationParamsRandom   `json:"random"`
 	Script   *GenerationParamsScript   `json:"script"`
 	Template *GenerationParamsTemplate `json:"template"`
+
+	// Custom selects a generator that is registered by a program embedding the generator.
+	Custom *GenerationParamsCustom `json:"custom,omitempty"`
+}
+
+// Names of the generation methods as used in the configuration.
+const (
+	GenerationTypeImport   = "import"
+	GenerationTypeJSON     = "json"
+	GenerationTypeRandom   = "random"
+	GenerationTypeScript   = "script"
+	GenerationTypeTemplate = "template"
+	GenerationTypeCustom   = "custom"
+)
+
+// Type returns the name of the generation method or an empty string if the secret is not generated.
+func (params GenerationParams) Type() string {
+	switch {
+	case params.Import != nil:
+		return GenerationTypeImport
+	case params.JSON != nil:
+		return GenerationTypeJSON
+	case params.Random != nil:
+		return GenerationTypeRandom
+	case params.Script != nil:
+		return GenerationTypeScript
+	case params.Template != nil:
+		return GenerationTypeTemplate
+	case params.Custom != nil:
+		return GenerationTypeCustom
+	default:
+		return ""
+	}
+}
+
+// GenerationParamsImport configures where a secret that is not generated is imported from.
+// Exactly one source must be set (see package importer).
+type GenerationParamsImport struct {
+	// File is the path of a file containing the secret.
+	File string `json:"file,omitempty"`
+
+	// Env is the name of an environment variable containing the secret.
+	Env string `json:"env,omitempty"`
+
+	// Pass is the name of an entry in a pass store.
+	// The secret is the first line of the entry.
+	Pass string `json:"pass,omitempty"`
+
+	// PassCommand is the pass compatible program used to read Pass, like gopass.
+	// It defaults to pass.
+	PassCommand string `json:"passCommand,omitempty"`
+
+	// Command is a program and its arguments that print the secret.
+	// One trailing newline is removed from the output.
+	Command []string `json:"command,omitempty"`
 }
 
 type GenerationParamsJSON struct {
 	Content any `json:"content"`
+
+	// Format is the format the content is written in (see package format).
+	// It defaults to JSON.
+	Format string `json:"format,omitempty"`
+
+	// Outputs replaces Content for secrets with multiple outputs (see Secret.Outputs).
+	Outputs map[string]GenerationParamsJSONOutput `json:"outputs,omitempty"`
+}
+
+type GenerationParamsJSONOutput struct {
+	Content any    `json:"content"`
+	Format  string `json:"format,omitempty"`
+}
+
+// GenerationParamsCustom configures a secret that is generated by a custom generator.
+type GenerationParamsCustom struct {
+	// Type is the name the generator is registered under.
+	Type string `json:"type"`
+
+	// Params are passed to the generator as they are.
+	Params any `json:"params,omitempty"`
 }
 
 type GenerationParamsRandom struct {
@@ -37,22 +126,109 @@ type GenerationParamsRandom struct {
 
 type GenerationParamsScript struct {
 	Program string `json:"program"`
+
+	// RuntimeInputs and Script are what Program was built from.
+	// They are part of the configuration but not used by the generator.
+	RuntimeInputs []string `json:"runtimeInputs,omitempty"`
+	Script        string   `json:"script,omitempty"`
 }
 
 type GenerationParamsTemplate struct {
 	Data    map[string]any `json:"data"`
 	Content string         `json:"content"`
+
+	// Outputs maps output names to template contents and replaces Content for secrets with multiple outputs (see Secret.Outputs).
+	Outputs map[string]string `json:"outputs,omitempty"`
+
+	// Strict makes missing keys in Data an error and rejects output containing "<no value>".
+	// It defaults to true, use IsStrict to read it.
+	Strict *bool `json:"strict,omitempty"`
+}
+
+// IsStrict returns whether the template should be executed in strict mode.
+func (params GenerationParamsTemplate) IsStrict() bool {
+	return params.Strict == nil || *params.Strict
 }
 
 type SecretMount struct {
 	Host   string `json:"host"`
 	Secret string `json:"secret"`
+
+	// Owner, Group, Mode and Path describe how the secret is mounted on the host.
+	// They are checked by CheckMounts and carried into bundles.
+	Owner string `json:"owner,omitempty"`
+	Group string `json:"group,omitempty"`
+	Mode  string `json:"mode,omitempty"`
+	Path  string `json:"path,omitempty"`
+
+	// SecretsDir is the directory that agenix mounts secrets into on the host.
+	// DefaultSecretsDir is assumed if it is empty.
+	SecretsDir string `json:"secretsDir,omitempty"`
+}
+
+// Outputs returns the sorted names of the outputs of a secret or nil if the secret has a single output.
+// Each output is stored as its own secret called OutputSecretName(secretName, output) and can be mounted and read like any other secret.
+// All outputs share one entropy file and are always regenerated together.
+func (secret Secret) Outputs() []string {
+	var outputs []string
+
+	switch {
+	case secret.Generation.JSON != nil:
+		for output := range secret.Generation.JSON.Outputs {
+			outputs = append(outputs, output)
+		}
+	case secret.Generation.Template != nil:
+		for output := range secret.Generation.Template.Outputs {
+			outputs = append(outputs, output)
+		}
+	}
+
+	slices.Sort(outputs)
+	return outputs
+}
+
+// OutputSecretName returns the name under which an output of a secret is stored.
+func OutputSecretName(secretName, output string) string {
+	return secretName + "/" + output
+}
+
+// StoredSecretNames returns the names under which the contents of a secret are stored.
+// This is the name of the secret itself for secrets with a single output, or the names of all outputs otherwise.
+func StoredSecretNames(secretName string, secret Secret) []string {
+	outputs := secret.Outputs()
+	if outputs == nil {
+		return []string{secretName}
+	}
+
+	names := make([]string, len(outputs))
+	for i, output := range outputs {
+		names[i] = OutputSecretName(secretName, output)
+	}
+
+	return names
+}
+
+// EntropyKey returns the storage key of the entropy file of a secret.
+func EntropyKey(secretName string) string {
+	return path.Join(SecretsEntropyDirectory, secretName+".age")
+}
+
+// SecretKey returns the storage key of the age file holding a secret.
+func SecretKey(secretName string) string {
+	return EncodedSecretKey(AgeEncoding{}, secretName)
+}
+
+// EncodedSecretKey returns the storage key of the file holding a secret in the given encoding.
+func EncodedSecretKey(encoding SecretEncoding, secretName string) string {
+	return path.Join(SecretsDataDirectory, secretName+encoding.Extension())
 }
 
+// EntropyFilePath returns the path of the entropy file of a secret in the default local storage.
 func EntropyFilePath(secretName string) string {
-	return filepath.Join(SecretsDirectory, SecretsEntropyDirectory, secretName+".age")
+	return filepath.Join(SecretsDirectory, filepath.FromSlash(EntropyKey(secretName)))
 }
 
+// SecretFilePath returns the path of the file holding a secret in the default local storage.
 func SecretFilePath(secretName string) string {
-	return filepath.Join(SecretsDirectory, SecretsDataDirectory, secretName+".age")
+	return filepath.Join(SecretsDirectory, filepath.FromSlash(SecretKey(secretName)))
 }
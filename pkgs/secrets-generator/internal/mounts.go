@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// DefaultSecretsDir is the directory that agenix mounts secrets into unless configured otherwise.
+const DefaultSecretsDir = "/run/agenix"
+
+var (
+	ErrDuplicatePath     = errors.New("path is used by multiple mounts on the same host")
+	ErrOutsideSecretsDir = errors.New("path is outside of the secrets directory")
+	ErrWorldReadable     = errors.New("secret is readable by everyone")
+)
+
+// CheckMounts validates the metadata of secret mounts and returns all problems found, ordered by mount name.
+// Metadata that isn't set is not checked.
+func CheckMounts(mounts map[string]SecretMount) []error {
+	var problems []error
+	report := func(mountName string, err error) {
+		problems = append(problems, fmt.Errorf("mount %s: %w", mountName, err))
+	}
+
+	// paths maps hosts to the paths on them and the first mount using each path.
+	paths := make(map[string]map[string]string)
+
+	for _, mountName := range sortedKeys(mounts) {
+		mount := mounts[mountName]
+
+		if mount.Mode != "" {
+			mode, err := ParseMode(mount.Mode)
+			if err != nil {
+				report(mountName, err)
+			} else if mode&0004 != 0 {
+				report(mountName, fmt.Errorf("%w: %s", ErrWorldReadable, mount.Mode))
+			}
+		}
+
+		if mount.Path == "" {
+			continue
+		}
+
+		mountPath := path.Clean(mount.Path)
+
+		secretsDir := mount.SecretsDir
+		if secretsDir == "" {
+			secretsDir = DefaultSecretsDir
+		}
+
+		if !strings.HasPrefix(mountPath, path.Clean(secretsDir)+"/") {
+			report(mountName, fmt.Errorf("%w: %s is not in %s", ErrOutsideSecretsDir, mount.Path, secretsDir))
+		}
+
+		if paths[mount.Host] == nil {
+			paths[mount.Host] = make(map[string]string)
+		}
+
+		if other, ok := paths[mount.Host][mountPath]; ok {
+			report(mountName, fmt.Errorf("%w: %s on host %s is also used by mount %s", ErrDuplicatePath, mount.Path, mount.Host, other))
+		} else {
+			paths[mount.Host][mountPath] = mountName
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,30 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tbx.at/secrets-generator/internal"
+)
+
+func TestCheckMounts(t *testing.T) {
+	problems := internal.CheckMounts(map[string]internal.SecretMount{
+		"a": {Host: "host", Secret: "a", Mode: "u=r,go=", Path: "/run/agenix/a"},
+		"b": {Host: "host", Secret: "b", Mode: "a=r", Path: "/run/agenix/b"},
+		"c": {Host: "host", Secret: "c", Mode: "u=r,go=", Path: "/run/agenix/./a"},
+		"d": {Host: "other", Secret: "d", Mode: "u=r,go=", Path: "/run/agenix/a"},
+		"e": {Host: "host", Secret: "e", Mode: "u=r,go=", Path: "/etc/e"},
+		"f": {Host: "host", Secret: "f", Mode: "u=r,go=", Path: "/var/lib/secrets/f", SecretsDir: "/var/lib/secrets"},
+		"g": {Host: "host", Secret: "g", Mode: "u=read"},
+		"h": {Host: "host", Secret: "h", Path: "/run/agenix"},
+		"i": {Host: "host", Secret: "i"},
+	})
+
+	require.Len(t, problems, 5)
+	assert.EqualError(t, problems[0], "mount b: secret is readable by everyone: a=r")
+	assert.EqualError(t, problems[1], "mount c: path is used by multiple mounts on the same host: /run/agenix/./a on host host is also used by mount a")
+	assert.EqualError(t, problems[2], "mount e: path is outside of the secrets directory: /etc/e is not in /run/agenix")
+	assert.ErrorIs(t, problems[3], internal.ErrInvalidMode)
+	assert.ErrorIs(t, problems[4], internal.ErrOutsideSecretsDir)
+}
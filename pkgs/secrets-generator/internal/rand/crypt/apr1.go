@@ -0,0 +1,89 @@
+package crypt
+
+import (
+	"crypto/md5"
+	"io"
+	"strings"
+)
+
+const (
+	apr1Magic      = "$apr1$"
+	apr1SaltLength = 8
+	apr1Rounds     = 1000
+)
+
+var apr1Permutation = [4][3]int{
+	{0, 6, 12}, {1, 7, 13}, {2, 8, 14}, {3, 9, 15},
+}
+
+// APR1 creates an Apache variant of the MD5 based crypt hash ($apr1$) as used by htpasswd.
+// The salt consists of 8 characters, for which 6 bytes are read from rng.
+func APR1(rng io.Reader, password []byte) (string, error) {
+	salt, err := randomSalt(rng, apr1SaltLength)
+	if err != nil {
+		return "", err
+	}
+
+	return apr1WithSalt(password, []byte(salt)), nil
+}
+
+func apr1WithSalt(password, salt []byte) string {
+	alternate := md5.New()
+	alternate.Write(password)
+	alternate.Write(salt)
+	alternate.Write(password)
+	digestAlternate := alternate.Sum(nil)
+
+	ctx := md5.New()
+	ctx.Write(password)
+	ctx.Write([]byte(apr1Magic))
+	ctx.Write(salt)
+	writeRepeated(ctx, digestAlternate, len(password))
+	for n := len(password); n > 0; n >>= 1 {
+		if n&1 != 0 {
+			ctx.Write([]byte{0})
+		} else {
+			ctx.Write(password[:1])
+		}
+	}
+	digest := ctx.Sum(nil)
+
+	for i := 0; i < apr1Rounds; i++ {
+		round := md5.New()
+
+		if i&1 != 0 {
+			round.Write(password)
+		} else {
+			round.Write(digest)
+		}
+
+		if i%3 != 0 {
+			round.Write(salt)
+		}
+
+		if i%7 != 0 {
+			round.Write(password)
+		}
+
+		if i&1 != 0 {
+			round.Write(digest)
+		} else {
+			round.Write(password)
+		}
+
+		digest = round.Sum(nil)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(apr1Magic)
+	sb.Write(salt)
+	sb.WriteByte('$')
+
+	for _, idx := range apr1Permutation {
+		encode24(&sb, digest[idx[0]], digest[idx[1]], digest[idx[2]], 4)
+	}
+	encode24(&sb, digest[4], digest[10], digest[5], 4)
+	encode24(&sb, 0, 0, digest[11], 2)
+
+	return sb.String()
+}
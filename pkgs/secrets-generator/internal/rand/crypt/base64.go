@@ -0,0 +1,32 @@
+package crypt
+
+import (
+	"io"
+	"strings"
+)
+
+const alphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// encode24 appends n characters encoding the 24 bit value b2 b1 b0, least significant bits first.
+func encode24(sb *strings.Builder, b2, b1, b0 byte, n int) {
+	w := uint32(b2)<<16 | uint32(b1)<<8 | uint32(b0)
+	for ; n > 0; n-- {
+		sb.WriteByte(alphabet[w&0x3f])
+		w >>= 6
+	}
+}
+
+// randomSalt reads 3 bytes from rng for every 4 characters of salt.
+func randomSalt(rng io.Reader, length int) (string, error) {
+	raw := make([]byte, (length+3)/4*3)
+	if _, err := io.ReadFull(rng, raw); err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(raw); i += 3 {
+		encode24(&sb, raw[i], raw[i+1], raw[i+2], 4)
+	}
+
+	return sb.String()[:length], nil
+}
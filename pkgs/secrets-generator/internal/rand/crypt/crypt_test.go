@@ -0,0 +1,77 @@
+package crypt
+
+import (
+	"crypto/rand"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Reference hashes were created with libxcrypt ($6$) and OpenSSL ($apr1$).
+
+func TestSHA512Vectors(t *testing.T) {
+	vectors := []struct {
+		password, salt string
+		rounds         int
+		hash           string
+	}{
+		{"Hello world!", "saltstring", 5000, "$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1"},
+		{"Hello world!", "saltstringsaltst", 10000, "$6$rounds=10000$saltstringsaltst$OW1/O6BYHV6BcXZu8QVeXbDWra3Oeqh0sbHbbMCVNSnCM/UrjmM0Dp8vOuZeHBy/YTBmSK6H9qs/y3RnOaw5v."},
+		{"", "abcdefghijklmnop", 5000, "$6$abcdefghijklmnop$6.vC8ffobuN7AxcHvesxeeksF2DXFfpYyFt3PFU8pYpEQPhWFSN7hwaUQRfHg/LkfB3jIPEitUcU7ZTqjaQUp1"},
+		{strings.Repeat("a", 100), "abcdefghijklmnop", 5000, "$6$abcdefghijklmnop$ZW3yixa3ExXjp32Kntt.IV2krvDXw.nWdGkqpCo65dluAx.25kaQM.iYywVP3SFVNEaptdOkL99N6Nf98Bmnr/"},
+	}
+
+	for _, vector := range vectors {
+		hash := sha512WithSalt([]byte(vector.password), []byte(vector.salt), vector.rounds)
+		if hash != vector.hash {
+			t.Errorf("hash for %q = %q, want %q", vector.password, hash, vector.hash)
+		}
+	}
+}
+
+func TestAPR1Vectors(t *testing.T) {
+	vectors := []struct {
+		password, salt, hash string
+	}{
+		{"Hello world!", "saltsalt", "$apr1$saltsalt$6BwcdpRros16.J9J/tHRr/"},
+		{"", "ab", "$apr1$ab$S8K6Sgp3W8c9Jb6LxgywZ."},
+		{strings.Repeat("x", 40), "12345678", "$apr1$12345678$lmvIejSHXo9SZNHoskkNr0"},
+	}
+
+	for _, vector := range vectors {
+		hash := apr1WithSalt([]byte(vector.password), []byte(vector.salt))
+		if hash != vector.hash {
+			t.Errorf("hash for %q = %q, want %q", vector.password, hash, vector.hash)
+		}
+	}
+}
+
+func TestSHA512(t *testing.T) {
+	hashRX := regexp.MustCompile(`^\$6\$[./0-9A-Za-z]{16}\$[./0-9A-Za-z]{86}$`)
+
+	hash, err := SHA512(rand.Reader, []byte("pa$$word"), SHA512DefaultRounds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !hashRX.MatchString(hash) {
+		t.Errorf("hash %q not in correct format", hash)
+	}
+
+	if _, err := SHA512(rand.Reader, []byte("pa$$word"), SHA512MinRounds-1); err == nil {
+		t.Error("expected an error for too few rounds")
+	}
+}
+
+func TestAPR1(t *testing.T) {
+	hashRX := regexp.MustCompile(`^\$apr1\$[./0-9A-Za-z]{8}\$[./0-9A-Za-z]{22}$`)
+
+	hash, err := APR1(rand.Reader, []byte("pa$$word"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !hashRX.MatchString(hash) {
+		t.Errorf("hash %q not in correct format", hash)
+	}
+}
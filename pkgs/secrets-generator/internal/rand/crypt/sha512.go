@@ -0,0 +1,130 @@
+package crypt
+
+import (
+	"crypto/sha512"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	SHA512DefaultRounds = 5000
+	SHA512MinRounds     = 1000
+	SHA512MaxRounds     = 999999999
+
+	sha512SaltLength = 16
+)
+
+var sha512Permutation = [21][3]int{
+	{0, 21, 42}, {22, 43, 1}, {44, 2, 23}, {3, 24, 45}, {25, 46, 4},
+	{47, 5, 26}, {6, 27, 48}, {28, 49, 7}, {50, 8, 29}, {9, 30, 51},
+	{31, 52, 10}, {53, 11, 32}, {12, 33, 54}, {34, 55, 13}, {56, 14, 35},
+	{15, 36, 57}, {37, 58, 16}, {59, 17, 38}, {18, 39, 60}, {40, 61, 19},
+	{62, 20, 41},
+}
+
+// SHA512 creates a SHA-512 based crypt hash ($6$) as specified by Ulrich Drepper.
+// The salt consists of 16 characters, for which 12 bytes are read from rng.
+// The rounds are only included in the hash if they differ from SHA512DefaultRounds (like glibc does).
+func SHA512(rng io.Reader, password []byte, rounds int) (string, error) {
+	if rounds < SHA512MinRounds || rounds > SHA512MaxRounds {
+		return "", fmt.Errorf("sha512crypt: rounds must be between %d and %d, got %d", SHA512MinRounds, SHA512MaxRounds, rounds)
+	}
+
+	salt, err := randomSalt(rng, sha512SaltLength)
+	if err != nil {
+		return "", err
+	}
+
+	return sha512WithSalt(password, []byte(salt), rounds), nil
+}
+
+func sha512WithSalt(key, salt []byte, rounds int) string {
+	b := sha512.New()
+	b.Write(key)
+	b.Write(salt)
+	b.Write(key)
+	digestB := b.Sum(nil)
+
+	a := sha512.New()
+	a.Write(key)
+	a.Write(salt)
+	writeRepeated(a, digestB, len(key))
+	for n := len(key); n > 0; n >>= 1 {
+		if n&1 != 0 {
+			a.Write(digestB)
+		} else {
+			a.Write(key)
+		}
+	}
+	digestA := a.Sum(nil)
+
+	dp := sha512.New()
+	for range key {
+		dp.Write(key)
+	}
+	p := repeatToLength(dp.Sum(nil), len(key))
+
+	ds := sha512.New()
+	for i := 0; i < 16+int(digestA[0]); i++ {
+		ds.Write(salt)
+	}
+	s := repeatToLength(ds.Sum(nil), len(salt))
+
+	digest := digestA
+	for i := 0; i < rounds; i++ {
+		c := sha512.New()
+
+		if i&1 != 0 {
+			c.Write(p)
+		} else {
+			c.Write(digest)
+		}
+
+		if i%3 != 0 {
+			c.Write(s)
+		}
+
+		if i%7 != 0 {
+			c.Write(p)
+		}
+
+		if i&1 != 0 {
+			c.Write(digest)
+		} else {
+			c.Write(p)
+		}
+
+		digest = c.Sum(nil)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("$6$")
+	if rounds != SHA512DefaultRounds {
+		fmt.Fprintf(&sb, "rounds=%d$", rounds)
+	}
+	sb.Write(salt)
+	sb.WriteByte('$')
+
+	for _, idx := range sha512Permutation {
+		encode24(&sb, digest[idx[0]], digest[idx[1]], digest[idx[2]], 4)
+	}
+	encode24(&sb, 0, 0, digest[63], 2)
+
+	return sb.String()
+}
+
+func writeRepeated(w io.Writer, data []byte, length int) {
+	for ; length > len(data); length -= len(data) {
+		w.Write(data)
+	}
+	w.Write(data[:length])
+}
+
+func repeatToLength(data []byte, length int) []byte {
+	repeated := make([]byte, 0, length)
+	for len(repeated) < length {
+		repeated = append(repeated, data[:min(len(data), length-len(repeated))]...)
+	}
+	return repeated
+}
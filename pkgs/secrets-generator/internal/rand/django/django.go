@@ -0,0 +1,62 @@
+// Package django implements password hashes in the formats used by Django (and thus Paperless).
+package django
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// SaltLength is the number of bytes read from rng for the salt.
+	// The salt is encoded as 22 characters of base64 (Django itself generates 22 alphanumeric characters).
+	SaltLength = 16
+
+	pbkdf2KeyLength = 32
+	scryptKeyLength = 64
+)
+
+// PBKDF2SHA256 creates a hash in the format of Django's PBKDF2PasswordHasher: pbkdf2_sha256$<iterations>$<salt>$<hash>.
+func PBKDF2SHA256(rng io.Reader, password []byte, iterations int) (string, error) {
+	if iterations < 1 {
+		return "", fmt.Errorf("pbkdf2_sha256: iterations must be positive, got %d", iterations)
+	}
+
+	salt, err := randomSalt(rng)
+	if err != nil {
+		return "", err
+	}
+
+	key := pbkdf2.Key(password, []byte(salt), iterations, pbkdf2KeyLength, sha256.New)
+
+	return fmt.Sprintf("pbkdf2_sha256$%d$%s$%s", iterations, salt, base64.StdEncoding.EncodeToString(key)), nil
+}
+
+// Scrypt creates a hash in the format of Django's ScryptPasswordHasher: scrypt$<salt>$<n>$<r>$<p>$<hash>.
+func Scrypt(rng io.Reader, password []byte, n, r, p int) (string, error) {
+	salt, err := randomSalt(rng)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := scrypt.Key(password, []byte(salt), n, r, p, scryptKeyLength)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("scrypt$%s$%d$%d$%d$%s", salt, n, r, p, base64.StdEncoding.EncodeToString(key)), nil
+}
+
+func randomSalt(rng io.Reader) (string, error) {
+	salt := make([]byte, SaltLength)
+	if _, err := io.ReadFull(rng, salt); err != nil {
+		return "", err
+	}
+
+	// Django salts must not contain "$", which isn't part of the base64 alphabet.
+	return base64.RawStdEncoding.EncodeToString(salt), nil
+}
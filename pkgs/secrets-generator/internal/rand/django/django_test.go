@@ -0,0 +1,36 @@
+package django
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Reference hashes were created with Python's hashlib, which Django uses as well.
+
+var testSalt = []byte("0123456789abcdef")
+
+func TestPBKDF2SHA256(t *testing.T) {
+	expected := "pbkdf2_sha256$1000$MDEyMzQ1Njc4OWFiY2RlZg$NoNJ7EtbpNhkOK9z7yifIvInw4znIBmimeeUdFxoxh8="
+
+	hash, err := PBKDF2SHA256(bytes.NewReader(testSalt), []byte("hunter2"), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hash != expected {
+		t.Errorf("hash = %q, want %q", hash, expected)
+	}
+}
+
+func TestScrypt(t *testing.T) {
+	expected := "scrypt$MDEyMzQ1Njc4OWFiY2RlZg$1024$8$1$DqHuT0nbPS8C6qBXR+oWyGipupl7Bn5/2GH4p8jXtkWllfT7pHqxI5ZyvXENyGMi9w67sY4gXXakDjAOR5FibQ=="
+
+	hash, err := Scrypt(bytes.NewReader(testSalt), []byte("hunter2"), 1024, 8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hash != expected {
+		t.Errorf("hash = %q, want %q", hash, expected)
+	}
+}
@@ -1,17 +1,87 @@
 package password
 
 import (
-	"crypto/rand"
+	"errors"
 	"io"
 	"math/big"
+
+	"golang.org/x/crypto/chacha20"
+)
+
+const (
+	// SeedLength is the number of bytes GeneratePassword reads from rng for every password, regardless of length or charset.
+	SeedLength = chacha20.KeySize
+
+	// MaxCharsetLength is the largest charset GeneratePassword can sample from (one byte of keystream per candidate character).
+	MaxCharsetLength = 256
+
+	keystreamBlockSize = 64
+)
+
+var (
+	ErrCharsetEmpty   = errors.New("charset is empty")
+	ErrCharsetTooLong = errors.New("charset is too long")
 )
 
+// GeneratePassword generates a password of the given length with characters drawn uniformly from charset.
+//
+// Exactly SeedLength bytes are read from rng.
+// They are used as the key for a ChaCha20 keystream (with an all-zero nonce) which is consumed one byte at a time.
+// Bytes at or above the largest multiple of len(charset) that fits in a byte are rejected, every other byte b selects charset[b % len(charset)].
+// Less than half of all bytes are rejected, so the keystream (which is practically endless) runs out long before this becomes a problem.
 func GeneratePassword(rng io.Reader, charset string, length int) ([]byte, error) {
+	if err := checkCharset(charset); err != nil {
+		return nil, err
+	}
+
+	seed := make([]byte, SeedLength)
+	if _, err := io.ReadFull(rng, seed); err != nil {
+		return nil, err
+	}
+
+	stream, err := chacha20.NewUnauthenticatedCipher(seed, make([]byte, chacha20.NonceSize))
+	if err != nil {
+		return nil, err
+	}
+
+	bound := MaxCharsetLength - MaxCharsetLength%len(charset)
+
+	password := make([]byte, 0, length)
+	block := make([]byte, keystreamBlockSize)
+
+	for len(password) < length {
+		clear(block)
+		stream.XORKeyStream(block, block)
+
+		for _, b := range block {
+			if int(b) >= bound {
+				continue
+			}
+
+			password = append(password, charset[int(b)%len(charset)])
+			if len(password) == length {
+				break
+			}
+		}
+	}
+
+	return password, nil
+}
+
+// GeneratePasswordLegacy generates a password the way it was done before GeneratePassword was introduced.
+// This is only needed to reproduce passwords from old entropy files.
+//
+// Every character is drawn with a separate call to randInt, which reads a variable amount of bytes from rng.
+func GeneratePasswordLegacy(rng io.Reader, charset string, length int) ([]byte, error) {
+	if err := checkCharset(charset); err != nil {
+		return nil, err
+	}
+
 	max := big.NewInt(int64(len(charset)))
 	password := make([]byte, length)
 
 	for i := 0; i < length; i++ {
-		idx, err := rand.Int(rng, max)
+		idx, err := randInt(rng, max)
 		if err != nil {
 			return nil, err
 		}
@@ -21,3 +91,56 @@ func GeneratePassword(rng io.Reader, charset string, length int) ([]byte, error)
 
 	return password, nil
 }
+
+func checkCharset(charset string) error {
+	if len(charset) == 0 {
+		return ErrCharsetEmpty
+	}
+
+	if len(charset) > MaxCharsetLength {
+		return ErrCharsetTooLong
+	}
+
+	return nil
+}
+
+// randInt is a copy of crypto/rand.Int from Go 1.22.
+// It is kept here so that reproducing legacy passwords doesn't depend on the implementation details of the standard library.
+func randInt(rng io.Reader, max *big.Int) (n *big.Int, err error) {
+	if max.Sign() <= 0 {
+		panic("crypto/rand: argument to Int is <= 0")
+	}
+	n = new(big.Int)
+	n.Sub(max, n.SetUint64(1))
+	// bitLen is the maximum bit length needed to encode a value < max.
+	bitLen := n.BitLen()
+	if bitLen == 0 {
+		// the only valid result is 0
+		return
+	}
+	// k is the maximum byte length needed to encode a value < max.
+	k := (bitLen + 7) / 8
+	// b is the number of bits in the most significant byte of max-1.
+	b := uint(bitLen % 8)
+	if b == 0 {
+		b = 8
+	}
+
+	bytes := make([]byte, k)
+
+	for {
+		_, err = io.ReadFull(rng, bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		// Clear bits in the first byte to increase the probability
+		// that the candidate is < max.
+		bytes[0] &= uint8(int(1<<b) - 1)
+
+		n.SetBytes(bytes)
+		if n.Cmp(max) < 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package password_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tbx.at/secrets-generator/internal/rand/password"
+)
+
+const testCharset = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+type countingReader struct {
+	io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (n int, err error) {
+	n, err = c.Reader.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestGeneratePasswordFixedEntropy(t *testing.T) {
+	for _, length := range []int{0, 1, 32, 64, 1000} {
+		rng := &countingReader{Reader: rand.Reader}
+
+		generated, err := password.GeneratePassword(rng, testCharset, length)
+		require.NoError(t, err)
+
+		assert.Len(t, generated, length)
+		assert.Equal(t, password.SeedLength, rng.n)
+	}
+}
+
+func TestGeneratePasswordDeterministic(t *testing.T) {
+	seed := make([]byte, password.SeedLength)
+	_, err := rand.Read(seed)
+	require.NoError(t, err)
+
+	first, err := password.GeneratePassword(bytes.NewReader(seed), testCharset, 64)
+	require.NoError(t, err)
+
+	second, err := password.GeneratePassword(bytes.NewReader(seed), testCharset, 64)
+	require.NoError(t, err)
+
+	assert.Equal(t, first, second)
+}
+
+func TestGeneratePasswordVector(t *testing.T) {
+	// Pins the algorithm: changing it would regenerate every password on the next run.
+	seed := bytes.Repeat([]byte{0}, password.SeedLength)
+
+	generated, err := password.GeneratePassword(bytes.NewReader(seed), testCharset, 16)
+	require.NoError(t, err)
+
+	assert.Equal(t, "kei3qzza2v8nl0je", string(generated))
+}
+
+func TestGeneratePasswordCharset(t *testing.T) {
+	generated, err := password.GeneratePassword(rand.Reader, "ab", 1000)
+	require.NoError(t, err)
+
+	assert.Empty(t, strings.Trim(string(generated), "ab"))
+	assert.Contains(t, string(generated), "a")
+	assert.Contains(t, string(generated), "b")
+
+	_, err = password.GeneratePassword(rand.Reader, "", 10)
+	assert.ErrorIs(t, err, password.ErrCharsetEmpty)
+
+	_, err = password.GeneratePassword(rand.Reader, strings.Repeat("a", password.MaxCharsetLength+1), 10)
+	assert.ErrorIs(t, err, password.ErrCharsetTooLong)
+}
+
+func TestGeneratePasswordShortEntropy(t *testing.T) {
+	_, err := password.GeneratePassword(bytes.NewReader(make([]byte, password.SeedLength-1)), testCharset, 10)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestGeneratePasswordLegacyMatchesRandInt(t *testing.T) {
+	entropy := make([]byte, 1024)
+	_, err := rand.Read(entropy)
+	require.NoError(t, err)
+
+	generated, err := password.GeneratePasswordLegacy(bytes.NewReader(entropy), testCharset, 64)
+	require.NoError(t, err)
+
+	rng := bytes.NewReader(entropy)
+	max := big.NewInt(int64(len(testCharset)))
+	expected := make([]byte, 64)
+	for i := range expected {
+		idx, err := rand.Int(rng, max)
+		require.NoError(t, err)
+		expected[i] = testCharset[idx.Int64()]
+	}
+
+	assert.Equal(t, expected, generated)
+}
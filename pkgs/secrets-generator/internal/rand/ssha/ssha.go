@@ -0,0 +1,28 @@
+// Package ssha implements salted SHA-1 hashes ({SSHA}) as used by LDAP and Dovecot.
+package ssha
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"io"
+)
+
+// SaltLength is the number of bytes read from rng for the salt.
+const SaltLength = 8
+
+// Hash creates an {SSHA} hash of password: the base64 encoding of SHA-1(password || salt) || salt.
+func Hash(rng io.Reader, password []byte) (string, error) {
+	salt := make([]byte, SaltLength)
+	if _, err := io.ReadFull(rng, salt); err != nil {
+		return "", err
+	}
+
+	h := sha1.New()
+	h.Write(password)
+	h.Write(salt)
+
+	digest := h.Sum(nil)
+	digest = append(digest, salt...)
+
+	return "{SSHA}" + base64.StdEncoding.EncodeToString(digest), nil
+}
@@ -0,0 +1,20 @@
+package ssha
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHash(t *testing.T) {
+	// Reference hash was created with Python's hashlib.
+	expected := "{SSHA}EwvBq0sMQSLLD3eOSZNmhQqOOkRzYWx0c2FsdA=="
+
+	hash, err := Hash(bytes.NewReader([]byte("saltsalt")), []byte("hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hash != expected {
+		t.Errorf("hash = %q, want %q", hash, expected)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2012-2018 Alexander Peslyak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yescrypt
+
+import (
+	"errors"
+	"strings"
+)
+
+const alphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+var errInvalidEncoding = errors.New("yescrypt: invalid encoding")
+
+// encode64 encodes src in groups of up to 3 bytes, least significant bits first.
+func encode64(src []byte) string {
+	var sb strings.Builder
+
+	for i := 0; i < len(src); {
+		var value, bits uint32
+		for bits < 24 && i < len(src) {
+			value |= uint32(src[i]) << bits
+			bits += 8
+			i++
+		}
+
+		for ; bits > 0; bits -= min(bits, 6) {
+			sb.WriteByte(alphabet[value&0x3f])
+			value >>= 6
+		}
+	}
+
+	return sb.String()
+}
+
+// decode64 is the inverse of encode64.
+func decode64(src string) ([]byte, error) {
+	var dst []byte
+
+	for len(src) > 0 {
+		var value, bits uint32
+		for len(src) > 0 && bits < 24 {
+			c := strings.IndexByte(alphabet, src[0])
+			if c < 0 {
+				return nil, errInvalidEncoding
+			}
+
+			src = src[1:]
+			value |= uint32(c) << bits
+			bits += 6
+		}
+
+		if bits < 12 {
+			return nil, errInvalidEncoding
+		}
+
+		for ; bits >= 8; bits -= 8 {
+			dst = append(dst, byte(value))
+			value >>= 8
+		}
+
+		if value != 0 {
+			return nil, errInvalidEncoding
+		}
+	}
+
+	return dst, nil
+}
+
+// encode64Uint32 encodes a parameter value with the variable length encoding used for yescrypt parameters.
+func encode64Uint32(sb *strings.Builder, src, min uint32) {
+	src -= min
+
+	start, end, chars, bits := uint32(0), uint32(47), 1, uint32(0)
+	for {
+		count := (end + 1 - start) << bits
+		if src < count {
+			break
+		}
+
+		start = end + 1
+		end = start + (62-end)/2
+		src -= count
+		chars++
+		bits += 6
+	}
+
+	sb.WriteByte(alphabet[start+(src>>bits)])
+	for chars--; chars > 0; chars-- {
+		bits -= 6
+		sb.WriteByte(alphabet[(src>>bits)&0x3f])
+	}
+}
@@ -0,0 +1,396 @@
+// Copyright 2009 Colin Percival
+// Copyright 2012-2018 Alexander Peslyak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package yescrypt implements yescrypt password hashing ($y$) as used for Linux user passwords.
+//
+// The code is a port of the yescrypt reference implementation, restricted to the default (and only widely deployed) pwxform settings.
+package yescrypt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/bits"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// DefaultNLog2 and DefaultR are the cost parameters used by libxcrypt by default ($y$j9T$).
+	DefaultNLog2 = 12
+	DefaultR     = 32
+
+	// SaltLength is the number of bytes read from rng for the salt.
+	SaltLength = 16
+
+	hashLength = 32
+)
+
+const (
+	flagRW      = 0x002
+	flagPrehash = 0x10000000
+
+	// flagsDefault are the only flags supported by this implementation:
+	// YESCRYPT_RW | YESCRYPT_ROUNDS_6 | YESCRYPT_GATHER_4 | YESCRYPT_SIMPLE_2 | YESCRYPT_SBOX_12K
+	flagsDefault = 0x0b6
+)
+
+const (
+	pwxSimple = 2
+	pwxGather = 4
+	pwxRounds = 6
+	sWidth    = 8
+
+	pwxBytes = pwxGather * pwxSimple * 8
+	pwxWords = pwxBytes / 4
+	sWords1  = (1 << sWidth) * pwxSimple * 2
+	sWords   = 3 * sWords1
+	sBytes   = sWords * 4
+	sMask    = ((1 << sWidth) - 1) * pwxSimple * 8
+)
+
+var ErrInvalidParams = errors.New("yescrypt: invalid parameters")
+
+// Hash creates a yescrypt hash of password with the default cost parameters.
+// SaltLength bytes are read from rng for the salt.
+func Hash(rng io.Reader, password []byte) (string, error) {
+	return HashWithParams(rng, password, DefaultNLog2, DefaultR)
+}
+
+// HashWithParams creates a yescrypt hash of password with N = 2^nLog2 and the block size r.
+func HashWithParams(rng io.Reader, password []byte, nLog2, r int) (string, error) {
+	if nLog2 < 1 || nLog2 > 63 || r < 1 || r > 1<<20 {
+		return "", ErrInvalidParams
+	}
+
+	salt := make([]byte, SaltLength)
+	if _, err := io.ReadFull(rng, salt); err != nil {
+		return "", err
+	}
+
+	var setting strings.Builder
+	setting.WriteString("$y$")
+	encode64Uint32(&setting, flagRW+(flagsDefault-flagRW)>>2, 0)
+	encode64Uint32(&setting, uint32(nLog2), 1)
+	encode64Uint32(&setting, uint32(r), 1)
+	setting.WriteByte('$')
+	setting.WriteString(encode64(salt))
+
+	hash, err := kdf(password, salt, uint64(1)<<nLog2, uint32(r))
+	if err != nil {
+		return "", err
+	}
+
+	return setting.String() + "$" + encode64(hash), nil
+}
+
+func kdf(password, salt []byte, n uint64, r uint32) ([]byte, error) {
+	if n/128 > uint64(^uint32(0))/uint64(r) {
+		return nil, ErrInvalidParams
+	}
+
+	if n >= 0x100 && n*uint64(r) >= 0x20000 {
+		password = kdfBody(password, salt, flagsDefault|flagPrehash, n>>6, r)
+	}
+
+	return kdfBody(password, salt, flagsDefault, n, r), nil
+}
+
+func kdfBody(password, salt []byte, flags uint32, n uint64, r uint32) []byte {
+	key := []byte("yescrypt-prehash")
+	if flags&flagPrehash == 0 {
+		key = key[:8]
+	}
+
+	passwordHash := hmacSHA256(key, password)
+
+	b := pbkdf2.Key(passwordHash, salt, 1, int(128*r), sha256.New)
+	copy(passwordHash, b[:32])
+
+	smix(b, r, uint32(n), passwordHash)
+
+	dk := pbkdf2.Key(passwordHash, b, 1, hashLength, sha256.New)
+
+	if flags&flagPrehash == 0 {
+		clientKey := hmacSHA256(dk, []byte("Client Key"))
+		storedKey := sha256.Sum256(clientKey)
+		dk = storedKey[:]
+	}
+
+	return dk
+}
+
+type pwxformCtx struct {
+	s0, s1, s2 []uint32
+	w          uint32
+}
+
+func smix(b []byte, r, n uint32, passwordHash []byte) {
+	s := 32 * r
+
+	// A third of N rounded up (and then to an even number) as yescrypt does for t = 0.
+	nLoop := (uint64(n) + 2) / 3
+	nLoop = (nLoop + 1) &^ 1
+
+	v := make([]uint32, uint64(s)*uint64(n))
+	xy := make([]uint32, 2*s)
+	sbox := make([]uint32, sWords)
+
+	smix1(b[:128], 1, sBytes/128, 0, sbox, xy, nil)
+
+	ctx := &pwxformCtx{
+		s2: sbox[:sWords1],
+		s1: sbox[sWords1 : 2*sWords1],
+		s0: sbox[2*sWords1:],
+	}
+
+	copy(passwordHash, hmacSHA256(b[128*r-64:128*r], passwordHash))
+
+	smix1(b, r, n, flagRW, v, xy, ctx)
+	smix2(b, r, p2floor(n), nLoop, flagRW, v, xy, ctx)
+}
+
+func smix1(b []byte, r, n, flags uint32, v, xy []uint32, ctx *pwxformCtx) {
+	s := 32 * r
+	x := xy[:s]
+
+	load(x, b, r)
+
+	for i := uint32(0); i < n; i++ {
+		copy(v[i*s:(i+1)*s], x)
+
+		if flags&flagRW != 0 && i > 1 {
+			j := wrap(integerify(x, r), i)
+			xor(x, v[j*s:(j+1)*s])
+		}
+
+		blockmix(x, xy[s:], r, ctx)
+	}
+
+	store(b, x, r)
+}
+
+func smix2(b []byte, r, n uint32, nLoop uint64, flags uint32, v, xy []uint32, ctx *pwxformCtx) {
+	s := 32 * r
+	x := xy[:s]
+
+	load(x, b, r)
+
+	for i := uint64(0); i < nLoop; i++ {
+		j := uint32(integerify(x, r) & uint64(n-1))
+		xor(x, v[j*s:(j+1)*s])
+
+		if flags&flagRW != 0 {
+			copy(v[j*s:(j+1)*s], x)
+		}
+
+		blockmix(x, xy[s:], r, ctx)
+	}
+
+	store(b, x, r)
+}
+
+// load decodes b into x, applying the SIMD shuffle used by all implementations of yescrypt.
+func load(x []uint32, b []byte, r uint32) {
+	for k := uint32(0); k < 2*r; k++ {
+		for i := uint32(0); i < 16; i++ {
+			x[k*16+i] = binary.LittleEndian.Uint32(b[(k*16+(i*5%16))*4:])
+		}
+	}
+}
+
+// store is the inverse of load.
+func store(b []byte, x []uint32, r uint32) {
+	for k := uint32(0); k < 2*r; k++ {
+		for i := uint32(0); i < 16; i++ {
+			binary.LittleEndian.PutUint32(b[(k*16+(i*5%16))*4:], x[k*16+i])
+		}
+	}
+}
+
+func blockmix(b, y []uint32, r uint32, ctx *pwxformCtx) {
+	if ctx == nil {
+		blockmixSalsa8(b, y, r)
+	} else {
+		blockmixPwxform(b, r, ctx)
+	}
+}
+
+func blockmixSalsa8(b, y []uint32, r uint32) {
+	var x [16]uint32
+	copy(x[:], b[(2*r-1)*16:])
+
+	for i := uint32(0); i < 2*r; i++ {
+		xor(x[:], b[i*16:(i+1)*16])
+		salsa20(&x, 8)
+		copy(y[i*16:], x[:])
+	}
+
+	for i := uint32(0); i < r; i++ {
+		copy(b[i*16:(i+1)*16], y[(i*2)*16:])
+	}
+	for i := uint32(0); i < r; i++ {
+		copy(b[(i+r)*16:(i+r+1)*16], y[(i*2+1)*16:])
+	}
+}
+
+func blockmixPwxform(b []uint32, r uint32, ctx *pwxformCtx) {
+	r1 := 128 * r / pwxBytes
+
+	var x [pwxWords]uint32
+	copy(x[:], b[(r1-1)*pwxWords:])
+
+	for i := uint32(0); i < r1; i++ {
+		if r1 > 1 {
+			xor(x[:], b[i*pwxWords:(i+1)*pwxWords])
+		}
+
+		pwxform(&x, ctx)
+
+		copy(b[i*pwxWords:], x[:])
+	}
+
+	i := (r1 - 1) * pwxBytes / 64
+
+	var block [16]uint32
+	copy(block[:], b[i*16:])
+	salsa20(&block, 2)
+	copy(b[i*16:], block[:])
+
+	for i++; i < 2*r; i++ {
+		xor(b[i*16:(i+1)*16], b[(i-1)*16:i*16])
+		copy(block[:], b[i*16:])
+		salsa20(&block, 2)
+		copy(b[i*16:], block[:])
+	}
+}
+
+func pwxform(x *[pwxWords]uint32, ctx *pwxformCtx) {
+	s0, s1, s2 := ctx.s0, ctx.s1, ctx.s2
+	w := ctx.w
+
+	for i := 0; i < pwxRounds; i++ {
+		for j := 0; j < pwxGather; j++ {
+			base := j * pwxSimple * 2
+
+			p0 := (x[base] & sMask) / 4
+			p1 := (x[base+1] & sMask) / 4
+
+			for k := 0; k < pwxSimple; k++ {
+				s0k := uint64(s0[p0+uint32(k)*2+1])<<32 | uint64(s0[p0+uint32(k)*2])
+				s1k := uint64(s1[p1+uint32(k)*2+1])<<32 | uint64(s1[p1+uint32(k)*2])
+
+				xl := x[base+k*2]
+				xh := x[base+k*2+1]
+
+				v := uint64(xh) * uint64(xl)
+				v += s0k
+				v ^= s1k
+
+				x[base+k*2] = uint32(v)
+				x[base+k*2+1] = uint32(v >> 32)
+
+				if i != 0 && i != pwxRounds-1 {
+					s2[w*2] = uint32(v)
+					s2[w*2+1] = uint32(v >> 32)
+					w++
+				}
+			}
+		}
+	}
+
+	ctx.s0, ctx.s1, ctx.s2 = s2, s0, s1
+	ctx.w = w & ((1<<sWidth)*pwxSimple - 1)
+}
+
+// salsa20 applies the Salsa20 core to a block in the SIMD shuffled layout.
+func salsa20(b *[16]uint32, rounds int) {
+	var x [16]uint32
+	for i := range x {
+		x[i*5%16] = b[i]
+	}
+
+	for i := 0; i < rounds; i += 2 {
+		// Operate on columns.
+		x[4] ^= bits.RotateLeft32(x[0]+x[12], 7)
+		x[8] ^= bits.RotateLeft32(x[4]+x[0], 9)
+		x[12] ^= bits.RotateLeft32(x[8]+x[4], 13)
+		x[0] ^= bits.RotateLeft32(x[12]+x[8], 18)
+
+		x[9] ^= bits.RotateLeft32(x[5]+x[1], 7)
+		x[13] ^= bits.RotateLeft32(x[9]+x[5], 9)
+		x[1] ^= bits.RotateLeft32(x[13]+x[9], 13)
+		x[5] ^= bits.RotateLeft32(x[1]+x[13], 18)
+
+		x[14] ^= bits.RotateLeft32(x[10]+x[6], 7)
+		x[2] ^= bits.RotateLeft32(x[14]+x[10], 9)
+		x[6] ^= bits.RotateLeft32(x[2]+x[14], 13)
+		x[10] ^= bits.RotateLeft32(x[6]+x[2], 18)
+
+		x[3] ^= bits.RotateLeft32(x[15]+x[11], 7)
+		x[7] ^= bits.RotateLeft32(x[3]+x[15], 9)
+		x[11] ^= bits.RotateLeft32(x[7]+x[3], 13)
+		x[15] ^= bits.RotateLeft32(x[11]+x[7], 18)
+
+		// Operate on rows.
+		x[1] ^= bits.RotateLeft32(x[0]+x[3], 7)
+		x[2] ^= bits.RotateLeft32(x[1]+x[0], 9)
+		x[3] ^= bits.RotateLeft32(x[2]+x[1], 13)
+		x[0] ^= bits.RotateLeft32(x[3]+x[2], 18)
+
+		x[6] ^= bits.RotateLeft32(x[5]+x[4], 7)
+		x[7] ^= bits.RotateLeft32(x[6]+x[5], 9)
+		x[4] ^= bits.RotateLeft32(x[7]+x[6], 13)
+		x[5] ^= bits.RotateLeft32(x[4]+x[7], 18)
+
+		x[11] ^= bits.RotateLeft32(x[10]+x[9], 7)
+		x[8] ^= bits.RotateLeft32(x[11]+x[10], 9)
+		x[9] ^= bits.RotateLeft32(x[8]+x[11], 13)
+		x[10] ^= bits.RotateLeft32(x[9]+x[8], 18)
+
+		x[12] ^= bits.RotateLeft32(x[15]+x[14], 7)
+		x[13] ^= bits.RotateLeft32(x[12]+x[15], 9)
+		x[14] ^= bits.RotateLeft32(x[13]+x[12], 13)
+		x[15] ^= bits.RotateLeft32(x[14]+x[13], 18)
+	}
+
+	for i := range b {
+		b[i] += x[i*5%16]
+	}
+}
+
+// integerify returns the first 64 bits of the last 64 byte block.
+// Word 13 is the second word of that block due to the SIMD shuffle.
+func integerify(b []uint32, r uint32) uint64 {
+	x := b[(2*r-1)*16:]
+	return uint64(x[13])<<32 | uint64(x[0])
+}
+
+func wrap(x uint64, i uint32) uint32 {
+	n := p2floor(i)
+	return uint32(x&uint64(n-1)) + (i - n)
+}
+
+func p2floor(x uint32) uint32 {
+	for y := x & (x - 1); y != 0; y = x & (x - 1) {
+		x = y
+	}
+	return x
+}
+
+func xor(dst, src []uint32) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
+
+func hmacSHA256(key, message []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(message)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,74 @@
+package yescrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Reference hashes were created with libxcrypt.
+var testVectors = []struct {
+	password string
+	nLog2, r int
+	hash     string
+}{
+	{"pleaseletmein", 12, 32, "$y$j9T$LdJMENpBABJJ3hIHjB1Bi.$iofk68xbXBoXKsxTyMBCh2qkQuzQZ2Zik521F9TsTq6"},
+	{"", 12, 32, "$y$j9T$k2XAnEHBqQ1Ct2aMXFKNa/$EK2xW1oGRTS8QhuBaGBu09AtA6psATyEI2R7c0yZ3W2"},
+	{strings.Repeat("x", 100), 12, 32, "$y$j9T$n34PoBLMgFrQVl4Rn34Po/$2.QmMDn25QARgBv86uaV66q2PV9TpNZ/YzaHUtYw1B6"},
+	{"hunter2", 11, 32, "$y$j8T$k2XAnEHBqQ1Ct2aMXFKNa/$HC.RwwFxk7aLPONERmnrWH7jOHgyxA2d1gLTchPHH1/"},
+	{"hunter2", 13, 8, "$y$jA5$n34PoBLMgFrQVl4Rn34Po/$rPWxP1awAxWfF3v3CGRVtJFAdlLNLce1hItUHyb/l9."},
+	{"hunter2", 10, 8, "$y$j75$k2XAnEHBqQ1Ct2aMXFKNa/$SjI/nn7cLBIIEIzpTLJnFuFeIqmDYGztH7XEGm4fIk8"},
+}
+
+func TestHashWithParams(t *testing.T) {
+	for _, vector := range testVectors {
+		fields := strings.Split(vector.hash, "$")
+
+		salt, err := decode64(fields[3])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		hash, err := HashWithParams(bytes.NewReader(salt), []byte(vector.password), vector.nLog2, vector.r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if hash != vector.hash {
+			t.Errorf("hash for %q = %q, want %q", vector.password, hash, vector.hash)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	hashRX := regexp.MustCompile(`^\$y\$j9T\$[./0-9A-Za-z]{22}\$[./0-9A-Za-z]{43}$`)
+
+	hash, err := Hash(rand.Reader, []byte("pa$$word"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !hashRX.MatchString(hash) {
+		t.Errorf("hash %q not in correct format", hash)
+	}
+}
+
+func TestBase64RoundTrip(t *testing.T) {
+	for n := 0; n < 20; n++ {
+		src := make([]byte, n)
+		if _, err := rand.Read(src); err != nil {
+			t.Fatal(err)
+		}
+
+		decoded, err := decode64(encode64(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(src, decoded) {
+			t.Errorf("decode64(encode64(%x)) = %x", src, decoded)
+		}
+	}
+}
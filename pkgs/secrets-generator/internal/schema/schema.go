@@ -0,0 +1,201 @@
+// Package schema describes the configuration as a JSON Schema.
+// The schema is derived from the Go types of the configuration, so it always matches what internal.DecodeConfig accepts.
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"tbx.at/secrets-generator/internal"
+	"tbx.at/secrets-generator/internal/generator/format"
+	"tbx.at/secrets-generator/internal/generator/functions"
+	"tbx.at/secrets-generator/internal/generator/random"
+)
+
+// Schema is a JSON Schema document.
+type Schema = map[string]any
+
+// Draft is the JSON Schema version of the returned schema.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// fieldKey identifies a field of a configuration type.
+type fieldKey struct {
+	Type  reflect.Type
+	Field string
+}
+
+// fieldSchemas replaces the schemas derived from the Go types of some fields with more precise ones.
+var fieldSchemas = map[fieldKey]func() Schema{
+	{reflect.TypeFor[internal.GenerationParamsJSON](), "Content"}:       contentRef,
+	{reflect.TypeFor[internal.GenerationParamsJSON](), "Format"}:        formatSchema,
+	{reflect.TypeFor[internal.GenerationParamsJSONOutput](), "Content"}: contentRef,
+	{reflect.TypeFor[internal.GenerationParamsJSONOutput](), "Format"}:  formatSchema,
+	{reflect.TypeFor[internal.GenerationParamsRandom](), "Charsets"}:    charsetsSchema,
+}
+
+// Config returns the JSON Schema for internal.Config.
+// Every struct type gets its own definition named after the type.
+// JSON content is described by the "content" definition, which includes function calls.
+func Config() Schema {
+	b := builder{defs: make(map[string]Schema)}
+
+	root := b.schemaFor(reflect.TypeFor[internal.Config]())
+	b.defs["content"] = contentSchema()
+	b.defs["functionCall"] = functionCallSchema()
+
+	schema := Schema{
+		"$schema": Draft,
+		"$defs":   b.defs,
+	}
+	for key, value := range root {
+		schema[key] = value
+	}
+
+	return schema
+}
+
+type builder struct {
+	defs map[string]Schema
+}
+
+func (b *builder) schemaFor(t reflect.Type) Schema {
+	switch t.Kind() {
+	case reflect.Pointer:
+		// Unset generation methods and options are null in configurations generated by Nix.
+		return Schema{"anyOf": []any{Schema{"type": "null"}, b.schemaFor(t.Elem())}}
+
+	case reflect.Struct:
+		name := t.Name()
+		if _, ok := b.defs[name]; !ok {
+			// Reserve the name first in case the type refers to itself.
+			b.defs[name] = nil
+			b.defs[name] = b.structSchema(t)
+		}
+
+		return ref(name)
+
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": b.schemaFor(t.Elem())}
+
+	case reflect.Slice:
+		return Schema{"type": "array", "items": b.schemaFor(t.Elem())}
+
+	case reflect.String:
+		return Schema{"type": "string"}
+
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+
+	case reflect.Int, reflect.Int64:
+		return Schema{"type": "integer"}
+
+	case reflect.Interface:
+		return Schema{}
+
+	default:
+		panic(fmt.Sprintf("no schema for configuration type %s", t))
+	}
+}
+
+func (b *builder) structSchema(t reflect.Type) Schema {
+	properties := make(map[string]Schema)
+
+	for name, field := range internal.JSONFields(t) {
+		if override, ok := fieldSchemas[fieldKey{t, field.Name}]; ok {
+			properties[name] = override()
+		} else if strings.Contains(field.Tag.Get("json"), ",omitempty") && field.Type.Kind() != reflect.Pointer {
+			// Optional fields are null in configurations generated by Nix when they are unset.
+			properties[name] = Schema{"anyOf": []any{Schema{"type": "null"}, b.schemaFor(field.Type)}}
+		} else {
+			properties[name] = b.schemaFor(field.Type)
+		}
+	}
+
+	return Schema{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+func ref(name string) Schema {
+	return Schema{"$ref": "#/$defs/" + name}
+}
+
+func contentRef() Schema {
+	return ref("content")
+}
+
+// contentSchema describes the content of JSON secrets: any JSON value where objects can be function calls.
+func contentSchema() Schema {
+	return Schema{
+		"anyOf": []any{
+			ref("functionCall"),
+			Schema{
+				"type":                 "object",
+				"not":                  Schema{"required": []any{"__secretsGeneratorType"}},
+				"additionalProperties": contentRef(),
+			},
+			Schema{"type": "array", "items": contentRef()},
+			Schema{"type": []any{"string", "number", "boolean", "null"}},
+		},
+	}
+}
+
+// functionCallSchema describes a function call with one alternative per function.
+func functionCallSchema() Schema {
+	var calls []any
+
+	for _, function := range functions.All() {
+		properties := make(map[string]Schema)
+		required := []any{}
+
+		for _, param := range function.Params {
+			properties[param.Name] = contentRef()
+			if !param.Optional {
+				required = append(required, param.Name)
+			}
+		}
+
+		calls = append(calls, Schema{
+			"type": "object",
+			"properties": Schema{
+				"__secretsGeneratorType": Schema{"const": "functionCall"},
+				"name":                   Schema{"const": function.Name},
+				"arguments": Schema{
+					"type":                 "object",
+					"properties":           properties,
+					"required":             required,
+					"additionalProperties": false,
+				},
+			},
+			"required":             []any{"__secretsGeneratorType", "name", "arguments"},
+			"additionalProperties": false,
+		})
+	}
+
+	return Schema{"oneOf": calls}
+}
+
+func formatSchema() Schema {
+	enum := []any{nil}
+	for _, name := range format.Formats {
+		enum = append(enum, name)
+	}
+
+	return Schema{"enum": enum}
+}
+
+func charsetsSchema() Schema {
+	properties := make(map[string]Schema)
+	for name := range random.SupportedCharsets {
+		properties[name] = Schema{"type": "boolean"}
+	}
+
+	return Schema{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
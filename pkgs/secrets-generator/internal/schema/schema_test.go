@@ -0,0 +1,211 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tbx.at/secrets-generator/internal"
+	"tbx.at/secrets-generator/internal/schema"
+)
+
+const validConfig = `{
+	"publicKeys": {"host": ["age1..."]},
+	"secrets": {
+		"password": {"generation": {"json": null, "random": {"charsets": {"numbers": true, "special": false}, "length": 8}, "script": null, "template": null}},
+		"hash": {"generation": {"json": {
+			"content": {"users": [{"password": {
+				"__secretsGeneratorType": "functionCall",
+				"name": "hashBcrypt",
+				"arguments": {
+					"data": {"__secretsGeneratorType": "functionCall", "name": "readSecret", "arguments": {"name": "password"}},
+					"rounds": 10
+				}
+			}}]},
+			"format": "yaml"
+		}}},
+		"bundle": {"generation": {"json": {"content": null, "format": null, "outputs": {"env": {"content": {"A": "b"}, "format": "dotenv"}}}}},
+		"config": {"generation": {"template": {"content": "{{ .A }}", "data": {"A": [1, {"b": null}]}, "strict": null}}},
+		"script": {"generation": {"script": {"program": "/bin/generate", "runtimeInputs": ["/nix/store/coreutils"], "script": "echo"}}},
+		"imported": {"generation": {}},
+		"vendor": {"generation": {"import": {"file": null, "env": null, "pass": "vendor/api-key", "passCommand": null, "command": null}}}
+	},
+	"secretMounts": {
+		"password": {"host": "host", "secret": "password", "owner": "root", "group": "root", "mode": "u=r,go=", "path": "/run/agenix/password", "secretsDir": "/run/agenix"}
+	}
+}`
+
+func TestSchemaAcceptsValidConfig(t *testing.T) {
+	v := newValidator(t)
+	assert.True(t, v.valid(v.root, decode(t, validConfig)))
+
+	// The schema and the decoder must agree.
+	_, err := internal.DecodeConfig(strings.NewReader(validConfig))
+	assert.NoError(t, err)
+}
+
+func TestSchemaRejectsInvalidConfig(t *testing.T) {
+	v := newValidator(t)
+
+	for _, replacement := range [][2]string{
+		{`"length": 8`, `"lenght": 8`},
+		{`"special": false`, `"emoji": false`},
+		{`"format": "yaml"`, `"format": "xml"`},
+		{`"name": "hashBcrypt"`, `"name": "doesNotExist"`},
+		{`"rounds": 10`, `"cost": 10`},
+		{`"arguments": {"name": "password"}`, `"arguments": {}`},
+		{`"owner": "root"`, `"owner": 0`},
+		{`"strict": null`, `"strict": "yes"`},
+		{`"passCommand": null`, `"passCommand": ["gopass"]`},
+	} {
+		config := strings.Replace(validConfig, replacement[0], replacement[1], 1)
+		require.NotEqual(t, validConfig, config)
+		assert.False(t, v.valid(v.root, decode(t, config)), "%s", replacement[1])
+	}
+}
+
+func decode(t *testing.T, data string) any {
+	var value any
+	require.NoError(t, json.Unmarshal([]byte(data), &value))
+	return value
+}
+
+// validator implements the parts of JSON Schema that are used by schema.Config.
+type validator struct {
+	t    *testing.T
+	root map[string]any
+	defs map[string]any
+}
+
+func newValidator(t *testing.T) *validator {
+	// Round trip through JSON so the schema is checked in the form it is printed in.
+	encoded, err := json.Marshal(schema.Config())
+	require.NoError(t, err)
+
+	root := decode(t, string(encoded)).(map[string]any)
+	assert.Equal(t, schema.Draft, root["$schema"])
+
+	return &validator{t: t, root: root, defs: root["$defs"].(map[string]any)}
+}
+
+func (v *validator) valid(s map[string]any, value any) bool {
+	for keyword, arg := range s {
+		switch keyword {
+		case "$schema", "$defs":
+		case "$ref":
+			name, ok := strings.CutPrefix(arg.(string), "#/$defs/")
+			require.True(v.t, ok)
+			if !v.valid(v.defs[name].(map[string]any), value) {
+				return false
+			}
+		case "anyOf", "oneOf":
+			matches := 0
+			for _, sub := range arg.([]any) {
+				if v.valid(sub.(map[string]any), value) {
+					matches++
+				}
+			}
+			if matches == 0 || (keyword == "oneOf" && matches > 1) {
+				return false
+			}
+		case "not":
+			if v.valid(arg.(map[string]any), value) {
+				return false
+			}
+		case "type":
+			types, ok := arg.([]any)
+			if !ok {
+				types = []any{arg}
+			}
+			matched := false
+			for _, typ := range types {
+				matched = matched || typeOf(value) == typ || (typ == "number" && typeOf(value) == "integer")
+			}
+			if !matched {
+				return false
+			}
+		case "const":
+			if value != arg {
+				return false
+			}
+		case "enum":
+			matched := false
+			for _, option := range arg.([]any) {
+				matched = matched || value == option
+			}
+			if !matched {
+				return false
+			}
+		case "required":
+			object, ok := value.(map[string]any)
+			for _, name := range arg.([]any) {
+				if _, present := object[name.(string)]; ok && !present {
+					return false
+				}
+			}
+		case "properties", "additionalProperties":
+			object, ok := value.(map[string]any)
+			if !ok {
+				continue
+			}
+			properties, _ := s["properties"].(map[string]any)
+			for name, item := range object {
+				sub, ok := properties[name]
+				if keyword == "properties" {
+					if ok && !v.valid(sub.(map[string]any), item) {
+						return false
+					}
+					continue
+				}
+				if ok {
+					continue
+				}
+				switch additional := s["additionalProperties"].(type) {
+				case bool:
+					if !additional {
+						return false
+					}
+				case map[string]any:
+					if !v.valid(additional, item) {
+						return false
+					}
+				}
+			}
+		case "items":
+			list, ok := value.([]any)
+			for _, item := range list {
+				if ok && !v.valid(arg.(map[string]any), item) {
+					return false
+				}
+			}
+		default:
+			v.t.Fatalf("unsupported keyword %s", keyword)
+		}
+	}
+
+	return true
+}
+
+func typeOf(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if value == float64(int64(value)) {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return "unknown"
+	}
+}
@@ -1,61 +1,269 @@
 package internal
 
 import (
-	"io"
-	"os"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
 	"sync"
 
 	"filippo.io/age"
+	"tbx.at/secrets-generator/internal/storage"
 )
 
+var ErrLockMemoryUnsupported = errors.New("locking memory is not supported on this system")
+
+// SecretStore caches decrypted secrets for generators that read other secrets.
+// Secrets are loaded concurrently, and a secret requested by several readers at once is only decrypted once.
 type SecretStore struct {
-	data  map[string][]byte
+	entries map[string]*secretEntry
+
+	// readers counts the readers that may still load each secret, see Retain.
+	readers map[string]int
+
 	mutex sync.Mutex
 
+	storage    storage.Storage
+	encoding   SecretEncoding
 	identities []age.Identity
+	options    SecretStoreOptions
+}
+
+// SecretStoreOptions control how a SecretStore holds decrypted secrets.
+type SecretStoreOptions struct {
+	// Keep keeps secrets after their last reader released them instead of wiping them, so that later runs can reuse them.
+	Keep bool
+
+	// LockMemory locks decrypted secrets into memory, so that they are never written to swap.
+	LockMemory bool
+}
+
+// secretEntry is a decrypted secret along with the hash of the file it came from.
+// The hash is the zero value for secrets stored with StoreSecret.
+type secretEntry struct {
+	// ready is closed once loading the secret finished.
+	// The other fields must not be accessed before that.
+	ready chan struct{}
+
+	data   []byte
+	err    error
+	digest [sha256.Size]byte
+
+	// locked is set if data was allocated by lockMemory.
+	locked bool
 }
 
-func (c *SecretStore) LoadSecret(name string) ([]byte, error) {
+// loaded reports whether the secret was loaded successfully.
+func (e *secretEntry) loaded() bool {
+	select {
+	case <-e.ready:
+		return e.err == nil
+	default:
+		return false
+	}
+}
+
+// wipe overwrites the decrypted secret.
+func (e *secretEntry) wipe() {
+	if e.locked {
+		unlockMemory(e.data)
+	} else {
+		clear(e.data)
+	}
+
+	e.data = nil
+}
+
+// LoadSecret returns the decrypted secret called name, reading it from storage if it isn't cached.
+// The returned slice is owned by the store and must not be used after the caller released the secret.
+func (c *SecretStore) LoadSecret(ctx context.Context, name string) ([]byte, error) {
 	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	entry, found := c.entries[name]
+	if !found {
+		entry = &secretEntry{ready: make(chan struct{})}
+		c.entries[name] = entry
+	}
+	c.mutex.Unlock()
 
-	data, found := c.data[name]
-	if found {
-		return data, nil
+	if !found {
+		c.load(ctx, name, entry)
 	}
 
-	f, err := os.Open(SecretFilePath(name))
-	if err != nil {
-		return nil, err
+	select {
+	case <-entry.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	return entry.data, entry.err
+}
+
+// load reads and decrypts the secret called name into entry without holding the mutex, so that several secrets can be decrypted at once.
+func (c *SecretStore) load(ctx context.Context, name string, entry *secretEntry) {
+	defer close(entry.ready)
+
+	encrypted, err := c.storage.Read(ctx, EncodedSecretKey(c.encoding, name))
+	if err == nil {
+		var data []byte
+		data, err = c.encoding.Decrypt(encrypted, c.identities)
+		if err == nil {
+			entry.data, entry.locked, err = c.hold(data)
+			entry.digest = sha256.Sum256(encrypted)
+		}
 	}
-	defer f.Close()
 
-	reader, err := age.Decrypt(f, c.identities...)
 	if err != nil {
-		return nil, err
+		entry.err = err
+
+		// Failures aren't cached, so that the next reader tries again.
+		c.mutex.Lock()
+		if c.entries[name] == entry {
+			delete(c.entries, name)
+		}
+		c.mutex.Unlock()
+	}
+}
+
+// hold returns the buffer the store keeps data in.
+// If memory is locked, data is copied into locked memory and wiped.
+func (c *SecretStore) hold(data []byte) ([]byte, bool, error) {
+	if !c.options.LockMemory {
+		return data, false, nil
 	}
 
-	data, err = io.ReadAll(reader)
+	locked, err := lockMemory(len(data))
 	if err != nil {
-		return nil, err
+		return nil, false, fmt.Errorf("locking memory for secret failed: %w", err)
 	}
 
-	c.data[name] = data
+	copy(locked, data)
+	clear(data)
 
-	return data, nil
+	return locked, true, nil
 }
 
+// StoreSecret stores data as the secret called name without writing it to storage.
+// The store takes ownership of data, which isn't locked into memory.
 func (c *SecretStore) StoreSecret(name string, data []byte) {
+	entry := &secretEntry{ready: make(chan struct{}), data: data}
+	close(entry.ready)
+
+	c.replace(name, entry)
+}
+
+// WriteSecret encrypts data for recipients, writes it to the file of the secret and stores it.
+// The store takes ownership of data.
+func (c *SecretStore) WriteSecret(ctx context.Context, name string, recipients []age.Recipient, data []byte) error {
+	encrypted, err := c.encoding.Encrypt(data, recipients)
+	if err != nil {
+		return err
+	}
+
+	if err := c.storage.Write(ctx, EncodedSecretKey(c.encoding, name), encrypted); err != nil {
+		return err
+	}
+
+	entry := &secretEntry{ready: make(chan struct{}), digest: sha256.Sum256(encrypted)}
+	entry.data, entry.locked, err = c.hold(data)
+	if err != nil {
+		return err
+	}
+	close(entry.ready)
+
+	c.replace(name, entry)
+
+	return nil
+}
+
+// replace stores entry as the secret called name and wipes the previous version.
+func (c *SecretStore) replace(name string, entry *secretEntry) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	c.data[name] = data
+	if previous, found := c.entries[name]; found && previous.loaded() {
+		previous.wipe()
+	}
+
+	c.entries[name] = entry
+}
+
+// Retain records that count more readers may load the secret called name.
+// Once all of them called Release, the secret is wiped, unless the store keeps secrets.
+// Secrets that are never retained stay in the store until Wipe is called.
+func (c *SecretStore) Retain(name string, count int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.readers[name] += count
+}
+
+// Release records that a reader retained with Retain won't load the secret called name anymore.
+func (c *SecretStore) Release(name string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.readers[name]--
+	if c.readers[name] > 0 {
+		return
+	}
+
+	delete(c.readers, name)
+
+	if entry, found := c.entries[name]; found && entry.loaded() && !c.options.Keep {
+		entry.wipe()
+		delete(c.entries, name)
+	}
+}
+
+// Wipe wipes all secrets in the store.
+// The store can still be used afterwards and loads secrets again as needed.
+func (c *SecretStore) Wipe() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for name, entry := range c.entries {
+		if entry.loaded() {
+			entry.wipe()
+			delete(c.entries, name)
+		}
+	}
+}
+
+// Refresh drops the secrets whose files changed since they were stored, so that they are loaded again when they are needed.
+// This keeps a store that is reused for several runs correct when other programs change the secrets in between.
+// Secrets stored with StoreSecret are always dropped since there is no file to compare them to.
+func (c *SecretStore) Refresh(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for name, entry := range c.entries {
+		if !entry.loaded() {
+			continue
+		}
+
+		encrypted, err := c.storage.Read(ctx, EncodedSecretKey(c.encoding, name))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		if err != nil || sha256.Sum256(encrypted) != entry.digest {
+			entry.wipe()
+			delete(c.entries, name)
+		}
+	}
+
+	return nil
 }
 
-func NewSecretStore(identities []age.Identity) *SecretStore {
+func NewSecretStore(storage storage.Storage, encoding SecretEncoding, identities []age.Identity, options SecretStoreOptions) *SecretStore {
 	return &SecretStore{
-		data: make(map[string][]byte),
+		entries: make(map[string]*secretEntry),
+		readers: make(map[string]int),
 
+		storage:    storage,
+		encoding:   encoding,
 		identities: identities,
+		options:    options,
 	}
 }
@@ -0,0 +1,199 @@
+package internal_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tbx.at/secrets-generator/internal"
+	"tbx.at/secrets-generator/internal/storage"
+)
+
+func TestSecretStoreRefresh(t *testing.T) {
+	ctx := context.Background()
+
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	recipients := []age.Recipient{identity.Recipient()}
+
+	local := &storage.Local{Root: t.TempDir()}
+	secretStore := internal.NewSecretStore(local, internal.AgeEncoding{}, []age.Identity{identity}, internal.SecretStoreOptions{})
+
+	require.NoError(t, secretStore.WriteSecret(ctx, "a", recipients, []byte("a")))
+	require.NoError(t, secretStore.WriteSecret(ctx, "b", recipients, []byte("b")))
+	secretStore.StoreSecret("c", []byte("c"))
+
+	// Another store stands in for another program changing a secret.
+	other := internal.NewSecretStore(local, internal.AgeEncoding{}, []age.Identity{identity}, internal.SecretStoreOptions{})
+	require.NoError(t, other.WriteSecret(ctx, "b", recipients, []byte("changed")))
+
+	require.NoError(t, secretStore.Refresh(ctx))
+
+	data, err := secretStore.LoadSecret(ctx, "b")
+	require.NoError(t, err)
+	assert.Equal(t, "changed", string(data))
+
+	_, err = secretStore.LoadSecret(ctx, "c")
+	assert.ErrorIs(t, err, fs.ErrNotExist, "secrets without a file are dropped")
+
+	// Unchanged secrets stay cached, even if they can't be read anymore.
+	require.NoError(t, os.Remove(filepath.Join(local.Root, filepath.FromSlash(internal.SecretKey("a")))))
+
+	data, err = secretStore.LoadSecret(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "a", string(data))
+
+	require.NoError(t, secretStore.Refresh(ctx))
+
+	_, err = secretStore.LoadSecret(ctx, "a")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+// countingStorage counts the reads of every file and blocks them until release is closed.
+type countingStorage struct {
+	storage.Storage
+	reads   sync.Map
+	release chan struct{}
+}
+
+func (s *countingStorage) Read(ctx context.Context, key string) ([]byte, error) {
+	<-s.release
+
+	count, _ := s.reads.LoadOrStore(key, new(atomic.Int32))
+	count.(*atomic.Int32).Add(1)
+
+	return s.Storage.Read(ctx, key)
+}
+
+func (s *countingStorage) count(key string) int {
+	count, ok := s.reads.Load(key)
+	if !ok {
+		return 0
+	}
+
+	return int(count.(*atomic.Int32).Load())
+}
+
+func TestSecretStoreConcurrentLoads(t *testing.T) {
+	ctx := context.Background()
+
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	recipients := []age.Recipient{identity.Recipient()}
+
+	memory := storage.NewMemory()
+	writer := internal.NewSecretStore(memory, internal.AgeEncoding{}, []age.Identity{identity}, internal.SecretStoreOptions{})
+	require.NoError(t, writer.WriteSecret(ctx, "a", recipients, []byte("a")))
+	require.NoError(t, writer.WriteSecret(ctx, "b", recipients, []byte("b")))
+
+	counting := &countingStorage{Storage: memory, release: make(chan struct{})}
+	secretStore := internal.NewSecretStore(counting, internal.AgeEncoding{}, []age.Identity{identity}, internal.SecretStoreOptions{})
+
+	var group sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		for _, name := range []string{"a", "b"} {
+			group.Add(1)
+			go func() {
+				defer group.Done()
+
+				data, err := secretStore.LoadSecret(ctx, name)
+				assert.NoError(t, err)
+				assert.Equal(t, name, string(data))
+			}()
+		}
+	}
+
+	// Hold reads back until all loads have started, so that they overlap.
+	time.Sleep(50 * time.Millisecond)
+	close(counting.release)
+	group.Wait()
+
+	assert.Equal(t, 1, counting.count(internal.SecretKey("a")), "concurrent loads of a secret are deduplicated")
+	assert.Equal(t, 1, counting.count(internal.SecretKey("b")), "concurrent loads of a secret are deduplicated")
+
+	_, err = secretStore.LoadSecret(ctx, "missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = secretStore.LoadSecret(ctx, "missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	assert.Equal(t, 2, counting.count(internal.SecretKey("missing")), "failures aren't cached")
+}
+
+func TestSecretStoreRelease(t *testing.T) {
+	for _, options := range []internal.SecretStoreOptions{{}, {LockMemory: true}} {
+		t.Run(fmt.Sprintf("lock=%v", options.LockMemory), func(t *testing.T) {
+			ctx := context.Background()
+
+			identity, err := age.GenerateX25519Identity()
+			require.NoError(t, err)
+			recipients := []age.Recipient{identity.Recipient()}
+
+			counting := &countingStorage{Storage: storage.NewMemory(), release: make(chan struct{})}
+			close(counting.release)
+
+			secretStore := internal.NewSecretStore(counting, internal.AgeEncoding{}, []age.Identity{identity}, options)
+			secretStore.Retain("a", 2)
+
+			data := []byte("secret")
+			err = secretStore.WriteSecret(ctx, "a", recipients, data)
+			if errors.Is(err, internal.ErrLockMemoryUnsupported) {
+				t.Skip(err)
+			}
+			require.NoError(t, err)
+
+			loaded, err := secretStore.LoadSecret(ctx, "a")
+			require.NoError(t, err)
+			assert.Equal(t, "secret", string(loaded))
+			assert.Zero(t, counting.count(internal.SecretKey("a")), "written secrets are cached")
+
+			secretStore.Release("a")
+
+			loaded, err = secretStore.LoadSecret(ctx, "a")
+			require.NoError(t, err)
+			assert.Equal(t, "secret", string(loaded))
+			assert.Zero(t, counting.count(internal.SecretKey("a")), "secrets stay cached while a reader remains")
+
+			secretStore.Release("a")
+
+			if !options.LockMemory {
+				assert.Equal(t, make([]byte, len(data)), data, "released secrets are wiped")
+			}
+
+			loaded, err = secretStore.LoadSecret(ctx, "a")
+			require.NoError(t, err)
+			assert.Equal(t, "secret", string(loaded))
+			assert.Equal(t, 1, counting.count(internal.SecretKey("a")), "wiped secrets are loaded again")
+
+			secretStore.Wipe()
+		})
+	}
+}
+
+func TestSecretStoreKeep(t *testing.T) {
+	ctx := context.Background()
+
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	counting := &countingStorage{Storage: storage.NewMemory(), release: make(chan struct{})}
+	close(counting.release)
+
+	secretStore := internal.NewSecretStore(counting, internal.AgeEncoding{}, []age.Identity{identity}, internal.SecretStoreOptions{Keep: true})
+	secretStore.Retain("a", 1)
+	require.NoError(t, secretStore.WriteSecret(ctx, "a", []age.Recipient{identity.Recipient()}, []byte("a")))
+	secretStore.Release("a")
+
+	data, err := secretStore.LoadSecret(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "a", string(data))
+	assert.Zero(t, counting.count(internal.SecretKey("a")), "released secrets are kept")
+}
@@ -0,0 +1,613 @@
+// Package sops encrypts secrets into files that sops can decrypt, for deployments that use sops instead of agenix.
+//
+// Secrets that are JSON or YAML objects are encrypted value by value, so the structure stays readable.
+// All other secrets are stored like sops stores binary files, as the single value "data".
+// Either way, decrypting a file returns exactly the bytes that were encrypted.
+package sops
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats of sops files.
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// Formats lists the names of all formats.
+var Formats = []string{FormatJSON, FormatYAML}
+
+// Version is the sops version recorded in the files, which determines how sops interprets them.
+const Version = "3.8.1"
+
+const (
+	metadataKey       = "sops"
+	binaryKey         = "data"
+	unencryptedSuffix = "_unencrypted"
+
+	dataKeySize = 32
+	nonceSize   = 32
+)
+
+var (
+	ErrUnknownFormat        = errors.New("unknown sops format")
+	ErrUnsupportedRecipient = errors.New("sops files can only be encrypted to age X25519 recipients")
+	ErrUnsupportedValue     = errors.New("value can't be encrypted by sops")
+	ErrInvalidFile          = errors.New("invalid sops file")
+	ErrNoIdentityMatched    = errors.New("no identity matched any of the age recipients of the sops file")
+	ErrMACMismatch          = errors.New("sops file failed the integrity check")
+)
+
+var encryptedValuePattern = regexp.MustCompile(`^ENC\[AES256_GCM,data:([^,]*),iv:([^,]*),tag:([^,]*),type:([a-z]+)\]$`)
+
+// Encoding writes secrets as sops files in Format.
+// It implements internal.SecretEncoding.
+type Encoding struct {
+	Format string
+}
+
+// Extension names files so that sops recognizes their format.
+func (e Encoding) Extension() string {
+	return ".sops." + e.Format
+}
+
+func (e Encoding) Encrypt(plaintext []byte, recipients []age.Recipient) ([]byte, error) {
+	if e.Format != FormatJSON && e.Format != FormatYAML {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, e.Format)
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+
+	ageKeys, err := encryptDataKey(dataKey, recipients)
+	if err != nil {
+		return nil, err
+	}
+
+	lastModified := time.Now().UTC().Format(time.RFC3339)
+
+	tree, mac, err := e.encryptTree(plaintext, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedMAC, err := encryptValue(mac, "str", dataKey, lastModified)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadataNode yaml.Node
+	if err := metadataNode.Encode(metadata{
+		Age:               ageKeys,
+		LastModified:      lastModified,
+		MAC:               encryptedMAC,
+		UnencryptedSuffix: unencryptedSuffix,
+		Version:           Version,
+	}); err != nil {
+		return nil, err
+	}
+
+	tree.Content = append(tree.Content, stringNode(metadataKey), &metadataNode)
+
+	file, err := e.marshal(tree)
+	if err != nil || e.Format != FormatJSON {
+		return file, err
+	}
+
+	// Indent JSON files like sops does, to keep diffs of them readable.
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, file, "", "\t"); err != nil {
+		return nil, err
+	}
+
+	return indented.Bytes(), nil
+}
+
+// encryptTree encrypts plaintext value by value if it is an object that can be restored exactly, and as binary data otherwise.
+// It returns the encrypted tree and the MAC of the plaintext values.
+func (e Encoding) encryptTree(plaintext []byte, dataKey []byte) (*yaml.Node, string, error) {
+	if tree := parseObject(plaintext); tree != nil && !isBinary(tree) && !hasMetadata(tree) {
+		encrypted, mac, err := transform(tree, dataKey, encryptLeaf)
+		if err == nil {
+			// Only use the tree if decrypting it gives back the exact plaintext.
+			decrypted, _, err := transform(encrypted, dataKey, decryptLeaf)
+			if err == nil {
+				if canonical, err := e.marshal(decrypted); err == nil && bytes.Equal(canonical, plaintext) {
+					return encrypted, mac, nil
+				}
+			}
+		}
+	}
+
+	return transform(mappingNode(binaryKey, stringNode(string(plaintext))), dataKey, encryptLeaf)
+}
+
+func (e Encoding) Decrypt(encrypted []byte, identities []age.Identity) ([]byte, error) {
+	tree := parseObject(encrypted)
+	if tree == nil {
+		return nil, ErrInvalidFile
+	}
+
+	var metadataNode *yaml.Node
+	for i := 0; i < len(tree.Content); i += 2 {
+		if tree.Content[i].Value == metadataKey {
+			metadataNode = tree.Content[i+1]
+			tree.Content = append(tree.Content[:i:i], tree.Content[i+2:]...)
+			break
+		}
+	}
+
+	if metadataNode == nil {
+		return nil, fmt.Errorf("%w: no sops metadata", ErrInvalidFile)
+	}
+
+	var meta metadata
+	if err := metadataNode.Decode(&meta); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFile, err)
+	}
+
+	dataKey, err := decryptDataKey(meta.Age, identities)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted, mac, err := transform(tree, dataKey, decryptLeaf)
+	if err != nil {
+		return nil, err
+	}
+
+	expectedMAC, _, err := decryptValue(meta.MAC, dataKey, meta.LastModified)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(expectedMAC) != mac {
+		return nil, ErrMACMismatch
+	}
+
+	if isBinary(decrypted) {
+		return []byte(decrypted.Content[1].Value), nil
+	}
+
+	return e.marshal(decrypted)
+}
+
+// marshal writes tree in the format of the encoding, the same way that the generators write JSON and YAML.
+func (e Encoding) marshal(tree *yaml.Node) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	switch e.Format {
+	case FormatJSON:
+		if err := writeJSON(&buffer, tree); err != nil {
+			return nil, err
+		}
+		buffer.WriteByte('\n')
+
+	case FormatYAML:
+		encoder := yaml.NewEncoder(&buffer)
+		if err := encoder.Encode(tree); err != nil {
+			return nil, err
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, e.Format)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// metadata is the part of the sops metadata written by this package.
+type metadata struct {
+	Age               []ageKey `yaml:"age"`
+	LastModified      string   `yaml:"lastmodified"`
+	MAC               string   `yaml:"mac"`
+	UnencryptedSuffix string   `yaml:"unencrypted_suffix"`
+	Version           string   `yaml:"version"`
+}
+
+// ageKey is the data key encrypted to one age recipient.
+type ageKey struct {
+	Recipient string `yaml:"recipient"`
+	Enc       string `yaml:"enc"`
+}
+
+// encryptDataKey encrypts the data key to every recipient separately, which is how sops stores age keys.
+// Recipients that appear more than once are only listed once.
+func encryptDataKey(dataKey []byte, recipients []age.Recipient) ([]ageKey, error) {
+	keys := make([]ageKey, 0, len(recipients))
+
+	for _, recipient := range recipients {
+		x25519, ok := recipient.(*age.X25519Recipient)
+		if !ok {
+			return nil, fmt.Errorf("%w: got %T", ErrUnsupportedRecipient, recipient)
+		}
+
+		if slices.ContainsFunc(keys, func(key ageKey) bool { return key.Recipient == x25519.String() }) {
+			continue
+		}
+
+		var buffer bytes.Buffer
+		armorWriter := armor.NewWriter(&buffer)
+
+		writer, err := age.Encrypt(armorWriter, x25519)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := writer.Write(dataKey); err != nil {
+			return nil, err
+		}
+
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+
+		if err := armorWriter.Close(); err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, ageKey{Recipient: x25519.String(), Enc: buffer.String()})
+	}
+
+	return keys, nil
+}
+
+func decryptDataKey(keys []ageKey, identities []age.Identity) ([]byte, error) {
+	for _, key := range keys {
+		reader, err := age.Decrypt(armor.NewReader(strings.NewReader(key.Enc)), identities...)
+		if err != nil {
+			continue
+		}
+
+		dataKey, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(dataKey) != dataKeySize {
+			return nil, fmt.Errorf("%w: data key has %d bytes", ErrInvalidFile, len(dataKey))
+		}
+
+		return dataKey, nil
+	}
+
+	return nil, ErrNoIdentityMatched
+}
+
+// leafFunc replaces a scalar at path in a tree and returns the bytes the scalar contributes to the MAC.
+type leafFunc func(node *yaml.Node, path []string, dataKey []byte) (*yaml.Node, []byte, error)
+
+// transform returns a copy of tree with every scalar replaced by leaf, together with the MAC of the plaintext values.
+// Like in sops, the path of a value consists of the keys leading to it, without any list indices.
+func transform(tree *yaml.Node, dataKey []byte, leaf leafFunc) (*yaml.Node, string, error) {
+	hash := sha512.New()
+
+	var walk func(node *yaml.Node, path []string) (*yaml.Node, error)
+	walk = func(node *yaml.Node, path []string) (*yaml.Node, error) {
+		switch node.Kind {
+		case yaml.MappingNode:
+			result := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			for i := 0; i < len(node.Content); i += 2 {
+				key := node.Content[i]
+				value, err := walk(node.Content[i+1], append(path[:len(path):len(path)], key.Value))
+				if err != nil {
+					return nil, err
+				}
+				result.Content = append(result.Content, stringNode(key.Value), value)
+			}
+			return result, nil
+
+		case yaml.SequenceNode:
+			result := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+			for _, item := range node.Content {
+				value, err := walk(item, path)
+				if err != nil {
+					return nil, err
+				}
+				result.Content = append(result.Content, value)
+			}
+			return result, nil
+
+		case yaml.ScalarNode:
+			value, macBytes, err := leaf(node, path, dataKey)
+			if err != nil {
+				return nil, err
+			}
+			hash.Write(macBytes)
+			return value, nil
+
+		default:
+			return nil, fmt.Errorf("%w: YAML node kind %d", ErrUnsupportedValue, node.Kind)
+		}
+	}
+
+	result, err := walk(tree, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return result, fmt.Sprintf("%X", hash.Sum(nil)), nil
+}
+
+func encryptLeaf(node *yaml.Node, path []string, dataKey []byte) (*yaml.Node, []byte, error) {
+	if isUnencrypted(path) {
+		// sops would leave the value unencrypted, which is never what we want for a secret.
+		return nil, nil, fmt.Errorf("%w: key ends with %s", ErrUnsupportedValue, unencryptedSuffix)
+	}
+
+	var typ string
+	var plaintext, macBytes []byte
+
+	switch node.ShortTag() {
+	case "!!str":
+		typ = "str"
+		plaintext = []byte(node.Value)
+		macBytes = plaintext
+
+	case "!!int":
+		var value int64
+		if err := node.Decode(&value); err != nil {
+			return nil, nil, err
+		}
+		typ = "int"
+		plaintext = []byte(strconv.FormatInt(value, 10))
+		macBytes = plaintext
+
+	case "!!float":
+		var value float64
+		if err := node.Decode(&value); err != nil {
+			return nil, nil, err
+		}
+		typ = "float"
+		plaintext = []byte(strconv.FormatFloat(value, 'f', -1, 64))
+		macBytes = plaintext
+
+	case "!!bool":
+		var value bool
+		if err := node.Decode(&value); err != nil {
+			return nil, nil, err
+		}
+		typ = "bool"
+		plaintext = titleBool(value)
+		macBytes = plaintext
+
+	default:
+		return nil, nil, fmt.Errorf("%w: %s", ErrUnsupportedValue, node.ShortTag())
+	}
+
+	encrypted, err := encryptValue(string(plaintext), typ, dataKey, additionalData(path))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return stringNode(encrypted), macBytes, nil
+}
+
+func decryptLeaf(node *yaml.Node, path []string, dataKey []byte) (*yaml.Node, []byte, error) {
+	if isUnencrypted(path) {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: node.ShortTag(), Value: node.Value}, []byte(node.Value), nil
+	}
+
+	plaintext, typ, err := decryptValue(node.Value, dataKey, additionalData(path))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch typ {
+	case "str":
+		return stringNode(string(plaintext)), plaintext, nil
+	case "int":
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: string(plaintext)}, plaintext, nil
+	case "float":
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: string(plaintext)}, plaintext, nil
+	case "bool":
+		value, err := strconv.ParseBool(string(plaintext))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", ErrInvalidFile, err)
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(value)}, titleBool(value), nil
+	default:
+		return nil, nil, fmt.Errorf("%w: unsupported value type %s", ErrInvalidFile, typ)
+	}
+}
+
+// encryptValue encrypts a single value with AES-GCM the way sops does.
+// The additional data binds the value to its position in the file.
+func encryptValue(plaintext, typ string, dataKey []byte, additionalData string) (string, error) {
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nil, nonce, []byte(plaintext), []byte(additionalData))
+	data, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+
+	return fmt.Sprintf("ENC[AES256_GCM,data:%s,iv:%s,tag:%s,type:%s]",
+		base64.StdEncoding.EncodeToString(data),
+		base64.StdEncoding.EncodeToString(nonce),
+		base64.StdEncoding.EncodeToString(tag),
+		typ,
+	), nil
+}
+
+func decryptValue(encrypted string, dataKey []byte, additionalData string) ([]byte, string, error) {
+	match := encryptedValuePattern.FindStringSubmatch(encrypted)
+	if match == nil {
+		return nil, "", fmt.Errorf("%w: value is not encrypted", ErrInvalidFile)
+	}
+
+	var parts [3][]byte
+	for i := range parts {
+		var err error
+		if parts[i], err = base64.StdEncoding.DecodeString(match[i+1]); err != nil {
+			return nil, "", fmt.Errorf("%w: %w", ErrInvalidFile, err)
+		}
+	}
+	data, nonce, tag := parts[0], parts[1], parts[2]
+
+	if len(nonce) != nonceSize {
+		return nil, "", fmt.Errorf("%w: nonce has %d bytes", ErrInvalidFile, len(nonce))
+	}
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	plaintext, err := gcm.Open(nil, nonce, append(data, tag...), []byte(additionalData))
+	if err != nil {
+		return nil, "", ErrMACMismatch
+	}
+
+	return plaintext, match[4], nil
+}
+
+func newGCM(dataKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCMWithNonceSize(block, nonceSize)
+}
+
+func additionalData(path []string) string {
+	return strings.Join(path, ":") + ":"
+}
+
+// titleBool formats booleans the way sops encrypts them and adds them to the MAC, which it inherited from its Python implementation.
+func titleBool(value bool) []byte {
+	if value {
+		return []byte("True")
+	}
+	return []byte("False")
+}
+
+func isUnencrypted(path []string) bool {
+	for _, key := range path {
+		if strings.HasSuffix(key, unencryptedSuffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseObject parses data as a JSON or YAML object and returns nil if it isn't one.
+func parseObject(data []byte) *yaml.Node {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil || document.Kind != yaml.DocumentNode || len(document.Content) != 1 {
+		return nil
+	}
+
+	if tree := document.Content[0]; tree.Kind == yaml.MappingNode {
+		return tree
+	}
+
+	return nil
+}
+
+// hasMetadata returns whether tree has a value where sops keeps its metadata.
+func hasMetadata(tree *yaml.Node) bool {
+	for i := 0; i < len(tree.Content); i += 2 {
+		if tree.Content[i].Value == metadataKey {
+			return true
+		}
+	}
+	return false
+}
+
+// isBinary returns whether tree has the shape sops uses for binary files, which is an object with only the string "data".
+func isBinary(tree *yaml.Node) bool {
+	return len(tree.Content) == 2 && tree.Content[0].Value == binaryKey && tree.Content[1].Kind == yaml.ScalarNode && tree.Content[1].ShortTag() == "!!str"
+}
+
+func stringNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+func mappingNode(key string, value *yaml.Node) *yaml.Node {
+	return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{stringNode(key), value}}
+}
+
+// writeJSON writes tree as compact JSON with the keys in their original order, escaped like encoding/json does.
+func writeJSON(buffer *bytes.Buffer, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		buffer.WriteByte('{')
+		for i := 0; i < len(node.Content); i += 2 {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			if err := writeJSON(buffer, stringNode(node.Content[i].Value)); err != nil {
+				return err
+			}
+			buffer.WriteByte(':')
+			if err := writeJSON(buffer, node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte('}')
+
+	case yaml.SequenceNode:
+		buffer.WriteByte('[')
+		for i, item := range node.Content {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			if err := writeJSON(buffer, item); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte(']')
+
+	case yaml.ScalarNode:
+		switch node.ShortTag() {
+		case "!!str":
+			encoded, err := json.Marshal(node.Value)
+			if err != nil {
+				return err
+			}
+			buffer.Write(encoded)
+		case "!!int", "!!float", "!!bool":
+			buffer.WriteString(node.Value)
+		case "!!null":
+			buffer.WriteString("null")
+		default:
+			return fmt.Errorf("%w: %s", ErrUnsupportedValue, node.ShortTag())
+		}
+
+	default:
+		return fmt.Errorf("%w: YAML node kind %d", ErrUnsupportedValue, node.Kind)
+	}
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package sops_test
+
+import (
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+	"tbx.at/secrets-generator/internal/sops"
+)
+
+func TestRoundTrip(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		format    string
+		plaintext string
+		perValue  bool
+	}{
+		{"json object", sops.FormatJSON, `{"a":"x\u003cy","b":{"c":[1,2.5,true]},"d":false}` + "\n", true},
+		{"yaml object", sops.FormatYAML, "a: x\nb:\n    c:\n        - 1\n        - 2.5\n        - true\n", true},
+		{"plain string", sops.FormatJSON, "correct horse battery staple", false},
+		{"plain string in yaml", sops.FormatYAML, "correct horse battery staple\n", false},
+		{"indented json", sops.FormatJSON, "{\n  \"a\": 1\n}\n", false},
+		{"yaml in json file", sops.FormatJSON, "a: x\n", false},
+		{"null value", sops.FormatJSON, `{"a":null}` + "\n", false},
+		{"unencrypted suffix", sops.FormatJSON, `{"a_unencrypted":"x"}` + "\n", false},
+		{"binary shape", sops.FormatJSON, `{"data":"x"}` + "\n", false},
+		{"metadata key", sops.FormatJSON, `{"sops":"x"}` + "\n", false},
+		{"empty", sops.FormatYAML, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoding := sops.Encoding{Format: tt.format}
+
+			encrypted, err := encoding.Encrypt([]byte(tt.plaintext), []age.Recipient{identity.Recipient()})
+			require.NoError(t, err)
+			if tt.plaintext != "" {
+				assert.NotContains(t, string(encrypted), strings.TrimSpace(tt.plaintext))
+			}
+
+			var file map[string]any
+			require.NoError(t, yaml.Unmarshal(encrypted, &file))
+			assert.Contains(t, file, "sops")
+			if tt.perValue {
+				assert.NotContains(t, file, "data")
+			} else {
+				assert.Len(t, file, 2)
+				assert.Contains(t, file, "data")
+			}
+
+			decrypted, err := encoding.Decrypt(encrypted, []age.Identity{identity})
+			require.NoError(t, err)
+			assert.Equal(t, tt.plaintext, string(decrypted))
+		})
+	}
+}
+
+func TestMultipleRecipients(t *testing.T) {
+	identities := make([]*age.X25519Identity, 3)
+	recipients := make([]age.Recipient, len(identities))
+	for i := range identities {
+		var err error
+		identities[i], err = age.GenerateX25519Identity()
+		require.NoError(t, err)
+		recipients[i] = identities[i].Recipient()
+	}
+
+	encoding := sops.Encoding{Format: sops.FormatYAML}
+
+	encrypted, err := encoding.Encrypt([]byte("secret"), append(recipients, recipients[0]))
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(encrypted), identities[0].Recipient().String()), "duplicate recipients are listed once")
+
+	for _, identity := range identities {
+		assert.Contains(t, string(encrypted), identity.Recipient().String())
+
+		decrypted, err := encoding.Decrypt(encrypted, []age.Identity{identity})
+		require.NoError(t, err)
+		assert.Equal(t, "secret", string(decrypted))
+	}
+
+	other, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	_, err = encoding.Decrypt(encrypted, []age.Identity{other})
+	assert.ErrorIs(t, err, sops.ErrNoIdentityMatched)
+}
+
+func TestTampering(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	encoding := sops.Encoding{Format: sops.FormatJSON}
+
+	encrypted, err := encoding.Encrypt([]byte(`{"a":"x","b":"y"}`+"\n"), []age.Recipient{identity.Recipient()})
+	require.NoError(t, err)
+
+	var file map[string]any
+	require.NoError(t, yaml.Unmarshal(encrypted, &file))
+
+	// Swapping two encrypted values keeps each of them intact, but they no longer match their keys.
+	swapped := strings.NewReplacer(file["a"].(string), file["b"].(string), file["b"].(string), file["a"].(string)).Replace(string(encrypted))
+	_, err = encoding.Decrypt([]byte(swapped), []age.Identity{identity})
+	assert.ErrorIs(t, err, sops.ErrMACMismatch)
+
+	// Removing a value changes the MAC.
+	var tree yaml.Node
+	require.NoError(t, yaml.Unmarshal(encrypted, &tree))
+	tree.Content[0].Content = tree.Content[0].Content[2:]
+	removed, err := yaml.Marshal(&tree)
+	require.NoError(t, err)
+
+	_, err = encoding.Decrypt(removed, []age.Identity{identity})
+	assert.ErrorIs(t, err, sops.ErrMACMismatch)
+}
+
+func TestInvalidFiles(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	encoding := sops.Encoding{Format: sops.FormatJSON}
+
+	for _, file := range []string{"", "plain text", `{"data":"x"}`, `["sops"]`} {
+		_, err := encoding.Decrypt([]byte(file), []age.Identity{identity})
+		assert.ErrorIs(t, err, sops.ErrInvalidFile, file)
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	_, err = sops.Encoding{Format: "toml"}.Encrypt([]byte("x"), []age.Recipient{identity.Recipient()})
+	assert.ErrorIs(t, err, sops.ErrUnknownFormat)
+
+	scrypt, err := age.NewScryptRecipient("password")
+	require.NoError(t, err)
+
+	_, err = sops.Encoding{Format: sops.FormatJSON}.Encrypt([]byte("x"), []age.Recipient{scrypt})
+	assert.ErrorIs(t, err, sops.ErrUnsupportedRecipient)
+}
+
+func TestExtension(t *testing.T) {
+	assert.Equal(t, ".sops.json", sops.Encoding{Format: sops.FormatJSON}.Extension())
+	assert.Equal(t, ".sops.yaml", sops.Encoding{Format: sops.FormatYAML}.Extension())
+}
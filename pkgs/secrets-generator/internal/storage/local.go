@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	localFileMode      = 0660
+	localDirectoryMode = 0770
+)
+
+// Local stores files in a directory on the local filesystem.
+type Local struct {
+	Root string
+}
+
+func (l *Local) Read(ctx context.Context, key string) ([]byte, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(l.path(key))
+}
+
+// Write writes data to a temporary file next to the file at key first, which is then renamed over it.
+// An existing file keeps its permissions.
+func (l *Local) Write(ctx context.Context, key string, data []byte) (err error) {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+
+	target := l.path(key)
+
+	mode := fs.FileMode(localFileMode)
+	if info, err := os.Stat(target); err == nil {
+		mode = info.Mode().Perm()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), localDirectoryMode); err != nil {
+		return err
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tempFile.Close()
+			_ = os.Remove(tempFile.Name())
+		}
+	}()
+
+	if _, err := tempFile.Write(data); err != nil {
+		return err
+	}
+
+	if err := tempFile.Chmod(mode); err != nil {
+		return err
+	}
+
+	// Make sure the data is on disk before the rename makes it visible.
+	if err := tempFile.Sync(); err != nil {
+		return err
+	}
+
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tempFile.Name(), target)
+}
+
+// List skips the temporary files left behind by interrupted writes.
+func (l *Local) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+
+	err := filepath.WalkDir(l.Root, func(p string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) && p == l.Root {
+			return filepath.SkipAll
+		} else if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(l.Root, p)
+		if err != nil {
+			return err
+		}
+
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.Sort(keys)
+	return keys, nil
+}
+
+func (l *Local) path(key string) string {
+	return filepath.Join(l.Root, filepath.FromSlash(key))
+}
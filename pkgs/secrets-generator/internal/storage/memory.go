@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Memory keeps files in memory, which is mostly useful for tests.
+type Memory struct {
+	files map[string][]byte
+	mutex sync.Mutex
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		files: make(map[string][]byte),
+	}
+}
+
+func (m *Memory) Read(ctx context.Context, key string) ([]byte, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	data, ok := m.files[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", fs.ErrNotExist, key)
+	}
+
+	return slices.Clone(data), nil
+}
+
+func (m *Memory) Write(ctx context.Context, key string, data []byte) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.files[key] = slices.Clone(data)
+	return nil
+}
+
+func (m *Memory) List(ctx context.Context, prefix string) ([]string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var keys []string
+	for key := range m.files {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+
+	slices.Sort(keys)
+	return keys, nil
+}
@@ -0,0 +1,96 @@
+// Package storage stores the encrypted secret and entropy files written by the generator.
+// Files are addressed by keys like "data/db/password.age" that use forward slashes on every platform.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+var (
+	ErrInvalidKey      = errors.New("invalid storage key")
+	ErrInvalidLocation = errors.New("invalid storage location")
+)
+
+// Storage is a place to keep encrypted files.
+type Storage interface {
+	// Read returns the contents of the file at key.
+	// The error wraps fs.ErrNotExist if there is no such file.
+	Read(ctx context.Context, key string) ([]byte, error)
+
+	// Write replaces the file at key with data.
+	// Replacement is atomic, so readers see either the old or the new contents but never a mix of both.
+	Write(ctx context.Context, key string, data []byte) error
+
+	// List returns the sorted keys of all files whose key starts with prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// Open returns the storage at location.
+// Locations are either a path to a local directory or a URL like "s3://bucket/prefix".
+// S3 URLs take the query parameters "endpoint" and "region" and read credentials from the usual AWS environment variables.
+func Open(location string) (Storage, error) {
+	if !strings.Contains(location, "://") {
+		return &Local{Root: location}, nil
+	}
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidLocation, err)
+	}
+
+	switch u.Scheme {
+	case "file":
+		return &Local{Root: u.Path}, nil
+
+	case "s3":
+		if u.Host == "" {
+			return nil, fmt.Errorf("%w: no bucket in %s", ErrInvalidLocation, location)
+		}
+
+		region := u.Query().Get("region")
+		if region == "" {
+			region = os.Getenv("AWS_REGION")
+		}
+		if region == "" {
+			region = DefaultS3Region
+		}
+
+		endpoint := u.Query().Get("endpoint")
+		if endpoint == "" {
+			endpoint = "https://s3." + region + ".amazonaws.com"
+		}
+
+		prefix := strings.TrimPrefix(u.Path, "/")
+		if prefix != "" && !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+
+		return &S3{
+			Endpoint:        endpoint,
+			Bucket:          u.Host,
+			Prefix:          prefix,
+			Region:          region,
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("%w: unsupported scheme %s", ErrInvalidLocation, u.Scheme)
+	}
+}
+
+// checkKey makes sure that key can't refer to anything outside of the storage.
+func checkKey(key string) error {
+	if key == "" || path.IsAbs(key) || path.Clean(key) != key || key == ".." || strings.HasPrefix(key, "../") {
+		return fmt.Errorf("%w: %s", ErrInvalidKey, key)
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package storage_test
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tbx.at/secrets-generator/internal/storage"
+)
+
+func TestLocal(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "secrets")
+	testStorage(t, &storage.Local{Root: root})
+
+	info, err := os.Stat(filepath.Join(root, "data", "a.age"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0660), info.Mode().Perm())
+
+	// Existing files keep their permissions when they are replaced.
+	require.NoError(t, os.Chmod(filepath.Join(root, "data", "a.age"), 0600))
+	require.NoError(t, (&storage.Local{Root: root}).Write(context.Background(), "data/a.age", nil))
+
+	info, err = os.Stat(filepath.Join(root, "data", "a.age"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	t.Run("missing root", func(t *testing.T) {
+		keys, err := (&storage.Local{Root: filepath.Join(root, "missing")}).List(context.Background(), "")
+		assert.NoError(t, err)
+		assert.Empty(t, keys)
+	})
+}
+
+func TestMemory(t *testing.T) {
+	testStorage(t, storage.NewMemory())
+}
+
+func testStorage(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	_, err := s.Read(ctx, "data/a.age")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	require.NoError(t, s.Write(ctx, "data/a.age", []byte("a")))
+	require.NoError(t, s.Write(ctx, "data/b/c.age", []byte("c")))
+	require.NoError(t, s.Write(ctx, "entropy/a.age", []byte("entropy")))
+	require.NoError(t, s.Write(ctx, "data/a.age", []byte("replaced")))
+
+	data, err := s.Read(ctx, "data/a.age")
+	require.NoError(t, err)
+	assert.Equal(t, "replaced", string(data))
+
+	keys, err := s.List(ctx, "data/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"data/a.age", "data/b/c.age"}, keys)
+
+	keys, err = s.List(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"data/a.age", "data/b/c.age", "entropy/a.age"}, keys)
+
+	for _, key := range []string{"", "/etc/passwd", "../a.age", "data/../../a.age", "data//a.age"} {
+		assert.ErrorIs(t, s.Write(ctx, key, nil), storage.ErrInvalidKey, key)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "id")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_REGION", "")
+
+	s, err := storage.Open("secrets")
+	require.NoError(t, err)
+	assert.Equal(t, &storage.Local{Root: "secrets"}, s)
+
+	s, err = storage.Open("file:///var/lib/secrets")
+	require.NoError(t, err)
+	assert.Equal(t, &storage.Local{Root: "/var/lib/secrets"}, s)
+
+	s, err = storage.Open("s3://bucket/some/prefix?endpoint=http://localhost:9000")
+	require.NoError(t, err)
+	assert.Equal(t, &storage.S3{
+		Endpoint:        "http://localhost:9000",
+		Bucket:          "bucket",
+		Prefix:          "some/prefix/",
+		Region:          storage.DefaultS3Region,
+		AccessKeyID:     "id",
+		SecretAccessKey: "secret",
+	}, s)
+
+	s, err = storage.Open("s3://bucket?region=eu-central-1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://s3.eu-central-1.amazonaws.com", s.(*storage.S3).Endpoint)
+
+	for _, location := range []string{"ftp://host/secrets", "s3:///prefix"} {
+		_, err := storage.Open(location)
+		assert.ErrorIs(t, err, storage.ErrInvalidLocation, location)
+	}
+}
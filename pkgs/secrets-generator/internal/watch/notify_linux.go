@@ -0,0 +1,138 @@
+//go:build linux
+
+package watch
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// inotifyMask selects the events that can change a file or replace it in its directory.
+const inotifyMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_MODIFY | unix.IN_CLOSE_WRITE | unix.IN_ATTRIB
+
+// inotifyNotifier watches the directories that contain the watched path and its target.
+// Files that are replaced by renaming another file over them can't be watched directly, and neither can symlinks.
+type inotifyNotifier struct {
+	path string
+	file *os.File
+
+	mutex sync.Mutex
+
+	// dirs holds the watch descriptors of the watched directories.
+	dirs map[string]int
+
+	// names holds the names of the files of interest in every watched directory by watch descriptor.
+	names map[int]map[string]bool
+}
+
+func newNotifier(path string, changed chan<- struct{}, errs chan<- error) (notifier, error) {
+	// A non-blocking descriptor is handled by the runtime poller, so closing the file interrupts reading from it.
+	fd, err := unix.InotifyInit1(unix.IN_NONBLOCK | unix.IN_CLOEXEC)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	n := &inotifyNotifier{
+		path: path,
+		file: os.NewFile(uintptr(fd), "inotify"),
+	}
+
+	if err := n.follow(); err != nil {
+		_ = n.file.Close()
+		return nil, err
+	}
+
+	go n.read(changed, errs)
+
+	return n, nil
+}
+
+func (n *inotifyNotifier) follow() error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	dirs := make(map[string]int)
+	names := make(map[int]map[string]bool)
+
+	for _, p := range []string{n.path, target(n.path)} {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return err
+		}
+
+		dir := filepath.Dir(abs)
+
+		wd, ok := dirs[dir]
+		if !ok {
+			wd, err = unix.InotifyAddWatch(int(n.file.Fd()), dir, inotifyMask)
+			if err != nil {
+				return &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+			}
+
+			dirs[dir] = wd
+			names[wd] = make(map[string]bool)
+		}
+
+		names[wd][filepath.Base(abs)] = true
+	}
+
+	// Stop watching the directory of a previous target.
+	for dir, wd := range n.dirs {
+		if _, ok := dirs[dir]; !ok {
+			_, _ = unix.InotifyRmWatch(int(n.file.Fd()), uint32(wd))
+		}
+	}
+
+	n.dirs = dirs
+	n.names = names
+
+	return nil
+}
+
+func (n *inotifyNotifier) close() error {
+	return n.file.Close()
+}
+
+// read reads events until the notifier is closed.
+func (n *inotifyNotifier) read(changed chan<- struct{}, errs chan<- error) {
+	buffer := make([]byte, 64*1024)
+
+	for {
+		count, err := n.file.Read(buffer)
+		if errors.Is(err, os.ErrClosed) {
+			return
+		} else if err != nil {
+			errs <- err
+			return
+		}
+
+		// Events are a struct inotify_event followed by a NUL-padded name.
+		for offset := 0; offset+unix.SizeofInotifyEvent <= count; {
+			wd := int32(binary.NativeEndian.Uint32(buffer[offset:]))
+			mask := binary.NativeEndian.Uint32(buffer[offset+4:])
+			nameLength := int(binary.NativeEndian.Uint32(buffer[offset+12:]))
+
+			nameStart := offset + unix.SizeofInotifyEvent
+			name := strings.TrimRight(string(buffer[nameStart:nameStart+nameLength]), "\x00")
+			offset = nameStart + nameLength
+
+			// If the queue overflowed, events may have been lost.
+			if mask&unix.IN_Q_OVERFLOW != 0 || n.interesting(int(wd), name) {
+				notify(changed)
+			}
+		}
+	}
+}
+
+func (n *inotifyNotifier) interesting(wd int, name string) bool {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return n.names[wd][name]
+}
@@ -0,0 +1,78 @@
+//go:build !linux
+
+package watch
+
+import (
+	"os"
+	"time"
+)
+
+// pollInterval is how often the watched file is checked for changes where inotify isn't available.
+const pollInterval = time.Second
+
+// pollNotifier checks the watched file for changes periodically.
+type pollNotifier struct {
+	done chan struct{}
+}
+
+func newNotifier(path string, changed chan<- struct{}, _ chan<- error) (notifier, error) {
+	n := &pollNotifier{done: make(chan struct{})}
+
+	go n.poll(path, changed)
+
+	return n, nil
+}
+
+// follow does nothing since every poll resolves the path again.
+func (n *pollNotifier) follow() error {
+	return nil
+}
+
+func (n *pollNotifier) close() error {
+	close(n.done)
+	return nil
+}
+
+func (n *pollNotifier) poll(path string, changed chan<- struct{}) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	last := stat(path)
+
+	for {
+		select {
+		case <-n.done:
+			return
+
+		case <-ticker.C:
+			if current := stat(path); !current.equal(last) {
+				last = current
+				notify(changed)
+			}
+		}
+	}
+}
+
+// fileState identifies a version of the file at a path.
+type fileState struct {
+	target  string
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+func stat(path string) fileState {
+	state := fileState{target: target(path)}
+
+	if info, err := os.Stat(path); err == nil {
+		state.exists = true
+		state.size = info.Size()
+		state.modTime = info.ModTime()
+	}
+
+	return state
+}
+
+func (s fileState) equal(other fileState) bool {
+	return s.target == other.target && s.exists == other.exists && s.size == other.size && s.modTime.Equal(other.modTime)
+}
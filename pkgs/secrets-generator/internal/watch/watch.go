@@ -0,0 +1,78 @@
+// Package watch notices changes to a file, like a configuration that is rebuilt while the generator is running.
+// Changes are detected with inotify on Linux and by polling elsewhere.
+package watch
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+)
+
+// debounce is how long Watch waits for further events before it reports a change.
+// Editors and builds often touch a file several times in a row.
+const debounce = 100 * time.Millisecond
+
+// notifier sends to its changed channel whenever the watched file may have changed.
+type notifier interface {
+	// follow watches the current target of the watched path, after the path was replaced by a symlink to another file.
+	follow() error
+
+	close() error
+}
+
+// Watch calls fn whenever the file at path may have changed, until ctx is done.
+// If path is a symlink, replacing the symlink as well as changing the file it points to count as changes.
+// Calls are not concurrent, and changes during a call are reported once it returns.
+// The file doesn't need to exist, in which case creating it is a change.
+func Watch(ctx context.Context, path string, fn func()) error {
+	changed := make(chan struct{}, 1)
+	errs := make(chan error, 1)
+
+	n, err := newNotifier(path, changed, errs)
+	if err != nil {
+		return err
+	}
+	defer n.close()
+
+	var timer <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case err := <-errs:
+			return err
+
+		case <-changed:
+			timer = time.After(debounce)
+
+		case <-timer:
+			timer = nil
+
+			if err := n.follow(); err != nil {
+				return err
+			}
+
+			fn()
+		}
+	}
+}
+
+// target returns the file that path currently points to, or path itself if that can't be resolved yet.
+func target(path string) string {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return path
+	}
+
+	return resolved
+}
+
+// notify sends to changed without blocking, since one pending change covers any number of them.
+func notify(changed chan<- struct{}) {
+	select {
+	case changed <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,104 @@
+package watch_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tbx.at/secrets-generator/internal/watch"
+)
+
+// startWatch watches path until the test ends and returns a channel that receives a value for every call of fn.
+func startWatch(t *testing.T, path string) <-chan struct{} {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := make(chan struct{}, 16)
+	done := make(chan error)
+
+	go func() {
+		done <- watch.Watch(ctx, path, func() { calls <- struct{}{} })
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		assert.ErrorIs(t, <-done, context.Canceled)
+	})
+
+	// Give the watcher time to set up before the test changes anything.
+	time.Sleep(50 * time.Millisecond)
+
+	return calls
+}
+
+func expectChange(t *testing.T, calls <-chan struct{}, message string) {
+	t.Helper()
+
+	select {
+	case <-calls:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no change reported: %s", message)
+	}
+
+	// Drain calls for events that belong to the same change.
+	for {
+		select {
+		case <-calls:
+		case <-time.After(300 * time.Millisecond):
+			return
+		}
+	}
+}
+
+func TestWatchFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+
+	calls := startWatch(t, path)
+
+	require.NoError(t, os.WriteFile(path, []byte("1"), 0644))
+	expectChange(t, calls, "file created")
+
+	require.NoError(t, os.WriteFile(path, []byte("22"), 0644))
+	expectChange(t, calls, "file written")
+
+	replacement := filepath.Join(dir, "config.json.tmp")
+	require.NoError(t, os.WriteFile(replacement, []byte("333"), 0644))
+	require.NoError(t, os.Rename(replacement, path))
+	expectChange(t, calls, "file replaced")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.json"), []byte("1"), 0644))
+	select {
+	case <-calls:
+		t.Fatal("change reported for another file")
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestWatchSymlink(t *testing.T) {
+	dir := t.TempDir()
+	store := t.TempDir()
+	path := filepath.Join(dir, "result")
+
+	first := filepath.Join(store, "first.json")
+	second := filepath.Join(store, "second.json")
+	require.NoError(t, os.WriteFile(first, []byte("1"), 0644))
+	require.NoError(t, os.WriteFile(second, []byte("2"), 0644))
+	require.NoError(t, os.Symlink(first, path))
+
+	calls := startWatch(t, path)
+
+	require.NoError(t, os.WriteFile(first, []byte("11"), 0644))
+	expectChange(t, calls, "target written")
+
+	// Builds replace the symlink by renaming a new one over it.
+	temporary := filepath.Join(dir, "result.tmp")
+	require.NoError(t, os.Symlink(second, temporary))
+	require.NoError(t, os.Rename(temporary, path))
+	expectChange(t, calls, "symlink replaced")
+
+	require.NoError(t, os.WriteFile(second, []byte("22"), 0644))
+	expectChange(t, calls, "new target written")
+}
@@ -0,0 +1,53 @@
+package secretsgen
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	"tbx.at/secrets-generator/internal"
+)
+
+// Generator generates secrets whose generation parameters select it with a custom type:
+//
+//	"generation": {"custom": {"type": "name", "params": {...}}}
+type Generator interface {
+	// Deterministic returns whether Generate always writes the same secret when it reads the same bytes from request.Rand.
+	// Deterministic generators must read all randomness from request.Rand.
+	// Their secrets are only regenerated when the output changes, like when the parameters change.
+	// Other secrets are only generated if they don't exist yet.
+	Deterministic() bool
+
+	// Generate writes a secret to output.
+	Generate(ctx context.Context, request Request, output io.Writer) error
+}
+
+// Request describes the secret to generate.
+type Request struct {
+	// Name is the name of the secret.
+	Name string
+
+	// Params holds the params of the custom generation parameters as JSON, or nil if there are none.
+	Params json.RawMessage
+
+	// Rand is the source of randomness for the secret.
+	Rand io.Reader
+}
+
+// customGenerator adapts a Generator to the generators used internally.
+type customGenerator struct {
+	Generator
+}
+
+func (g customGenerator) Generate(ctx context.Context, rng io.Reader, secret internal.Secret, output io.Writer) error {
+	request := Request{Name: secret.Name, Rand: rng}
+
+	if params := secret.Generation.Custom.Params; params != nil {
+		var err error
+		if request.Params, err = json.Marshal(params); err != nil {
+			return err
+		}
+	}
+
+	return g.Generator.Generate(ctx, request, output)
+}
@@ -0,0 +1,225 @@
+// Package secretsgen embeds the secrets generator into other programs.
+//
+// It runs the same generation as the secrets-generator command: secrets are generated or regenerated as needed and written to a storage as age files encrypted to the generator and the hosts they are mounted on.
+// Programs can register their own generators for secrets with custom generation parameters.
+//
+// The configuration types are aliases of the types the secrets-generator command decodes its configuration into.
+// They follow that configuration, so fields are added to them whenever the configuration gains options.
+package secretsgen
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"filippo.io/age"
+	"tbx.at/secrets-generator/internal"
+	"tbx.at/secrets-generator/internal/generate"
+	"tbx.at/secrets-generator/internal/generator"
+	"tbx.at/secrets-generator/internal/generator/functions"
+	"tbx.at/secrets-generator/internal/sops"
+	"tbx.at/secrets-generator/internal/storage"
+)
+
+// The configuration has the same structure as the JSON configuration read by the secrets-generator command.
+type (
+	Config                     = internal.Config
+	Secret                     = internal.Secret
+	SecretMount                = internal.SecretMount
+	GenerationParams           = internal.GenerationParams
+	GenerationParamsCustom     = internal.GenerationParamsCustom
+	GenerationParamsImport     = internal.GenerationParamsImport
+	GenerationParamsJSON       = internal.GenerationParamsJSON
+	GenerationParamsJSONOutput = internal.GenerationParamsJSONOutput
+	GenerationParamsRandom     = internal.GenerationParamsRandom
+	GenerationParamsScript     = internal.GenerationParamsScript
+	GenerationParamsTemplate   = internal.GenerationParamsTemplate
+)
+
+var (
+	ErrNoIdentities         = errors.New("no generator identities")
+	ErrUnknownFormat        = errors.New("unknown secret file format")
+	ErrUnknownGenerator     = generate.ErrUnknownGenerator
+	ErrDependencyCycle      = generate.ErrDependencyCycle
+	ErrUnknownDependency    = generate.ErrUnknownDependency
+	ErrUndeclaredDependency = functions.ErrUndeclaredDependency
+)
+
+// DecodeConfig decodes a JSON configuration from r and rejects fields that don't exist.
+func DecodeConfig(r io.Reader) (Config, error) {
+	return internal.DecodeConfig(r)
+}
+
+// Format is the format of secret files.
+type Format string
+
+const (
+	FormatAge      Format = "age"
+	FormatSopsJSON Format = "sops-json"
+	FormatSopsYAML Format = "sops-yaml"
+)
+
+// encoding returns the encoding that writes secret files in f.
+// The empty format is FormatAge.
+func (f Format) encoding() (internal.SecretEncoding, error) {
+	switch f {
+	case "", FormatAge:
+		return internal.AgeEncoding{}, nil
+	case FormatSopsJSON:
+		return sops.Encoding{Format: sops.FormatJSON}, nil
+	case FormatSopsYAML:
+		return sops.Encoding{Format: sops.FormatYAML}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, f)
+	}
+}
+
+// Options control how Run generates secrets.
+type Options struct {
+	// StorageRoot is the directory or URL like "s3://bucket/prefix" that holds the secret and entropy files.
+	// It defaults to the directory "secrets" in the current working directory.
+	StorageRoot string
+
+	// Format is the format of secret files. It defaults to FormatAge.
+	// Entropy files are always age files.
+	Format Format
+
+	// Identities are the identities of the generator.
+	// Every secret is encrypted to all of them, so any of them can decrypt all secrets.
+	Identities []*age.X25519Identity
+
+	// Generators holds the generators for secrets with custom generation parameters by the type they are registered under.
+	Generators map[string]Generator
+
+	// Rotate holds the names of secrets that are generated again with fresh entropy, even if they haven't changed.
+	Rotate []string
+
+	// StrictDependencies makes reading a secret fail unless the reading secret depends on it.
+	// Secrets depend on the secrets in their dependsOn list and on those their generation parameters read by name.
+	StrictDependencies bool
+
+	// LockMemory locks decrypted secrets into memory, so that they are never written to swap.
+	LockMemory bool
+
+	// Logger receives a progress message for every secret.
+	// Nothing is logged if it is nil.
+	Logger *slog.Logger
+}
+
+// Status is what happened to a secret during a run.
+type Status string
+
+const (
+	StatusGenerated    Status = "generated"
+	StatusUnchanged    Status = "unchanged"
+	StatusExists       Status = "exists"
+	StatusNotGenerated Status = "not generated"
+)
+
+// Result lists what happened to the secrets of the configuration.
+type Result struct {
+	// Secrets holds the secrets that were handled, sorted by name.
+	// If Run fails, secrets it didn't get to are missing.
+	Secrets []SecretResult
+}
+
+// SecretResult is what happened to a single secret.
+type SecretResult struct {
+	Name   string
+	Status Status
+
+	// Reason says why a generated secret was generated, like "content differs".
+	Reason string
+
+	// Details holds additional information for Reason, like the parts of JSON content that changed.
+	// Details never contain the values of secrets.
+	Details []string
+}
+
+// Generated returns the names of the secrets that were generated.
+func (r Result) Generated() []string {
+	var names []string
+	for _, secret := range r.Secrets {
+		if secret.Status == StatusGenerated {
+			names = append(names, secret.Name)
+		}
+	}
+
+	return names
+}
+
+// Run generates or regenerates the secrets in config as needed.
+func Run(ctx context.Context, config Config, options Options) (Result, error) {
+	var result Result
+
+	if len(options.Identities) == 0 {
+		return result, ErrNoIdentities
+	}
+
+	encoding, err := options.Format.encoding()
+	if err != nil {
+		return result, err
+	}
+
+	secretStorage, err := storage.Open(cmp.Or(options.StorageRoot, internal.SecretsDirectory))
+	if err != nil {
+		return result, err
+	}
+
+	generators := make(map[string]generator.Generator, len(options.Generators))
+	for name, gen := range options.Generators {
+		generators[name] = customGenerator{gen}
+	}
+
+	var mutex sync.Mutex
+
+	err = generate.Run(ctx, config, generate.Options{
+		Identities:         options.Identities,
+		Rotate:             options.Rotate,
+		StrictDependencies: options.StrictDependencies,
+		LockMemory:         options.LockMemory,
+		Logger:             options.Logger,
+		Storage:            secretStorage,
+		Encoding:           encoding,
+		Generators:         generators,
+		Outcome: func(outcome generate.Outcome) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			result.Secrets = append(result.Secrets, secretResult(outcome))
+		},
+	})
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	slices.SortFunc(result.Secrets, func(a, b SecretResult) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	return result, err
+}
+
+func secretResult(outcome generate.Outcome) SecretResult {
+	result := SecretResult{Name: outcome.Secret}
+
+	switch outcome.Skipped {
+	case "":
+		result.Status = StatusGenerated
+		result.Reason = string(outcome.Explanation.Reason)
+		result.Details = outcome.Explanation.Details
+	case generate.SkipUnchanged:
+		result.Status = StatusUnchanged
+	case generate.SkipExists:
+		result.Status = StatusExists
+	case generate.SkipNotGenerated:
+		result.Status = StatusNotGenerated
+	}
+
+	return result
+}
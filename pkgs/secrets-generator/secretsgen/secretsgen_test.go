@@ -0,0 +1,197 @@
+package secretsgen_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tbx.at/secrets-generator/secretsgen"
+)
+
+// tokenGenerator writes a prefix from its params followed by random hex digits.
+type tokenGenerator struct {
+	deterministic bool
+}
+
+func (g tokenGenerator) Deterministic() bool {
+	return g.deterministic
+}
+
+func (g tokenGenerator) Generate(ctx context.Context, request secretsgen.Request, output io.Writer) error {
+	var params struct {
+		Prefix string `json:"prefix"`
+	}
+	if err := json.Unmarshal(request.Params, &params); err != nil {
+		return err
+	}
+
+	token := make([]byte, 8)
+	if _, err := io.ReadFull(request.Rand, token); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(output, params.Prefix+hex.EncodeToString(token))
+	return err
+}
+
+func TestRun(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	config := secretsgen.Config{
+		PublicKeys: map[string][]string{"host": {identity.Recipient().String()}},
+		Secrets: map[string]secretsgen.Secret{
+			"token": {
+				Generation: secretsgen.GenerationParams{
+					Custom: &secretsgen.GenerationParamsCustom{Type: "token", Params: map[string]any{"prefix": "tok_"}},
+				},
+			},
+			"session": {
+				Generation: secretsgen.GenerationParams{
+					Custom: &secretsgen.GenerationParamsCustom{Type: "session", Params: map[string]any{"prefix": "ses_"}},
+				},
+			},
+			"password": {
+				Generation: secretsgen.GenerationParams{
+					Random: &secretsgen.GenerationParamsRandom{Length: 16, Charsets: map[string]bool{"lowercase": true}},
+				},
+			},
+			"manual": {},
+		},
+		SecretMounts: map[string]secretsgen.SecretMount{
+			"token": {Host: "host", Secret: "token"},
+		},
+	}
+
+	root := t.TempDir()
+	options := secretsgen.Options{
+		StorageRoot: root,
+		Identities:  []*age.X25519Identity{identity},
+		Generators: map[string]secretsgen.Generator{
+			"token":   tokenGenerator{deterministic: true},
+			"session": tokenGenerator{deterministic: false},
+		},
+	}
+
+	result, err := secretsgen.Run(context.Background(), config, options)
+	require.NoError(t, err)
+	assert.Equal(t, []secretsgen.SecretResult{
+		{Name: "manual", Status: secretsgen.StatusNotGenerated},
+		{Name: "password", Status: secretsgen.StatusGenerated, Reason: "entropy file missing"},
+		{Name: "session", Status: secretsgen.StatusGenerated, Reason: "secret file missing"},
+		{Name: "token", Status: secretsgen.StatusGenerated, Reason: "entropy file missing"},
+	}, result.Secrets)
+	assert.Equal(t, []string{"password", "session", "token"}, result.Generated())
+
+	token := readSecret(t, identity, filepath.Join(root, "data", "token.age"))
+	assert.Regexp(t, `^tok_[0-9a-f]{16}$`, token)
+	assert.Regexp(t, `^ses_[0-9a-f]{16}$`, readSecret(t, identity, filepath.Join(root, "data", "session.age")))
+
+	result, err = secretsgen.Run(context.Background(), config, options)
+	require.NoError(t, err)
+	assert.Equal(t, []secretsgen.SecretResult{
+		{Name: "manual", Status: secretsgen.StatusNotGenerated},
+		{Name: "password", Status: secretsgen.StatusUnchanged},
+		{Name: "session", Status: secretsgen.StatusExists},
+		{Name: "token", Status: secretsgen.StatusUnchanged},
+	}, result.Secrets)
+
+	// Deterministic custom generators are regenerated when their output changes.
+	config.Secrets["token"].Generation.Custom.Params = map[string]any{"prefix": "new_"}
+
+	result, err = secretsgen.Run(context.Background(), config, options)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"token"}, result.Generated())
+	assert.Equal(t, "content differs", result.Secrets[3].Reason)
+	assert.Regexp(t, `^new_[0-9a-f]{16}$`, readSecret(t, identity, filepath.Join(root, "data", "token.age")))
+}
+
+func TestRunErrors(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	config := secretsgen.Config{
+		Secrets: map[string]secretsgen.Secret{
+			"token": {
+				Generation: secretsgen.GenerationParams{
+					Custom: &secretsgen.GenerationParamsCustom{Type: "token"},
+				},
+			},
+		},
+	}
+
+	_, err = secretsgen.Run(context.Background(), config, secretsgen.Options{StorageRoot: t.TempDir()})
+	assert.ErrorIs(t, err, secretsgen.ErrNoIdentities)
+
+	_, err = secretsgen.Run(context.Background(), config, secretsgen.Options{
+		StorageRoot: t.TempDir(),
+		Identities:  []*age.X25519Identity{identity},
+	})
+	assert.ErrorIs(t, err, secretsgen.ErrUnknownGenerator)
+}
+
+func TestRunFormat(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	config := secretsgen.Config{
+		Secrets: map[string]secretsgen.Secret{
+			"password": {
+				Generation: secretsgen.GenerationParams{
+					Random: &secretsgen.GenerationParamsRandom{Length: 16, Charsets: map[string]bool{"lowercase": true}},
+				},
+			},
+		},
+	}
+
+	root := t.TempDir()
+	_, err = secretsgen.Run(context.Background(), config, secretsgen.Options{
+		StorageRoot: root,
+		Format:      secretsgen.FormatSopsJSON,
+		Identities:  []*age.X25519Identity{identity},
+	})
+	require.NoError(t, err)
+
+	assert.FileExists(t, filepath.Join(root, "data", "password.sops.json"))
+	assert.NoFileExists(t, filepath.Join(root, "data", "password.age"))
+
+	_, err = secretsgen.Run(context.Background(), config, secretsgen.Options{
+		StorageRoot: t.TempDir(),
+		Format:      "toml",
+		Identities:  []*age.X25519Identity{identity},
+	})
+	assert.ErrorIs(t, err, secretsgen.ErrUnknownFormat)
+}
+
+func TestDecodeConfig(t *testing.T) {
+	config, err := secretsgen.DecodeConfig(bytes.NewReader([]byte(`{
+		"secrets": {"token": {"generation": {"custom": {"type": "token", "params": {"prefix": "tok_"}}}}}
+	}`)))
+	require.NoError(t, err)
+	assert.Equal(t, "token", config.Secrets["token"].Generation.Custom.Type)
+	assert.Equal(t, map[string]any{"prefix": "tok_"}, config.Secrets["token"].Generation.Custom.Params)
+
+	_, err = secretsgen.DecodeConfig(bytes.NewReader([]byte(`{"secrets": {"token": {"generation": {"custom": {"typo": "token"}}}}}`)))
+	assert.Error(t, err)
+}
+
+func readSecret(t *testing.T, identity age.Identity, path string) string {
+	encrypted, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	decrypted, err := age.Decrypt(bytes.NewReader(encrypted), identity)
+	require.NoError(t, err)
+
+	secret, err := io.ReadAll(decrypted)
+	require.NoError(t, err)
+
+	return string(secret)
+}